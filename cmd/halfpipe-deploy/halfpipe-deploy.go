@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gookit/color"
+	"github.com/spf13/afero"
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/springernature/halfpipe-deploy-resource/logger"
+	"github.com/springernature/halfpipe-deploy-resource/manifest"
+	"github.com/springernature/halfpipe-deploy-resource/plan"
+)
+
+// Runs the same planner as the out binary from a laptop, e.g. to debug a stuck deploy.
+//
+//	halfpipe-deploy push|check|promote|status [--request request.json] [--manifest manifest.yml] [--api ...] [--resume] [--dry-run] [--yes]
+//
+// The request is built from the request file, in the same json format Concourse sends to the out binary,
+// then the CF_API, CF_ORG, CF_SPACE, CF_USERNAME and CF_PASSWORD env vars, and then the flags.
+var commands = map[string]string{
+	"push":    config.PUSH,
+	"check":   config.CHECK,
+	"promote": config.PROMOTE,
+	"status":  config.STATUS,
+}
+
+type options struct {
+	dryRun   bool
+	yes      bool
+	cfBinary string
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: halfpipe-deploy push|check|promote|status [flags], see halfpipe-deploy <command> --help for the flags")
+}
+
+func main() {
+	os.Exit(run())
+}
+
+// run returns the exit code rather than exiting, so that the deferred cleanup of the temporary directory always runs.
+func run() int {
+	logger := logger.NewLogger(os.Stderr)
+
+	if len(os.Args) < 2 || commands[os.Args[1]] == "" {
+		usage()
+		return 2
+	}
+
+	fs := afero.Afero{Fs: afero.NewOsFs()}
+	request, opts, err := parseRequest(os.Args[1], os.Args[2:], environmentToMap(), fs)
+	if err != nil {
+		logger.Println(err)
+		return 2
+	}
+
+	// The request is completed like the out binary does, paths are relative to the working directory.
+	request, err = config.NewRequestReader([]string{"halfpipe-deploy", ""}, map[string]string{}, nil, fs, manifest.NewManifestReadWrite(fs)).CompleteRequest(request)
+	if err != nil {
+		logger.Println(err)
+		return 1
+	}
+	request.Metadata.DeployedBy = fmt.Sprintf("halfpipe-deploy run by %s", os.Getenv("USER"))
+	request.Metadata.Pipeline = ""
+
+	// The planner writes the vars and labels into the manifest and the plan logs in with cf,
+	// so both happen in a temporary directory instead of changing the manifest and the cf target of the user.
+	workDir, err := os.MkdirTemp("", "halfpipe-deploy")
+	if err != nil {
+		logger.Println(err)
+		return 1
+	}
+	defer os.RemoveAll(workDir)
+
+	if request.Params.ManifestPath, err = copyManifest(fs, request.Params.ManifestPath, workDir); err != nil {
+		logger.Println(err)
+		return 1
+	}
+	if err = os.Setenv("CF_HOME", workDir); err != nil {
+		logger.Println(err)
+		return 1
+	}
+
+	cfClient, appsSummary, privateDomains, err := plan.GetApps(request)
+	if err != nil {
+		logger.Println(fmt.Sprintf("Unable to login to api: %s, org: %s, space: %s with user %s", request.Source.API, request.Source.Org, request.Source.Space, request.Source.Username))
+		logger.Println(err)
+		return 1
+	}
+
+	resumer := plan.NewResumer(request, cfClient)
+	appsToPlanWith, err := resumer.AppsToPlanWith(appsSummary)
+	if err != nil {
+		logger.Println(err)
+		return 1
+	}
+
+	liveHasNetworkPolicies, err := plan.LiveHasNetworkPolicies(cfClient, request, appsToPlanWith)
+	if err != nil {
+		logger.Println(err)
+		return 1
+	}
+
+	inherited, err := plan.LookupInherited(cfClient, request, appsToPlanWith)
+	if err != nil {
+		logger.Println(err)
+		return 1
+	}
+
+	p, err := plan.NewPlanner(manifest.NewManifestReadWrite(fs), plan.NewPushPlan(liveHasNetworkPolicies, inherited), plan.NewCheckPlan(), plan.NewPromotePlan(privateDomains), plan.NewCleanupPlan(), plan.NewRollingDeployPlan(), plan.NewDeleteCandidatePlan(), plan.NewStopCandidatePlan(), plan.NewLogsPlan(), plan.NewCheckLabelsPlan(), plan.NewSSOPlan(),
+		plan.NewRouteCheckPlan(), plan.NewQuotaPlan(inherited), plan.NewServicesPlan(), plan.NewProvenancePlan(), plan.NewRunTaskPlan(), plan.NewDynamicCleanupPlan(), plan.NewRouteCleanupPlan(),
+		plan.NewStatusPlan(), plan.NewRestartPlan(), plan.NewRestagePlan(), plan.NewScalePlan(), plan.NewSetEnvPlan(), plan.NewLintPlan()).Plan(request, appsToPlanWith)
+	if err != nil {
+		logger.Println(err)
+		return 1
+	}
+
+	logger.Println(color.New(color.FgGreen).Sprintf("%s", p.String()))
+
+	if opts.dryRun {
+		logger.Println("Dry run, nothing is executed")
+		return 0
+	}
+
+	if !opts.yes && !confirm(fmt.Sprintf("Execute the plan against %s, org %s, space %s?", request.Source.API, request.Source.Org, request.Source.Space)) {
+		logger.Println("Nothing is executed")
+		return 1
+	}
+
+	timeout, err := request.Params.TimeoutOrDefault()
+	if err != nil {
+		logger.Println(err)
+		return 1
+	}
+
+	if err = resumer.Execute(p, appsToPlanWith, plan.NewCFBinaryExecutor(&logger, opts.cfBinary), &logger, timeout, false); err != nil {
+		logger.Println(err)
+		return 1
+	}
+	return 0
+}
+
+func parseRequest(command string, args []string, environ map[string]string, fs afero.Afero) (request config.Request, opts options, err error) {
+	flags := flag.NewFlagSet(fmt.Sprintf("halfpipe-deploy %s", command), flag.ContinueOnError)
+	requestPath := flags.String("request", "", "path to a json request with source and params, like Concourse sends to the out binary")
+	manifestPath := flags.String("manifest", "", "path to the cf manifest")
+	appPath := flags.String("app-path", "", "path to the app bits to push")
+	testDomain := flags.String("test-domain", "", "domain of the candidate route")
+	gitRefPath := flags.String("git-ref-path", "", "path to a file with the git revision")
+	buildVersionPath := flags.String("build-version-path", "", "path to a file with the build version")
+	eaid := flags.String("eaid", "", "EAID of the app")
+	team := flags.String("team", "", "team of the app")
+	timeout := flags.String("timeout", "", "timeout of each command, e.g. 15m")
+	api := flags.String("api", environ["CF_API"], "cf api, defaults to $CF_API")
+	org := flags.String("org", environ["CF_ORG"], "cf org, defaults to $CF_ORG")
+	space := flags.String("space", environ["CF_SPACE"], "cf space, defaults to $CF_SPACE")
+	username := flags.String("username", environ["CF_USERNAME"], "cf username, defaults to $CF_USERNAME")
+	password := flags.String("password", environ["CF_PASSWORD"], "cf password, defaults to $CF_PASSWORD")
+	resume := flags.Bool("resume", false, "resume a failed push or promote from the step that failed")
+	flags.BoolVar(&opts.dryRun, "dry-run", false, "print the plan without executing it")
+	flags.BoolVar(&opts.yes, "yes", false, "execute the plan without asking")
+	flags.StringVar(&opts.cfBinary, "cf-binary", "cf", "the cf CLI to run the cf commands of the plan with")
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+
+	if *requestPath != "" {
+		content, e := fs.ReadFile(*requestPath)
+		if e != nil {
+			err = e
+			return
+		}
+		if err = json.Unmarshal(content, &request); err != nil {
+			err = fmt.Errorf("failed to parse request '%s': %w", *requestPath, err)
+			return
+		}
+	}
+
+	// Only what is set overrides the request file.
+	set := func(field *string, value string) {
+		if value != "" {
+			*field = value
+		}
+	}
+	set(&request.Source.API, *api)
+	set(&request.Source.Org, *org)
+	set(&request.Source.Space, *space)
+	set(&request.Source.Username, *username)
+	set(&request.Source.Password, *password)
+	set(&request.Params.ManifestPath, *manifestPath)
+	set(&request.Params.AppPath, *appPath)
+	set(&request.Params.TestDomain, *testDomain)
+	set(&request.Params.GitRefPath, *gitRefPath)
+	set(&request.Params.BuildVersionPath, *buildVersionPath)
+	set(&request.Params.EAID, *eaid)
+	set(&request.Params.Team, *team)
+	set(&request.Params.Timeout, *timeout)
+	if *resume {
+		request.Params.Resume = true
+	}
+	request.Params.Command = commands[command]
+	return
+}
+
+func copyManifest(fs afero.Afero, manifestPath string, dir string) (string, error) {
+	content, err := fs.ReadFile(manifestPath)
+	if err != nil {
+		return "", err
+	}
+	copied := filepath.Join(dir, filepath.Base(manifestPath))
+	return copied, fs.WriteFile(copied, content, 0600)
+}
+
+func confirm(question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func environmentToMap() map[string]string {
+	env := make(map[string]string)
+	for _, element := range os.Environ() {
+		parts := strings.SplitN(element, "=", 2)
+		env[parts[0]] = parts[1]
+	}
+	return env
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRequest(t *testing.T) {
+	fs := afero.Afero{Fs: afero.NewMemMapFs()}
+	require.NoError(t, fs.WriteFile("request.json", []byte(`{
+  "source": {"api": "file-api", "org": "file-org", "space": "file-space", "username": "file-user", "password": "file-password"},
+  "params": {"manifestPath": "file-manifest.yml", "testDomain": "file.com"}
+}`), 0600))
+
+	t.Run("the request file", func(t *testing.T) {
+		request, _, err := parseRequest("push", []string{"--request", "request.json"}, map[string]string{}, fs)
+
+		require.NoError(t, err)
+		assert.Equal(t, config.PUSH, request.Params.Command)
+		assert.Equal(t, config.Source{API: "file-api", Org: "file-org", Space: "file-space", Username: "file-user", Password: "file-password"}, request.Source)
+		assert.Equal(t, "file-manifest.yml", request.Params.ManifestPath)
+		assert.Equal(t, "file.com", request.Params.TestDomain)
+	})
+
+	t.Run("env vars override the request file", func(t *testing.T) {
+		environ := map[string]string{"CF_API": "env-api", "CF_ORG": "env-org", "CF_SPACE": "env-space", "CF_USERNAME": "env-user", "CF_PASSWORD": "env-password"}
+
+		request, _, err := parseRequest("push", []string{"--request", "request.json"}, environ, fs)
+
+		require.NoError(t, err)
+		assert.Equal(t, config.Source{API: "env-api", Org: "env-org", Space: "env-space", Username: "env-user", Password: "env-password"}, request.Source)
+		assert.Equal(t, "file-manifest.yml", request.Params.ManifestPath)
+	})
+
+	t.Run("flags override env vars and the request file", func(t *testing.T) {
+		environ := map[string]string{"CF_API": "env-api", "CF_ORG": "env-org", "CF_SPACE": "env-space"}
+
+		request, opts, err := parseRequest("promote", []string{"--request", "request.json", "--api", "flag-api", "--space", "flag-space", "--manifest", "flag-manifest.yml", "--resume", "--dry-run"}, environ, fs)
+
+		require.NoError(t, err)
+		assert.Equal(t, config.PROMOTE, request.Params.Command)
+		assert.Equal(t, config.Source{API: "flag-api", Org: "env-org", Space: "flag-space", Username: "file-user", Password: "file-password"}, request.Source)
+		assert.Equal(t, "flag-manifest.yml", request.Params.ManifestPath)
+		assert.Equal(t, "file.com", request.Params.TestDomain)
+		assert.True(t, request.Params.Resume)
+		assert.True(t, opts.dryRun)
+		assert.False(t, opts.yes)
+	})
+
+	t.Run("an invalid request file", func(t *testing.T) {
+		require.NoError(t, fs.WriteFile("invalid.json", []byte(`{`), 0600))
+
+		_, _, err := parseRequest("push", []string{"--request", "invalid.json"}, map[string]string{}, fs)
+
+		assert.ErrorContains(t, err, "failed to parse request 'invalid.json'")
+	})
+}
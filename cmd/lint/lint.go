@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/springernature/halfpipe-deploy-resource/logger"
+	"github.com/springernature/halfpipe-deploy-resource/manifest"
+	"github.com/springernature/halfpipe-deploy-resource/plan"
+)
+
+// Lints a manifest the way halfpipe-lint does, without a request, CF or network access,
+// so it can run in pre-commit hooks and PR checks.
+//
+//	lint [-manifest manifest.yml] [-policy lint-policy.yml] [-format text|json]
+func main() {
+	manifestPath := flag.String("manifest", "manifest.yml", "path to the cf manifest")
+	policyPath := flag.String("policy", "", "path to a yaml file with a top level lintPolicy")
+	format := flag.String("format", "", "output format, either 'text' or 'json', overrides the format of the policy")
+	flag.Parse()
+
+	logger := logger.NewLogger(os.Stderr)
+	fs := afero.Afero{Fs: afero.NewOsFs()}
+
+	params := config.Params{
+		Command:      config.LINT,
+		ManifestPath: *manifestPath,
+		CliVersion:   "cf7",
+	}
+	if *policyPath != "" {
+		policy, err := config.ReadLintPolicyFile(fs, *policyPath)
+		if err != nil {
+			logger.Println(err)
+			os.Exit(1)
+		}
+		params.LintPolicy = policy
+		params.LintPolicyPath = *policyPath
+	}
+	params.LintPolicy = params.LintPolicy.Merge(config.LintPolicy{Format: *format})
+
+	request := config.Request{Params: params}
+	if err := request.Verify(false); err != nil {
+		logger.Println(err)
+		os.Exit(1)
+	}
+
+	man, err := manifest.NewManifestReadWrite(fs).ReadManifest(*manifestPath)
+	if err != nil {
+		logger.Println(fmt.Sprintf("failed to read manifest '%s': %s", *manifestPath, err))
+		os.Exit(1)
+	}
+
+	failed := false
+	for _, app := range man.Applications {
+		p := plan.NewLintPlan().Plan(app, request)
+		if err := p.Execute(plan.NewCFCliExecutor(&logger, request), nil, &logger, time.Minute, false); err != nil {
+			logger.Println(err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/springernature/halfpipe-deploy-resource/cmd/out/check_resource"
@@ -15,7 +14,6 @@ import (
 	"github.com/springernature/halfpipe-deploy-resource/logger"
 
 	cfclient "github.com/cloudfoundry/go-cfclient/v3/client"
-	cfconfig "github.com/cloudfoundry/go-cfclient/v3/config"
 	"github.com/cloudfoundry/go-cfclient/v3/resource"
 	"github.com/spf13/afero"
 	"github.com/springernature/halfpipe-deploy-resource/config"
@@ -57,25 +55,54 @@ func main() {
 
 	metrics := plan.NewMetrics(requestConfig, "https://aggregationgateway.k8s.springernature.io/")
 
-	cfClient, appsSummary, privateDomains, err := getApps(requestConfig)
+	var cfClient *cfclient.Client
+	var appsSummary []*resource.App
+	var privateDomains []*resource.Domain
+	if requestConfig.Params.Command == config.LINT {
+		// Lint must work without network access, e.g. in pre-commit hooks.
+		metrics = plan.NewNoMetrics()
+	} else {
+		cfClient, appsSummary, privateDomains, err = plan.GetApps(requestConfig)
+		if err != nil {
+			errStr := fmt.Sprintf("Unable to login to api: %s, org: %s, space: %s with user %s", requestConfig.Source.API, requestConfig.Source.Org, requestConfig.Source.Space, requestConfig.Source.Username)
+			logger.Println(errStr)
+			logger.Println(err)
+			syscall.Exit(1)
+		}
+	}
+
+	resumer := plan.NewResumer(requestConfig, cfClient)
+	appsToPlanWith, err := resumer.AppsToPlanWith(appsSummary)
 	if err != nil {
-		errStr := fmt.Sprintf("Unable to login to api: %s, org: %s, space: %s with user %s", requestConfig.Source.API, requestConfig.Source.Org, requestConfig.Source.Space, requestConfig.Source.Username)
-		logger.Println(errStr)
 		logger.Println(err)
-		syscall.Exit(1)
+		os.Exit(1)
+	}
+
+	liveHasNetworkPolicies, err := plan.LiveHasNetworkPolicies(cfClient, requestConfig, appsToPlanWith)
+	if err != nil {
+		logger.Println(err)
+		os.Exit(1)
+	}
+
+	inherited, err := plan.LookupInherited(cfClient, requestConfig, appsToPlanWith)
+	if err != nil {
+		logger.Println(err)
+		os.Exit(1)
 	}
 
 	var p plan.Plan
 	switch requestConfig.Params.Command {
 	case "":
 		panic("params.command must not be empty")
-	case config.PUSH, config.CHECK, config.PROMOTE, config.DELETE, config.CLEANUP, config.ROLLING_DEPLOY, config.DELETE_CANDIDATE, config.STOP_CANDIDATE, config.ALL, config.LOGS, config.SSO:
+	case config.PUSH, config.CHECK, config.PROMOTE, config.DELETE, config.CLEANUP, config.ROLLING_DEPLOY, config.DELETE_CANDIDATE, config.STOP_CANDIDATE, config.ALL, config.LOGS, config.SSO, config.RUN_TASK, config.STATUS, config.RESTART, config.RESTAGE, config.SCALE, config.SET_ENV, config.LINT:
 
 		if requestConfig.Params.CliVersion == "" {
 			requestConfig.Params.CliVersion = "cf6"
 		}
 
-		p, err = plan.NewPlanner(manifest.NewManifestReadWrite(fs), plan.NewPushPlan(), plan.NewCheckPlan(), plan.NewPromotePlan(privateDomains), plan.NewCleanupPlan(), plan.NewRollingDeployPlan(), plan.NewDeleteCandidatePlan(), plan.NewStopCandidatePlan(), plan.NewLogsPlan(), plan.NewCheckLabelsPlan(), plan.NewSSOPlan()).Plan(requestConfig, appsSummary)
+		p, err = plan.NewPlanner(manifest.NewManifestReadWrite(fs), plan.NewPushPlan(liveHasNetworkPolicies, inherited), plan.NewCheckPlan(), plan.NewPromotePlan(privateDomains), plan.NewCleanupPlan(), plan.NewRollingDeployPlan(), plan.NewDeleteCandidatePlan(), plan.NewStopCandidatePlan(), plan.NewLogsPlan(), plan.NewCheckLabelsPlan(), plan.NewSSOPlan(),
+			plan.NewRouteCheckPlan(), plan.NewQuotaPlan(inherited), plan.NewServicesPlan(), plan.NewProvenancePlan(), plan.NewRunTaskPlan(), plan.NewDynamicCleanupPlan(), plan.NewRouteCleanupPlan(),
+			plan.NewStatusPlan(), plan.NewRestartPlan(), plan.NewRestagePlan(), plan.NewScalePlan(), plan.NewSetEnvPlan(), plan.NewLintPlan()).Plan(requestConfig, appsToPlanWith)
 	default:
 		panic(fmt.Sprintf("Command '%s' not supported", requestConfig.Params.Command))
 	}
@@ -87,16 +114,16 @@ func main() {
 
 	logger.Println(color.New(color.FgGreen).Sprintf("%s", p.String()))
 
-	timeout, err := getTimeout(requestConfig)
+	timeout, err := requestConfig.Params.TimeoutOrDefault()
 	if err != nil {
 		logger.Println(err)
 		os.Exit(1)
 	}
 
-	if err = p.Execute(plan.NewCFCliExecutor(&logger, requestConfig), cfClient, &logger, timeout, requestConfig.Metadata.IsActions); err != nil {
+	if err = resumer.Execute(p, appsToPlanWith, plan.NewCFCliExecutor(&logger, requestConfig), &logger, timeout, requestConfig.Metadata.IsActions); err != nil {
 		logger.Println(err)
 		logger.Println("")
-		for _, fix := range fixes.SuggestFix(logger.BytesWritten, requestConfig) {
+		for _, fix := range fixes.SuggestFix(logger.Tail(), requestConfig) {
 			logger.Println(fix)
 		}
 		metrics.Failure()
@@ -123,52 +150,3 @@ func main() {
 		}
 	}
 }
-
-func getTimeout(request config.Request) (time.Duration, error) {
-	if request.Params.Timeout == "" {
-		return 15 * time.Minute, nil
-	}
-	return time.ParseDuration(request.Params.Timeout)
-}
-
-func getApps(request config.Request) (client *cfclient.Client, appSummary []*resource.App, privateDomains []*resource.Domain, err error) {
-	ctx := context.Background()
-
-	c, err := cfconfig.New(request.Source.API, cfconfig.UserPassword(request.Source.Username, request.Source.Password))
-	if err != nil {
-		return
-	}
-	client, err = cfclient.New(c)
-	if err != nil {
-		return
-	}
-
-	orgOpts := cfclient.NewOrganizationListOptions()
-	orgOpts.Names = cfclient.Filter{Values: []string{request.Source.Org}}
-	org, err := client.Organizations.Single(ctx, orgOpts)
-	if err != nil {
-		return
-	}
-
-	spaceOpts := cfclient.NewSpaceListOptions()
-	spaceOpts.Names = cfclient.Filter{Values: []string{request.Source.Space}}
-	spaceOpts.OrganizationGUIDs = cfclient.Filter{Values: []string{org.GUID}}
-	space, err := client.Spaces.Single(ctx, spaceOpts)
-	if err != nil {
-		return
-	}
-
-	appOpts := cfclient.NewAppListOptions()
-	appOpts.SpaceGUIDs = cfclient.Filter{Values: []string{space.GUID}}
-	appSummary, err = client.Applications.ListAll(ctx, appOpts)
-	if err != nil {
-		return
-	}
-
-	privateDomains, err = client.Domains.ListForOrganizationAll(ctx, org.GUID, cfclient.NewDomainListOptions())
-	if err != nil {
-		return
-	}
-
-	return
-}
@@ -11,3 +11,10 @@ const ALL = "halfpipe-all"
 const LOGS = "halfpipe-logs"
 const STOP_CANDIDATE = "halfpipe-stop-candidate"
 const SSO = "halfpipe-sso"
+const RUN_TASK = "halfpipe-run-task"
+const STATUS = "halfpipe-status"
+const RESTART = "halfpipe-restart"
+const RESTAGE = "halfpipe-restage"
+const SCALE = "halfpipe-scale"
+const SET_ENV = "halfpipe-set-env"
+const LINT = "halfpipe-lint"
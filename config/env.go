@@ -0,0 +1,24 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// VarsFile is the format of the file pointed to by Params.VarsPath.
+type VarsFile struct {
+	Vars  map[string]string `yaml:"vars"`
+	Unset []string          `yaml:"unset"`
+}
+
+func (params Params) verifyEnvChanges() error {
+	for _, key := range params.UnsetVars {
+		if strings.TrimSpace(key) == "" {
+			return ParamsInvalidError("unsetVars", "must not contain empty keys")
+		}
+		if _, found := params.Vars[key]; found {
+			return ParamsInvalidError("unsetVars", fmt.Sprintf("'%s' cannot be both set in vars and unset", key))
+		}
+	}
+	return nil
+}
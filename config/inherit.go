@@ -0,0 +1,30 @@
+package config
+
+import (
+	"strings"
+)
+
+// Inherit selects what the candidate takes over from the live app before it is started,
+// so that changes made at runtime, like a 'cf scale' during an incident, are not reverted by the next deploy.
+type Inherit struct {
+	Scale    bool     `yaml:"scale"`
+	Env      []string `yaml:"env"`
+	Services bool     `yaml:"services"`
+}
+
+func (i Inherit) IsEmpty() bool {
+	return !i.Scale && len(i.Env) == 0 && !i.Services
+}
+
+func (params Params) verifyInherit() error {
+	for _, key := range params.Inherit.Env {
+		if strings.TrimSpace(key) == "" {
+			return ParamsInvalidError("inherit.env", "must not contain empty keys")
+		}
+	}
+
+	if params.Inherit.Scale && params.Instances != 0 {
+		return ParamsInvalidError("inherit.scale", "cannot be used together with instances")
+	}
+	return nil
+}
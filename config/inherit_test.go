@@ -0,0 +1,30 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestVerifyInherit(t *testing.T) {
+	base := Params{
+		Command:      ALL,
+		CliVersion:   "cf7",
+		ManifestPath: "path",
+		EAID:         "eaid",
+	}
+
+	emptyKey := base
+	emptyKey.Inherit = Inherit{Env: []string{"A", " "}}
+	assert.Equal(t, ParamsInvalidError("inherit.env", "must not contain empty keys"), emptyKey.Verify(false))
+
+	scaleAndInstances := base
+	scaleAndInstances.Inherit = Inherit{Scale: true}
+	scaleAndInstances.Instances = 2
+	assert.Equal(t, ParamsInvalidError("inherit.scale", "cannot be used together with instances"), scaleAndInstances.Verify(false))
+
+	allesOk := base
+	allesOk.Inherit = Inherit{Scale: true, Env: []string{"A"}, Services: true}
+	assert.NoError(t, allesOk.Verify(false))
+	assert.False(t, allesOk.Inherit.IsEmpty())
+	assert.True(t, base.Inherit.IsEmpty())
+}
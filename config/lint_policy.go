@@ -0,0 +1,189 @@
+package config
+
+import (
+	"fmt"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+	"slices"
+	"sort"
+	"strings"
+)
+
+type LintLevel string
+
+const (
+	LintOff   LintLevel = "off"
+	LintWarn  LintLevel = "warn"
+	LintError LintLevel = "error"
+)
+
+const (
+	LintRuleRequiredLabels          = "required-labels"
+	LintRuleAllowedLabelValues      = "allowed-label-values"
+	LintRuleDeprecatedStack         = "deprecated-stack"
+	LintRuleMaxMemory               = "max-memory"
+	LintRuleHealthCheckType         = "health-check-type"
+	LintRuleNoRoute                 = "no-route"
+	LintRuleHealthCheckProcessOnWeb = "health-check-process-on-web"
+	LintRuleMissingTimeout          = "missing-timeout"
+	LintRuleUnpinnedBuildpack       = "unpinned-buildpack"
+	LintRuleRandomRoute             = "random-route"
+	LintRuleUnderscoreInHostname    = "underscore-in-hostname"
+)
+
+// lintRules are the ids of the rules a policy can configure, the rules themselves live with the lint plan.
+var lintRules = []string{
+	LintRuleAllowedLabelValues,
+	LintRuleDeprecatedStack,
+	LintRuleHealthCheckProcessOnWeb,
+	LintRuleHealthCheckType,
+	LintRuleMaxMemory,
+	LintRuleMissingTimeout,
+	LintRuleNoRoute,
+	LintRuleRandomRoute,
+	LintRuleRequiredLabels,
+	LintRuleUnderscoreInHostname,
+	LintRuleUnpinnedBuildpack,
+}
+
+var DefaultRequiredLabels = []string{"product", "environment", "eaid"}
+var DefaultDeprecatedStacks = []string{"cflinuxfs3"}
+
+const LintFormatText = "text"
+const LintFormatJSON = "json"
+
+// LintPolicy is how strict the linting of the manifest before a push is, from Params.LintPolicy or the file in Params.LintPolicyPath.
+type LintPolicy struct {
+	Rules              map[string]LintLevel `yaml:"rules"`
+	RequiredLabels     []string             `yaml:"requiredLabels"`
+	AllowedLabelValues map[string][]string  `yaml:"allowedLabelValues"`
+	DeprecatedStacks   []string             `yaml:"deprecatedStacks"`
+	MaxMemory          string               `yaml:"maxMemory"`
+	Format             string               `yaml:"format"`
+}
+
+// LintPolicyFile is the format of the file pointed to by Params.LintPolicyPath.
+type LintPolicyFile struct {
+	LintPolicy LintPolicy `yaml:"lintPolicy"`
+}
+
+// ReadLintPolicyFile reads the lint policy from a LintPolicyFile, it is verified together with the rest of the params.
+func ReadLintPolicyFile(fs afero.Afero, path string) (policy LintPolicy, err error) {
+	content, err := fs.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	var policyFile LintPolicyFile
+	if err = yaml.Unmarshal(content, &policyFile); err != nil {
+		return
+	}
+	return policyFile.LintPolicy, nil
+}
+
+// Level is the level the policy sets for the rule, or the severity of the rule itself when the policy does not set it.
+func (p LintPolicy) Level(rule string, severity LintLevel) LintLevel {
+	if level, found := p.Rules[rule]; found {
+		return level
+	}
+	return severity
+}
+
+func (p LintPolicy) RequiredLabelsOrDefault() []string {
+	if len(p.RequiredLabels) == 0 {
+		return DefaultRequiredLabels
+	}
+	return p.RequiredLabels
+}
+
+func (p LintPolicy) DeprecatedStacksOrDefault() []string {
+	if len(p.DeprecatedStacks) == 0 {
+		return DefaultDeprecatedStacks
+	}
+	return p.DeprecatedStacks
+}
+
+func (p LintPolicy) MaxMemoryInMB() int {
+	mb, _ := ParseMegabytes(p.MaxMemory)
+	return mb
+}
+
+func (p LintPolicy) FormatOrDefault() string {
+	if p.Format == "" {
+		return LintFormatText
+	}
+	return p.Format
+}
+
+// Merge returns the policy with what is set in other on top, rules are merged one by one.
+func (p LintPolicy) Merge(other LintPolicy) LintPolicy {
+	merged := p
+	merged.Rules = make(map[string]LintLevel)
+	for rule, level := range p.Rules {
+		merged.Rules[rule] = level
+	}
+	for rule, level := range other.Rules {
+		merged.Rules[rule] = level
+	}
+	if len(other.RequiredLabels) > 0 {
+		merged.RequiredLabels = other.RequiredLabels
+	}
+	if len(other.AllowedLabelValues) > 0 {
+		merged.AllowedLabelValues = other.AllowedLabelValues
+	}
+	if len(other.DeprecatedStacks) > 0 {
+		merged.DeprecatedStacks = other.DeprecatedStacks
+	}
+	if other.MaxMemory != "" {
+		merged.MaxMemory = other.MaxMemory
+	}
+	if other.Format != "" {
+		merged.Format = other.Format
+	}
+	return merged
+}
+
+func (params Params) verifyLintPolicy() error {
+	policy := params.LintPolicy
+
+	var rules []string
+	for rule := range policy.Rules {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+	for _, rule := range rules {
+		field := fmt.Sprintf("lintPolicy.rules.%s", rule)
+		if !slices.Contains(lintRules, rule) {
+			return ParamsInvalidError(field, fmt.Sprintf("unknown rule, must be one of %s", strings.Join(LintRules(), ", ")))
+		}
+		if level := policy.Rules[rule]; level != LintOff && level != LintWarn && level != LintError {
+			return ParamsInvalidError(field, "must be either 'off', 'warn' or 'error'")
+		}
+	}
+
+	for _, label := range policy.RequiredLabels {
+		if strings.TrimSpace(label) == "" {
+			return ParamsInvalidError("lintPolicy.requiredLabels", "must not contain empty labels")
+		}
+	}
+
+	for label, values := range policy.AllowedLabelValues {
+		if len(values) == 0 {
+			return ParamsInvalidError(fmt.Sprintf("lintPolicy.allowedLabelValues.%s", label), "must allow at least one value")
+		}
+	}
+
+	if err := verifySize("lintPolicy.maxMemory", policy.MaxMemory); err != nil {
+		return err
+	}
+
+	if policy.FormatOrDefault() != LintFormatText && policy.FormatOrDefault() != LintFormatJSON {
+		return ParamsInvalidError("lintPolicy.format", "must be either 'text' or 'json'")
+	}
+	return nil
+}
+
+// LintRules returns the ids of the rules a policy can configure.
+func LintRules() []string {
+	return slices.Clone(lintRules)
+}
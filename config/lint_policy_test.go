@@ -0,0 +1,84 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestVerifyLintPolicy(t *testing.T) {
+	base := Params{
+		Command:      PUSH,
+		CliVersion:   "cf7",
+		ManifestPath: "path",
+		TestDomain:   "domain",
+		AppPath:      "path",
+		GitRefPath:   "path",
+		EAID:         "1",
+	}
+	assert.NoError(t, base.Verify(false))
+
+	unknownRule := base
+	unknownRule.LintPolicy = LintPolicy{Rules: map[string]LintLevel{"tabs": LintError}}
+	assert.Equal(t, ParamsInvalidError("lintPolicy.rules.tabs", "unknown rule, must be one of allowed-label-values, deprecated-stack, health-check-process-on-web, health-check-type, max-memory, missing-timeout, no-route, random-route, required-labels, underscore-in-hostname, unpinned-buildpack"), unknownRule.Verify(false))
+
+	unknownLevel := base
+	unknownLevel.LintPolicy = LintPolicy{Rules: map[string]LintLevel{LintRuleNoRoute: "fatal"}}
+	assert.Equal(t, ParamsInvalidError("lintPolicy.rules.no-route", "must be either 'off', 'warn' or 'error'"), unknownLevel.Verify(false))
+
+	emptyLabel := base
+	emptyLabel.LintPolicy = LintPolicy{RequiredLabels: []string{"product", " "}}
+	assert.Equal(t, ParamsInvalidError("lintPolicy.requiredLabels", "must not contain empty labels"), emptyLabel.Verify(false))
+
+	noAllowedValues := base
+	noAllowedValues.LintPolicy = LintPolicy{AllowedLabelValues: map[string][]string{"environment": {}}}
+	assert.Equal(t, ParamsInvalidError("lintPolicy.allowedLabelValues.environment", "must allow at least one value"), noAllowedValues.Verify(false))
+
+	invalidMemory := base
+	invalidMemory.LintPolicy = LintPolicy{MaxMemory: "lots"}
+	assert.Error(t, invalidMemory.Verify(false))
+
+	invalidFormat := base
+	invalidFormat.LintPolicy = LintPolicy{Format: "yaml"}
+	assert.Equal(t, ParamsInvalidError("lintPolicy.format", "must be either 'text' or 'json'"), invalidFormat.Verify(false))
+
+	allesOk := base
+	allesOk.LintPolicy = LintPolicy{
+		Rules:              map[string]LintLevel{LintRuleRequiredLabels: LintError, LintRuleHealthCheckType: LintWarn},
+		AllowedLabelValues: map[string][]string{"environment": {"dev", "live"}},
+		MaxMemory:          "2G",
+		Format:             LintFormatJSON,
+	}
+	assert.NoError(t, allesOk.Verify(false))
+}
+
+func TestLintPolicyDefaults(t *testing.T) {
+	policy := LintPolicy{}
+
+	assert.Equal(t, LintWarn, policy.Level(LintRuleRequiredLabels, LintWarn))
+	assert.Equal(t, LintOff, policy.Level(LintRuleHealthCheckType, LintOff))
+
+	policy.Rules = map[string]LintLevel{LintRuleHealthCheckType: LintError}
+	assert.Equal(t, LintError, policy.Level(LintRuleHealthCheckType, LintOff))
+	assert.Equal(t, DefaultRequiredLabels, policy.RequiredLabelsOrDefault())
+	assert.Equal(t, DefaultDeprecatedStacks, policy.DeprecatedStacksOrDefault())
+	assert.Equal(t, LintFormatText, policy.FormatOrDefault())
+	assert.Equal(t, 0, policy.MaxMemoryInMB())
+}
+
+func TestLintPolicyMerge(t *testing.T) {
+	file := LintPolicy{
+		Rules:          map[string]LintLevel{LintRuleRequiredLabels: LintWarn, LintRuleMaxMemory: LintError},
+		RequiredLabels: []string{"product"},
+		MaxMemory:      "1G",
+	}
+	params := LintPolicy{
+		Rules:     map[string]LintLevel{LintRuleRequiredLabels: LintError},
+		MaxMemory: "2G",
+	}
+
+	merged := file.Merge(params)
+	assert.Equal(t, map[string]LintLevel{LintRuleRequiredLabels: LintError, LintRuleMaxMemory: LintError}, merged.Rules)
+	assert.Equal(t, []string{"product"}, merged.RequiredLabels)
+	assert.Equal(t, "2G", merged.MaxMemory)
+	assert.Equal(t, map[string]LintLevel{LintRuleRequiredLabels: LintWarn, LintRuleMaxMemory: LintError}, file.Rules, "the file policy is not changed")
+}
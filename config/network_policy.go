@@ -0,0 +1,69 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultNetworkPolicyProtocol = "tcp"
+	DefaultNetworkPolicyPorts    = "8080"
+)
+
+// NetworkPolicy declares a container-to-container network policy between the candidate and another app in the space.
+// Exactly one of Source and Destination is set, the other end of the policy is the candidate.
+type NetworkPolicy struct {
+	Source      string `yaml:"source"`
+	Destination string `yaml:"destination"`
+	Protocol    string `yaml:"protocol"`
+	Ports       string `yaml:"ports"`
+}
+
+func (n NetworkPolicy) ProtocolOrDefault() string {
+	if n.Protocol == "" {
+		return DefaultNetworkPolicyProtocol
+	}
+	return n.Protocol
+}
+
+// PortRange returns the first and last port of Ports, which is either a single port like '8080' or a range like '8080-8090'.
+func (n NetworkPolicy) PortRange() (start int, end int, err error) {
+	ports := n.Ports
+	if ports == "" {
+		ports = DefaultNetworkPolicyPorts
+	}
+
+	parts := strings.SplitN(ports, "-", 2)
+	if start, err = strconv.Atoi(parts[0]); err != nil {
+		return
+	}
+	end = start
+	if len(parts) == 2 {
+		end, err = strconv.Atoi(parts[1])
+	}
+	return
+}
+
+func NetworkPolicyInvalidError(policy NetworkPolicy, reason string) error {
+	return errors.New(fmt.Sprintf("Network policy with source '%s' and destination '%s': %s", policy.Source, policy.Destination, reason))
+}
+
+func verifyNetworkPolicies(policies []NetworkPolicy) error {
+	for _, policy := range policies {
+		if (policy.Source == "") == (policy.Destination == "") {
+			return NetworkPolicyInvalidError(policy, "must contain exactly one of source and destination, the other end is the app being deployed")
+		}
+
+		if policy.ProtocolOrDefault() != "tcp" && policy.ProtocolOrDefault() != "udp" {
+			return NetworkPolicyInvalidError(policy, "protocol must be either 'tcp' or 'udp'")
+		}
+
+		start, end, err := policy.PortRange()
+		if err != nil || start < 1 || end > 65535 || start > end {
+			return NetworkPolicyInvalidError(policy, "ports must be a port like '8080' or a range like '8080-8090'")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestVerifyNetworkPolicies(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy NetworkPolicy
+		err    string
+	}{
+		{name: "destination with defaults", policy: NetworkPolicy{Destination: "backend"}},
+		{name: "source with port range", policy: NetworkPolicy{Source: "frontend", Protocol: "udp", Ports: "8080-8090"}},
+		{name: "neither source nor destination", policy: NetworkPolicy{}, err: "must contain exactly one of source and destination, the other end is the app being deployed"},
+		{name: "both source and destination", policy: NetworkPolicy{Source: "a", Destination: "b"}, err: "must contain exactly one of source and destination, the other end is the app being deployed"},
+		{name: "invalid protocol", policy: NetworkPolicy{Destination: "backend", Protocol: "icmp"}, err: "protocol must be either 'tcp' or 'udp'"},
+		{name: "invalid port", policy: NetworkPolicy{Destination: "backend", Ports: "http"}, err: "ports must be a port like '8080' or a range like '8080-8090'"},
+		{name: "port out of range", policy: NetworkPolicy{Destination: "backend", Ports: "70000"}, err: "ports must be a port like '8080' or a range like '8080-8090'"},
+		{name: "backwards range", policy: NetworkPolicy{Destination: "backend", Ports: "9000-8000"}, err: "ports must be a port like '8080' or a range like '8080-8090'"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := verifyNetworkPolicies([]NetworkPolicy{test.policy})
+			if test.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.Equal(t, NetworkPolicyInvalidError(test.policy, test.err), err)
+			}
+		})
+	}
+}
+
+func TestNetworkPolicyPortRange(t *testing.T) {
+	start, end, err := NetworkPolicy{}.PortRange()
+	assert.NoError(t, err)
+	assert.Equal(t, 8080, start)
+	assert.Equal(t, 8080, end)
+
+	start, end, err = NetworkPolicy{Ports: "9000-9010"}.PortRange()
+	assert.NoError(t, err)
+	assert.Equal(t, 9000, start)
+	assert.Equal(t, 9010, end)
+}
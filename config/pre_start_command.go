@@ -0,0 +1,148 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CandidateAppNamePlaceholder can be used in a preStartCommand and is replaced with the name of the candidate app.
+const CandidateAppNamePlaceholder = "$CANDIDATE_APP_NAME"
+
+// allowedPreStartSubCommands are the cf subcommands that are safe to run against the candidate before it is started.
+var allowedPreStartSubCommands = []string{
+	"add-network-policy",
+	"app",
+	"bind-route-service",
+	"bind-service",
+	"create-service",
+	"create-service-key",
+	"create-user-provided-service",
+	"env",
+	"events",
+	"service",
+	"services",
+	"set-env",
+	"set-health-check",
+	"unbind-service",
+	"unset-env",
+	"update-service",
+	"update-user-provided-service",
+}
+
+func PreStartCommandNotAllowedError(subCommand string) error {
+	return errors.New(fmt.Sprintf("invalid preStartCommand - 'cf %s' is not allowed, must be one of [%s]", subCommand, strings.Join(allowedPreStartSubCommands, ", ")))
+}
+
+func PreStartCommandParseError(preStartCommand string, reason string) error {
+	return errors.New(fmt.Sprintf("invalid preStartCommand - %s: '%s'", reason, preStartCommand))
+}
+
+// ParsePreStartCommand splits a preStartCommand into its sub commands and each sub command into words.
+// Sub commands are separated by an unquoted ';'. Words are separated by unquoted whitespace,
+// single quotes preserve everything literally and double quotes and backslashes work like in a POSIX shell.
+// Shell operators such as pipes and redirects are rejected as they would never be run by a shell anyway.
+func ParsePreStartCommand(preStartCommand string) (commands [][]string, err error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+
+	endWord := func() {
+		if inWord {
+			words = append(words, word.String())
+			word.Reset()
+			inWord = false
+		}
+	}
+
+	endCommand := func() {
+		endWord()
+		if len(words) > 0 {
+			commands = append(commands, words)
+			words = nil
+		}
+	}
+
+	runes := []rune(preStartCommand)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\':
+			if i+1 == len(runes) {
+				return nil, PreStartCommandParseError(preStartCommand, "trailing backslash")
+			}
+			i++
+			word.WriteRune(runes[i])
+			inWord = true
+		case r == '\'':
+			i++
+			closed := false
+			for ; i < len(runes); i++ {
+				if runes[i] == '\'' {
+					closed = true
+					break
+				}
+				word.WriteRune(runes[i])
+			}
+			if !closed {
+				return nil, PreStartCommandParseError(preStartCommand, "unterminated single quote")
+			}
+			inWord = true
+		case r == '"':
+			i++
+			closed := false
+			for ; i < len(runes); i++ {
+				if runes[i] == '"' {
+					closed = true
+					break
+				}
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune(`"\$`+"`", runes[i+1]) {
+					i++
+				}
+				word.WriteRune(runes[i])
+			}
+			if !closed {
+				return nil, PreStartCommandParseError(preStartCommand, "unterminated double quote")
+			}
+			inWord = true
+		case r == ';':
+			endCommand()
+		case r == ' ' || r == '\t' || r == '\n':
+			endWord()
+		case strings.ContainsRune("|&<>`", r):
+			return nil, PreStartCommandParseError(preStartCommand, fmt.Sprintf("unsupported shell operator '%c'", r))
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	endCommand()
+
+	return
+}
+
+func verifyPreStartCommand(preStartCommand string) error {
+	commands, err := ParsePreStartCommand(preStartCommand)
+	if err != nil {
+		return err
+	}
+
+	for _, command := range commands {
+		if command[0] != "cf" || len(command) < 2 {
+			return PreStartCommandError(preStartCommand)
+		}
+
+		allowed := false
+		for _, subCommand := range allowedPreStartSubCommands {
+			if command[1] == subCommand {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return PreStartCommandNotAllowedError(command[1])
+		}
+	}
+
+	return nil
+}
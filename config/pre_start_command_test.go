@@ -0,0 +1,90 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParsePreStartCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected [][]string
+	}{
+		{
+			name:     "single command",
+			input:    "cf events app",
+			expected: [][]string{{"cf", "events", "app"}},
+		},
+		{
+			name:     "multiple commands",
+			input:    "cf events app; cf env app",
+			expected: [][]string{{"cf", "events", "app"}, {"cf", "env", "app"}},
+		},
+		{
+			name:     "multiple commands without whitespace",
+			input:    "cf events app;cf env app",
+			expected: [][]string{{"cf", "events", "app"}, {"cf", "env", "app"}},
+		},
+		{
+			name:     "double quotes",
+			input:    `cf set-env app KEY "some value"`,
+			expected: [][]string{{"cf", "set-env", "app", "KEY", "some value"}},
+		},
+		{
+			name:     "single quotes are literal",
+			input:    `cf set-env app KEY 'a "quoted" \value; with semicolon'`,
+			expected: [][]string{{"cf", "set-env", "app", "KEY", `a "quoted" \value; with semicolon`}},
+		},
+		{
+			name:     "escapes",
+			input:    `cf set-env app KEY some\ value\;x "with \"escaped\" quotes"`,
+			expected: [][]string{{"cf", "set-env", "app", "KEY", "some value;x", `with "escaped" quotes`}},
+		},
+		{
+			name:     "empty commands are ignored",
+			input:    " ; cf events app ;; ",
+			expected: [][]string{{"cf", "events", "app"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			commands, err := ParsePreStartCommand(test.input)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, commands)
+		})
+	}
+}
+
+func TestParsePreStartCommandErrors(t *testing.T) {
+	for _, input := range []string{
+		`cf set-env app KEY "unterminated`,
+		`cf set-env app KEY 'unterminated`,
+		`cf set-env app KEY value\`,
+		`cf events app | grep something`,
+		`cf events app && rm -rf /`,
+		`cf events app > /tmp/file`,
+		"cf events `whoami`",
+	} {
+		t.Run(input, func(t *testing.T) {
+			_, err := ParsePreStartCommand(input)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestVerifyPreStartCommand(t *testing.T) {
+	t.Run("allowed", func(t *testing.T) {
+		assert.NoError(t, verifyPreStartCommand(`cf set-env $CANDIDATE_APP_NAME KEY "some value"; cf bind-service $CANDIDATE_APP_NAME db`))
+	})
+
+	t.Run("not a cf command", func(t *testing.T) {
+		assert.Equal(t, PreStartCommandError("cf events app; rm -rf /"), verifyPreStartCommand("cf events app; rm -rf /"))
+		assert.Equal(t, PreStartCommandError("cf;rm"), verifyPreStartCommand("cf;rm"))
+	})
+
+	t.Run("not an allowed cf command", func(t *testing.T) {
+		assert.Equal(t, PreStartCommandNotAllowedError("delete"), verifyPreStartCommand("cf events app; cf delete app -f"))
+	})
+}
@@ -3,9 +3,11 @@ package config
 import (
 	"errors"
 	"fmt"
-	"strings"
+	"time"
 )
 
+const DefaultTimeout = 15 * time.Minute
+
 type Request struct {
 	Source   Source
 	Params   Params
@@ -33,24 +35,54 @@ type Source struct {
 }
 
 type Params struct {
-	Command          string
-	ManifestPath     string
-	AppPath          string
-	TestDomain       string
-	Vars             map[string]string
-	GitRefPath       string
-	GitUri           string
-	BuildVersionPath string
-	Timeout          string
-	PreStartCommand  string
-	DockerUsername   string
-	DockerPassword   string
-	DockerTag        string
-	CliVersion       string
-	Instances        int
-	Team             string
-	EAID             string
-	SSOHost          string
+	Command            string
+	ManifestPath       string
+	AppPath            string
+	TestDomain         string
+	Vars               map[string]string
+	GitRefPath         string
+	GitUri             string
+	BuildVersionPath   string
+	Timeout            string
+	PreStartCommand    string
+	DockerUsername     string
+	DockerPassword     string
+	DockerTag          string
+	CliVersion         string
+	Instances          int
+	Team               string
+	EAID               string
+	SSOHost            string
+	SSOHosts           []string
+	SSOServiceName     string
+	SSORouteServiceURL string
+	SSODomain          string
+	SSOPath            string
+	SSOMode            string
+	Services           []Service
+	ServicesPath       string
+	KeepReleases       int
+	KeepReleasesMaxAge string
+	ProtectedHostnames []string
+	NetworkPolicies    []NetworkPolicy
+	Inherit            Inherit
+	Task               Task
+	StatusFormat       string
+	Scale              []ProcessScale
+	ScaleLimits        ScaleLimits
+	UnsetVars          []string
+	VarsPath           string
+	LintPolicy         LintPolicy
+	LintPolicyPath     string
+	Resume             bool
+}
+
+// TimeoutOrDefault is the timeout of each command in the plan.
+func (params Params) TimeoutOrDefault() (time.Duration, error) {
+	if params.Timeout == "" {
+		return DefaultTimeout, nil
+	}
+	return time.ParseDuration(params.Timeout)
 }
 
 func SourceMissingError(field string) error {
@@ -70,8 +102,11 @@ func PreStartCommandError(preStartCommand string) error {
 }
 
 func (r Request) Verify(isActions bool) error {
-	if err := r.Source.Verify(); err != nil {
-		return err
+	// Linting is done offline, so it does not need to know where to deploy to.
+	if r.Params.Command != LINT {
+		if err := r.Source.Verify(); err != nil {
+			return err
+		}
 	}
 
 	if err := r.Params.Verify(isActions); err != nil {
@@ -118,6 +153,53 @@ func (params Params) Verify(isActions bool) error {
 		return ParamsInvalidError("cliVersion", "must be either 'cf6', 'cf7' or 'cf8'")
 	}
 
+	if err := verifyServices(params.Services); err != nil {
+		return err
+	}
+
+	if err := params.verifyRetention(); err != nil {
+		return err
+	}
+
+	if err := verifyNetworkPolicies(params.NetworkPolicies); err != nil {
+		return err
+	}
+
+	if err := params.verifyInherit(); err != nil {
+		return err
+	}
+
+	if err := params.verifyTask(); err != nil {
+		return err
+	}
+
+	if err := params.verifyStatusFormat(); err != nil {
+		return err
+	}
+
+	if err := params.verifyScale(); err != nil {
+		return err
+	}
+
+	if err := params.verifyEnvChanges(); err != nil {
+		return err
+	}
+
+	if err := params.verifyLintPolicy(); err != nil {
+		return err
+	}
+
+	if err := params.verifyResume(); err != nil {
+		return err
+	}
+
+	// Every command that pushes the candidate runs the preStartCommand.
+	if (params.Command == PUSH || params.Command == ALL) && len(params.PreStartCommand) > 0 {
+		if err := verifyPreStartCommand(params.PreStartCommand); err != nil {
+			return err
+		}
+	}
+
 	switch params.Command {
 	case PUSH:
 		if params.TestDomain == "" {
@@ -134,10 +216,6 @@ func (params Params) Verify(isActions bool) error {
 			return ParamsMissingError("gitRefPath")
 		}
 
-		if len(params.PreStartCommand) > 0 && !strings.HasPrefix(params.PreStartCommand, "cf ") {
-			return PreStartCommandError(params.PreStartCommand)
-		}
-
 		if params.EAID == "" {
 			return ParamsMissingError("eaid")
 		}
@@ -150,8 +228,8 @@ func (params Params) Verify(isActions bool) error {
 			return ParamsMissingError("testDomain")
 		}
 	case SSO:
-		if params.SSOHost == "" {
-			return ParamsMissingError("ssoHost")
+		if err := params.verifySSO(); err != nil {
+			return err
 		}
 	}
 
@@ -8,9 +8,11 @@ import (
 	"io"
 	"net/url"
 	"path"
+	"strconv"
 	"strings"
 
 	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
 )
 
 type RequestReader struct {
@@ -57,18 +59,98 @@ func (r RequestReader) actionRequest() (request Request, err error) {
 		cliVersion = cv
 	}
 
+	keepReleases := 0
+	if kr := r.environ["INPUT_KEEPRELEASES"]; kr != "" {
+		if keepReleases, err = strconv.Atoi(kr); err != nil {
+			err = ParamsInvalidError("keepReleases", "must be a number")
+			return
+		}
+	}
+
 	request.Params = Params{
-		Command:        r.environ["INPUT_COMMAND"],
-		AppPath:        r.environ["INPUT_APPPATH"],
-		ManifestPath:   r.environ["INPUT_MANIFESTPATH"],
-		TestDomain:     r.environ["INPUT_TESTDOMAIN"],
-		DockerUsername: r.environ["INPUT_DOCKERUSERNAME"],
-		DockerPassword: string(dockerPassword),
-		DockerTag:      r.environ["INPUT_DOCKERTAG"],
-		CliVersion:     cliVersion,
-		SSOHost:        r.environ["INPUT_SSOHOST"],
-		Team:           r.environ["INPUT_TEAM"],
-		EAID:           r.environ["INPUT_EAID"],
+		Command:            r.environ["INPUT_COMMAND"],
+		AppPath:            r.environ["INPUT_APPPATH"],
+		ManifestPath:       r.environ["INPUT_MANIFESTPATH"],
+		TestDomain:         r.environ["INPUT_TESTDOMAIN"],
+		DockerUsername:     r.environ["INPUT_DOCKERUSERNAME"],
+		DockerPassword:     string(dockerPassword),
+		DockerTag:          r.environ["INPUT_DOCKERTAG"],
+		CliVersion:         cliVersion,
+		SSOHost:            r.environ["INPUT_SSOHOST"],
+		SSOServiceName:     r.environ["INPUT_SSOSERVICENAME"],
+		SSORouteServiceURL: r.environ["INPUT_SSOROUTESERVICEURL"],
+		SSODomain:          r.environ["INPUT_SSODOMAIN"],
+		SSOPath:            r.environ["INPUT_SSOPATH"],
+		SSOMode:            r.environ["INPUT_SSOMODE"],
+		Team:               r.environ["INPUT_TEAM"],
+		EAID:               r.environ["INPUT_EAID"],
+		ServicesPath:       r.environ["INPUT_SERVICESPATH"],
+		KeepReleases:       keepReleases,
+		KeepReleasesMaxAge: r.environ["INPUT_KEEPRELEASESMAXAGE"],
+		StatusFormat:       r.environ["INPUT_STATUSFORMAT"],
+		VarsPath:           r.environ["INPUT_VARSPATH"],
+		LintPolicyPath:     r.environ["INPUT_LINTPOLICYPATH"],
+		Resume:             r.environ["INPUT_RESUME"] == "true",
+	}
+
+	if hostnames := r.environ["INPUT_PROTECTEDHOSTNAMES"]; hostnames != "" {
+		for _, hostname := range strings.Split(hostnames, ",") {
+			request.Params.ProtectedHostnames = append(request.Params.ProtectedHostnames, strings.TrimSpace(hostname))
+		}
+	}
+
+	if hosts := r.environ["INPUT_SSOHOSTS"]; hosts != "" {
+		for _, host := range strings.Split(hosts, ",") {
+			request.Params.SSOHosts = append(request.Params.SSOHosts, strings.TrimSpace(host))
+		}
+	}
+
+	if unset := r.environ["INPUT_UNSETVARS"]; unset != "" {
+		for _, key := range strings.Split(unset, ",") {
+			request.Params.UnsetVars = append(request.Params.UnsetVars, strings.TrimSpace(key))
+		}
+	}
+
+	if policies := r.environ["INPUT_NETWORKPOLICIES"]; policies != "" {
+		if err = yaml.Unmarshal([]byte(policies), &request.Params.NetworkPolicies); err != nil {
+			err = ParamsInvalidError("networkPolicies", fmt.Sprintf("must be a list of network policies: %s", err))
+			return
+		}
+	}
+
+	if inherit := r.environ["INPUT_INHERIT"]; inherit != "" {
+		if err = yaml.Unmarshal([]byte(inherit), &request.Params.Inherit); err != nil {
+			err = ParamsInvalidError("inherit", fmt.Sprintf("must be a map with scale, env and services: %s", err))
+			return
+		}
+	}
+
+	if task := r.environ["INPUT_TASK"]; task != "" {
+		if err = yaml.Unmarshal([]byte(task), &request.Params.Task); err != nil {
+			err = ParamsInvalidError("task", fmt.Sprintf("must be a map with command, name, memory, disk and timeout: %s", err))
+			return
+		}
+	}
+
+	if scale := r.environ["INPUT_SCALE"]; scale != "" {
+		if err = yaml.Unmarshal([]byte(scale), &request.Params.Scale); err != nil {
+			err = ParamsInvalidError("scale", fmt.Sprintf("must be a list of process types with instances, memory and disk: %s", err))
+			return
+		}
+	}
+
+	if limits := r.environ["INPUT_SCALELIMITS"]; limits != "" {
+		if err = yaml.Unmarshal([]byte(limits), &request.Params.ScaleLimits); err != nil {
+			err = ParamsInvalidError("scaleLimits", fmt.Sprintf("must be a map with minInstances, maxInstances, maxMemory and maxDisk: %s", err))
+			return
+		}
+	}
+
+	if policy := r.environ["INPUT_LINTPOLICY"]; policy != "" {
+		if err = yaml.Unmarshal([]byte(policy), &request.Params.LintPolicy); err != nil {
+			err = ParamsInvalidError("lintPolicy", fmt.Sprintf("must be a map with rules, requiredLabels, allowedLabelValues, deprecatedStacks, maxMemory and format: %s", err))
+			return
+		}
 	}
 
 	request.Metadata.IsActions = true
@@ -124,6 +206,18 @@ func (r RequestReader) setFullPathInRequest(request Request) Request {
 		updatedRequest.Params.BuildVersionPath = path.Join(r.baseDir(), request.Params.BuildVersionPath)
 	}
 
+	if request.Params.ServicesPath != "" {
+		updatedRequest.Params.ServicesPath = path.Join(r.baseDir(), request.Params.ServicesPath)
+	}
+
+	if request.Params.VarsPath != "" {
+		updatedRequest.Params.VarsPath = path.Join(r.baseDir(), request.Params.VarsPath)
+	}
+
+	if request.Params.LintPolicyPath != "" {
+		updatedRequest.Params.LintPolicyPath = path.Join(r.baseDir(), request.Params.LintPolicyPath)
+	}
+
 	return updatedRequest
 }
 
@@ -192,6 +286,76 @@ func (r RequestReader) addVars(request Request) (updated Request) {
 	return
 }
 
+func (r RequestReader) addServices(request Request) (updated Request, err error) {
+	updated = request
+	if request.Params.ServicesPath == "" {
+		return
+	}
+
+	content, err := r.fs.ReadFile(request.Params.ServicesPath)
+	if err != nil {
+		return
+	}
+
+	var servicesFile ServicesFile
+	if err = yaml.Unmarshal(content, &servicesFile); err != nil {
+		return
+	}
+
+	updated.Params.Services = append(updated.Params.Services, servicesFile.Services...)
+	err = verifyServices(updated.Params.Services)
+	return
+}
+
+// addVarsFile adds the vars and unsets from Params.VarsPath, vars from the params win over the ones in the file.
+// The vars from CF_ENV_VAR_* are only known at this point, so the changes are verified again here.
+func (r RequestReader) addVarsFile(request Request) (updated Request, err error) {
+	updated = request
+	if request.Params.VarsPath == "" {
+		err = updated.Params.verifyEnvChanges()
+		return
+	}
+
+	content, err := r.fs.ReadFile(request.Params.VarsPath)
+	if err != nil {
+		return
+	}
+
+	var varsFile VarsFile
+	if err = yaml.Unmarshal(content, &varsFile); err != nil {
+		return
+	}
+
+	vars := make(map[string]string)
+	for k, v := range varsFile.Vars {
+		vars[k] = v
+	}
+	for k, v := range request.Params.Vars {
+		vars[k] = v
+	}
+	updated.Params.Vars = vars
+	updated.Params.UnsetVars = append(append([]string{}, request.Params.UnsetVars...), varsFile.Unset...)
+	err = updated.Params.verifyEnvChanges()
+	return
+}
+
+// addLintPolicyFile adds the lint policy from Params.LintPolicyPath, what is set in the params wins over the file.
+func (r RequestReader) addLintPolicyFile(request Request) (updated Request, err error) {
+	updated = request
+	if request.Params.LintPolicyPath == "" {
+		return
+	}
+
+	policy, err := ReadLintPolicyFile(r.fs, request.Params.LintPolicyPath)
+	if err != nil {
+		return
+	}
+
+	updated.Params.LintPolicy = policy.Merge(request.Params.LintPolicy)
+	err = updated.Params.verifyLintPolicy()
+	return
+}
+
 func (r RequestReader) addAppName(request Request) (updated Request, err error) {
 	updated = request
 	manifest, err := r.manifestReaderWrite.ReadManifest(request.Params.ManifestPath)
@@ -213,15 +377,17 @@ func (r RequestReader) ReadRequest() (request Request, err error) {
 		return request, nil
 	}
 
+	return r.CompleteRequest(request)
+}
+
+// CompleteRequest verifies a request and adds what is read from the environment and the files the params point to.
+// ReadRequest uses it for the request it parsed, callers that build the request themselves can use it directly.
+func (r RequestReader) CompleteRequest(parsed Request) (request Request, err error) {
+	request = parsed
 	if request.Params.CliVersion == "" {
 		request.Params.CliVersion = "cf7"
 	}
 
-	if request.Params.Command == SSO {
-		// We require cf8 because we need the `cf route` command
-		request.Params.CliVersion = "cf8"
-	}
-
 	if e := request.Verify(r.isActions()); e != nil {
 		err = e
 		return
@@ -235,6 +401,18 @@ func (r RequestReader) ReadRequest() (request Request, err error) {
 	if err != nil {
 		return
 	}
+	request, err = r.addServices(request)
+	if err != nil {
+		return
+	}
+	request, err = r.addVarsFile(request)
+	if err != nil {
+		return
+	}
+	request, err = r.addLintPolicyFile(request)
+	if err != nil {
+		return
+	}
 	request, err = r.addAppName(request)
 
 	return
@@ -161,6 +161,82 @@ func TestReadRequest(t *testing.T) {
 		})
 	})
 
+	t.Run("Action with network policies", func(t *testing.T) {
+		env := map[string]string{
+			"INPUT_API":          "api",
+			"INPUT_ORG":          "org",
+			"INPUT_SPACE":        "space",
+			"INPUT_USERNAME":     "username",
+			"INPUT_PASSWORD":     "password",
+			"INPUT_COMMAND":      "command",
+			"INPUT_MANIFESTPATH": "app/cf/manifest.yml",
+			"INPUT_APPPATH":      "app",
+			"GITHUB_WORKSPACE":   "/github/workspace",
+			"GITHUB_REPOSITORY":  "springernature/ee-test-actions",
+
+			"INPUT_NETWORKPOLICIES": `- destination: backend
+  ports: 8080-8090
+- source: frontend
+  protocol: udp`,
+		}
+		rr := NewRequestReader([]string{}, env, nil, afero.Afero{}, &okManifestReadWriter)
+		req, err := rr.ReadRequest()
+
+		assert.NoError(t, err)
+		assert.Equal(t, []NetworkPolicy{
+			{Destination: "backend", Ports: "8080-8090"},
+			{Source: "frontend", Protocol: "udp"},
+		}, req.Params.NetworkPolicies)
+	})
+
+	t.Run("Action with sso hosts", func(t *testing.T) {
+		env := map[string]string{
+			"INPUT_API":          "api",
+			"INPUT_ORG":          "org",
+			"INPUT_SPACE":        "space",
+			"INPUT_USERNAME":     "username",
+			"INPUT_PASSWORD":     "password",
+			"INPUT_COMMAND":      "halfpipe-sso",
+			"INPUT_MANIFESTPATH": "app/cf/manifest.yml",
+			"INPUT_APPPATH":      "app",
+			"GITHUB_WORKSPACE":   "/github/workspace",
+			"GITHUB_REPOSITORY":  "springernature/ee-test-actions",
+
+			"INPUT_SSOHOST":  "host1",
+			"INPUT_SSOHOSTS": "host2, host3",
+		}
+		rr := NewRequestReader([]string{}, env, nil, afero.Afero{}, &okManifestReadWriter)
+		req, err := rr.ReadRequest()
+
+		assert.NoError(t, err)
+		assert.Equal(t, "host1", req.Params.SSOHost)
+		assert.Equal(t, []string{"host2", "host3"}, req.Params.SSOHosts)
+	})
+
+	t.Run("Action with task", func(t *testing.T) {
+		env := map[string]string{
+			"INPUT_API":          "api",
+			"INPUT_ORG":          "org",
+			"INPUT_SPACE":        "space",
+			"INPUT_USERNAME":     "username",
+			"INPUT_PASSWORD":     "password",
+			"INPUT_COMMAND":      "halfpipe-run-task",
+			"INPUT_MANIFESTPATH": "app/cf/manifest.yml",
+			"INPUT_APPPATH":      "app",
+			"GITHUB_WORKSPACE":   "/github/workspace",
+			"GITHUB_REPOSITORY":  "springernature/ee-test-actions",
+
+			"INPUT_TASK": `command: bin/migrate up
+memory: 512M
+timeout: 5m`,
+		}
+		rr := NewRequestReader([]string{}, env, nil, afero.Afero{}, &okManifestReadWriter)
+		req, err := rr.ReadRequest()
+
+		assert.NoError(t, err)
+		assert.Equal(t, Task{Command: "bin/migrate up", Memory: "512M", Timeout: "5m"}, req.Params.Task)
+	})
+
 	t.Run("empty app path", func(t *testing.T) {
 		env := map[string]string{
 			"INPUT_API":          "api",
@@ -375,5 +451,225 @@ func TestReadRequest(t *testing.T) {
 				assert.NotEmpty(t, content)
 			})
 		})
+
+		t.Run("with services", func(t *testing.T) {
+			requestWithServices := `{
+   "source": {
+      "api":"api",
+      "org":"org",
+      "password":"password",
+      "space":"space",
+      "username":"username"
+   },
+   "params": {
+      "appPath":"git/app",
+      "command":"halfpipe-push",
+      "gitRefPath":"git/.git/ref",
+      "manifestPath":"git/app/cf/manifest-qa.yml",
+      "testDomain":"springernature.app",
+      "eaid": "eaid",
+      "servicesPath": "git/app/cf/services.yml",
+      "services": [
+        {"name": "my-db", "offering": "postgres", "plan": "small", "parameters": {"version": 16}}
+      ]
+   }
+}`
+			servicesFile := `services:
+- name: my-ups
+  credentials:
+    nested:
+      key: value
+  tags: [a, b]
+`
+
+			fs := afero.Afero{Fs: afero.NewMemMapFs()}
+			fs.WriteFile("/tmp/buildDir/git/.git/ref", []byte("ref"), 0777)
+			fs.WriteFile("/tmp/buildDir/git/app/cf/services.yml", []byte(servicesFile), 0777)
+			stdin := strings.NewReader(requestWithServices)
+			rr := NewRequestReader([]string{"/opt/resource/out", "/tmp/buildDir"}, env, stdin, fs, &okManifestReadWriter)
+
+			request, err := rr.ReadRequest()
+			assert.NoError(t, err)
+			assert.Equal(t, "/tmp/buildDir/git/app/cf/services.yml", request.Params.ServicesPath)
+			assert.Equal(t, []Service{
+				{Name: "my-db", Offering: "postgres", Plan: "small", Parameters: map[string]any{"version": float64(16)}},
+				{Name: "my-ups", Credentials: map[string]any{"nested": map[string]any{"key": "value"}}, Tags: []string{"a", "b"}},
+			}, request.Params.Services)
+		})
+
+		t.Run("with invalid services file", func(t *testing.T) {
+			requestWithServices := `{
+   "source": {
+      "api":"api",
+      "org":"org",
+      "password":"password",
+      "space":"space",
+      "username":"username"
+   },
+   "params": {
+      "appPath":"git/app",
+      "command":"halfpipe-push",
+      "gitRefPath":"git/.git/ref",
+      "manifestPath":"git/app/cf/manifest-qa.yml",
+      "testDomain":"springernature.app",
+      "eaid": "eaid",
+      "servicesPath": "git/app/cf/services.yml"
+   }
+}`
+
+			fs := afero.Afero{Fs: afero.NewMemMapFs()}
+			fs.WriteFile("/tmp/buildDir/git/.git/ref", []byte("ref"), 0777)
+			fs.WriteFile("/tmp/buildDir/git/app/cf/services.yml", []byte("services:\n- name: my-db\n  offering: postgres\n"), 0777)
+			stdin := strings.NewReader(requestWithServices)
+			rr := NewRequestReader([]string{"/opt/resource/out", "/tmp/buildDir"}, env, stdin, fs, &okManifestReadWriter)
+
+			_, err := rr.ReadRequest()
+			assert.Equal(t, ServiceInvalidError("my-db", "must contain plan when offering is set"), err)
+		})
+
+		t.Run("with vars file", func(t *testing.T) {
+			requestWithVars := `{
+   "source": {
+      "api":"api",
+      "org":"org",
+      "password":"password",
+      "space":"space",
+      "username":"username"
+   },
+   "params": {
+      "command":"halfpipe-set-env",
+      "manifestPath":"git/app/cf/manifest-qa.yml",
+      "varsPath": "git/app/cf/env.yml",
+      "vars": {"A": "from params"},
+      "unsetVars": ["C"]
+   }
+}`
+
+			fs := afero.Afero{Fs: afero.NewMemMapFs()}
+			fs.WriteFile("/tmp/buildDir/git/app/cf/env.yml", []byte("vars:\n  A: from file\n  B: b\nunset: [D]\n"), 0777)
+			stdin := strings.NewReader(requestWithVars)
+			rr := NewRequestReader([]string{"/opt/resource/out", "/tmp/buildDir"}, env, stdin, fs, &okManifestReadWriter)
+
+			request, err := rr.ReadRequest()
+			assert.NoError(t, err)
+			assert.Equal(t, "/tmp/buildDir/git/app/cf/env.yml", request.Params.VarsPath)
+			assert.Equal(t, map[string]string{"A": "from params", "B": "b"}, request.Params.Vars)
+			assert.Equal(t, []string{"C", "D"}, request.Params.UnsetVars)
+		})
+
+		t.Run("with lint policy file", func(t *testing.T) {
+			requestWithLintPolicy := `{
+   "source": {
+      "api":"api",
+      "org":"org",
+      "password":"password",
+      "space":"space",
+      "username":"username"
+   },
+   "params": {
+      "command":"halfpipe-check",
+      "manifestPath":"git/app/cf/manifest-qa.yml",
+      "lintPolicyPath": "git/lint-policy.yml",
+      "lintPolicy": {"rules": {"required-labels": "error"}}
+   }
+}`
+
+			fs := afero.Afero{Fs: afero.NewMemMapFs()}
+			fs.WriteFile("/tmp/buildDir/git/lint-policy.yml", []byte("lintPolicy:\n  rules:\n    required-labels: warn\n    max-memory: error\n  maxMemory: 2G\n"), 0777)
+			stdin := strings.NewReader(requestWithLintPolicy)
+			rr := NewRequestReader([]string{"/opt/resource/out", "/tmp/buildDir"}, env, stdin, fs, &okManifestReadWriter)
+
+			request, err := rr.ReadRequest()
+			assert.NoError(t, err)
+			assert.Equal(t, LintError, request.Params.LintPolicy.Level(LintRuleRequiredLabels, LintWarn))
+			assert.Equal(t, LintError, request.Params.LintPolicy.Level(LintRuleMaxMemory, LintWarn))
+			assert.Equal(t, 2048, request.Params.LintPolicy.MaxMemoryInMB())
+		})
+
+		t.Run("with invalid lint policy file", func(t *testing.T) {
+			requestWithLintPolicy := `{
+   "source": {
+      "api":"api",
+      "org":"org",
+      "password":"password",
+      "space":"space",
+      "username":"username"
+   },
+   "params": {
+      "command":"halfpipe-check",
+      "manifestPath":"git/app/cf/manifest-qa.yml",
+      "lintPolicyPath": "git/lint-policy.yml"
+   }
+}`
+
+			fs := afero.Afero{Fs: afero.NewMemMapFs()}
+			fs.WriteFile("/tmp/buildDir/git/lint-policy.yml", []byte("lintPolicy:\n  rules:\n    max-memory: fatal\n"), 0777)
+			stdin := strings.NewReader(requestWithLintPolicy)
+			rr := NewRequestReader([]string{"/opt/resource/out", "/tmp/buildDir"}, env, stdin, fs, &okManifestReadWriter)
+
+			_, err := rr.ReadRequest()
+			assert.Equal(t, ParamsInvalidError("lintPolicy.rules.max-memory", "must be either 'off', 'warn' or 'error'"), err)
+		})
+
+		t.Run("with a var that is both set and unset", func(t *testing.T) {
+			requestWithVars := `{
+   "source": {
+      "api":"api",
+      "org":"org",
+      "password":"password",
+      "space":"space",
+      "username":"username"
+   },
+   "params": {
+      "command":"halfpipe-set-env",
+      "manifestPath":"git/app/cf/manifest-qa.yml",
+      "varsPath": "git/app/cf/env.yml"
+   }
+}`
+
+			fs := afero.Afero{Fs: afero.NewMemMapFs()}
+			fs.WriteFile("/tmp/buildDir/git/app/cf/env.yml", []byte("vars:\n  A: a\nunset: [A]\n"), 0777)
+			stdin := strings.NewReader(requestWithVars)
+			rr := NewRequestReader([]string{"/opt/resource/out", "/tmp/buildDir"}, env, stdin, fs, &okManifestReadWriter)
+
+			_, err := rr.ReadRequest()
+			assert.Equal(t, ParamsInvalidError("unsetVars", "'A' cannot be both set in vars and unset"), err)
+		})
 	})
 }
+
+func TestCompleteRequest(t *testing.T) {
+	manifestReadWriter := ManifestReadWriteStub{
+		manifest: halfpipe_deploy_resource.ParseManifest(`applications:
+- name: AppUnderDeployment`),
+	}
+
+	fs := afero.Afero{Fs: afero.NewMemMapFs()}
+	fs.WriteFile("/home/me/app/.git/ref", []byte("ref\n"), 0777)
+
+	request := Request{
+		Source: Source{API: "api", Org: "org", Space: "space", Username: "username", Password: "password"},
+		Params: Params{
+			Command:      PUSH,
+			ManifestPath: "manifest.yml",
+			AppPath:      "/home/me/app",
+			TestDomain:   "domain.com",
+			GitRefPath:   "/home/me/app/.git/ref",
+			EAID:         "1",
+		},
+	}
+
+	rr := NewRequestReader([]string{"halfpipe-deploy", ""}, map[string]string{}, nil, fs, &manifestReadWriter)
+	completed, err := rr.CompleteRequest(request)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "cf7", completed.Params.CliVersion)
+	assert.Equal(t, "manifest.yml", completed.Params.ManifestPath, "relative paths stay relative to the working directory")
+	assert.Equal(t, "/home/me/app", completed.Params.AppPath)
+	assert.Equal(t, "ref", completed.Metadata.GitRef)
+	assert.Equal(t, "AppUnderDeployment", completed.Metadata.AppName)
+
+	request.Params.TestDomain = ""
+	_, err = rr.CompleteRequest(request)
+	assert.Equal(t, ParamsMissingError("testDomain"), err)
+}
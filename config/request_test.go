@@ -3,6 +3,7 @@ package config
 import (
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestVerifyErrorsIfNotAllSourceFieldsAreFilledOut(t *testing.T) {
@@ -190,12 +191,24 @@ func TestPreStartCommandForPush(t *testing.T) {
 			TestDomain:      "test.com",
 			AppPath:         "path",
 			GitRefPath:      "path",
-			PreStartCommand: "cf something good",
+			PreStartCommand: `cf set-env $CANDIDATE_APP_NAME KEY "some value"; cf bind-service $CANDIDATE_APP_NAME my-db`,
 			EAID:            "eaid",
 		}
 
 		assert.NoError(t, allesOk.Verify(false))
 	})
+
+	t.Run("Disallowed preStartCommand with halfpipe-all", func(t *testing.T) {
+		invalidParams := Params{
+			Command:         ALL,
+			CliVersion:      "cf6",
+			ManifestPath:    "path",
+			EAID:            "eaid",
+			PreStartCommand: "cf delete app -f",
+		}
+
+		assert.Equal(t, PreStartCommandNotAllowedError("delete"), invalidParams.Verify(false))
+	})
 }
 
 func TestVerifyItDoesntErrorIfAppPathIsEmptyButDockerSpecified(t *testing.T) {
@@ -211,3 +224,88 @@ func TestVerifyItDoesntErrorIfAppPathIsEmptyButDockerSpecified(t *testing.T) {
 	}
 	assert.Nil(t, allesOk.Verify(false))
 }
+
+func TestVerifySSO(t *testing.T) {
+	missingHost := Params{
+		Command:      SSO,
+		CliVersion:   "cf7",
+		ManifestPath: "path",
+	}
+	assert.Equal(t, ParamsMissingError("ssoHost"), missingHost.Verify(false))
+
+	invalidMode := Params{
+		Command:      SSO,
+		CliVersion:   "cf7",
+		ManifestPath: "path",
+		SSOHosts:     []string{"host"},
+		SSOMode:      "remove",
+	}
+	assert.Equal(t, ParamsInvalidError("ssoMode", "must be either 'bind' or 'unbind'"), invalidMode.Verify(false))
+
+	allesOk := Params{
+		Command:      SSO,
+		CliVersion:   "cf7",
+		ManifestPath: "path",
+		SSOHost:      "host",
+		SSOMode:      SSOModeUnbind,
+	}
+	assert.NoError(t, allesOk.Verify(false))
+}
+
+func TestVerifyRetention(t *testing.T) {
+	base := Params{
+		Command:      CLEANUP,
+		CliVersion:   "cf7",
+		ManifestPath: "path",
+	}
+
+	negative := base
+	negative.KeepReleases = -1
+	assert.Equal(t, ParamsInvalidError("keepReleases", "must not be negative"), negative.Verify(false))
+
+	invalidAge := base
+	invalidAge.KeepReleases = 1
+	invalidAge.KeepReleasesMaxAge = "a week"
+	assert.Equal(t, ParamsInvalidError("keepReleasesMaxAge", "must be a positive duration, e.g. '168h'"), invalidAge.Verify(false))
+
+	ageWithoutCount := base
+	ageWithoutCount.KeepReleasesMaxAge = "168h"
+	assert.NoError(t, ageWithoutCount.Verify(false))
+	assert.Equal(t, Retention{MaxAge: 168 * time.Hour}, ageWithoutCount.Retention())
+
+	allesOk := base
+	allesOk.KeepReleases = 3
+	allesOk.KeepReleasesMaxAge = "168h"
+	assert.NoError(t, allesOk.Verify(false))
+	assert.Equal(t, Retention{Count: 3, MaxAge: 168 * time.Hour}, allesOk.Retention())
+}
+
+func TestVerifyStatusFormat(t *testing.T) {
+	params := Params{
+		Command:      STATUS,
+		CliVersion:   "cf7",
+		ManifestPath: "path",
+	}
+	assert.NoError(t, params.Verify(false))
+	assert.Equal(t, StatusFormatText, params.StatusFormatOrDefault())
+
+	params.StatusFormat = "json"
+	assert.NoError(t, params.Verify(false))
+
+	params.StatusFormat = "yaml"
+	assert.Equal(t, ParamsInvalidError("statusFormat", "must be either 'text' or 'json'"), params.Verify(false))
+}
+
+func TestVerifyLintDoesNotNeedASource(t *testing.T) {
+	request := Request{
+		Params: Params{
+			Command:      LINT,
+			CliVersion:   "cf7",
+			ManifestPath: "path",
+		},
+	}
+	assert.NoError(t, request.Verify(false))
+
+	request.Params.Command = CHECK
+	assert.Equal(t, SourceMissingError("api"), request.Verify(false))
+}
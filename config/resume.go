@@ -0,0 +1,21 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ResumableCommands are the commands that record their progress in CF, so a failed execution can be resumed.
+var ResumableCommands = []string{PUSH, PROMOTE, CLEANUP, ROLLING_DEPLOY, ALL}
+
+func IsResumable(command string) bool {
+	return slices.Contains(ResumableCommands, command)
+}
+
+func (params Params) verifyResume() error {
+	if params.Resume && !IsResumable(params.Command) {
+		return ParamsInvalidError("resume", fmt.Sprintf("only %s can be resumed", strings.Join(ResumableCommands, ", ")))
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestVerifyResume(t *testing.T) {
+	promote := Params{
+		Command:      PROMOTE,
+		CliVersion:   "cf7",
+		ManifestPath: "path",
+		TestDomain:   "test.com",
+		Resume:       true,
+	}
+	assert.NoError(t, promote.Verify(false))
+
+	status := Params{
+		Command:      STATUS,
+		CliVersion:   "cf7",
+		ManifestPath: "path",
+		Resume:       true,
+	}
+	assert.Equal(t, ParamsInvalidError("resume", "only halfpipe-push, halfpipe-promote, halfpipe-cleanup, halfpipe-rolling-deploy, halfpipe-all can be resumed"), status.Verify(false))
+
+	status.Resume = false
+	assert.NoError(t, status.Verify(false))
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Retention decides how many previous releases, the <app>-DELETE apps, are kept for manual rollback.
+// The zero value keeps nothing.
+type Retention struct {
+	// Count is the number of most recently deployed previous releases to keep, zero with a MaxAge means any number.
+	Count int
+	// MaxAge is how long a previous release is kept for since it was deployed, zero means forever.
+	MaxAge time.Duration
+}
+
+func (r Retention) IsEmpty() bool {
+	return r.Count == 0 && r.MaxAge == 0
+}
+
+// LimitsCount is false when only the age of the previous releases decides which are kept.
+func (r Retention) LimitsCount() bool {
+	return r.Count > 0 || r.MaxAge == 0
+}
+
+func (r Retention) String() string {
+	switch {
+	case r.MaxAge == 0:
+		return fmt.Sprintf("keeping the %d most recent previous releases", r.Count)
+	case !r.LimitsCount():
+		return fmt.Sprintf("keeping the previous releases deployed within %s", r.MaxAge)
+	}
+	return fmt.Sprintf("keeping the %d most recent previous releases deployed within %s", r.Count, r.MaxAge)
+}
+
+func (params Params) Retention() Retention {
+	// The params have already been verified, so we know the duration parses.
+	maxAge, _ := time.ParseDuration(params.KeepReleasesMaxAge)
+	return Retention{
+		Count:  params.KeepReleases,
+		MaxAge: maxAge,
+	}
+}
+
+func (params Params) verifyRetention() error {
+	if params.KeepReleases < 0 {
+		return ParamsInvalidError("keepReleases", "must not be negative")
+	}
+
+	if params.KeepReleasesMaxAge != "" {
+		maxAge, err := time.ParseDuration(params.KeepReleasesMaxAge)
+		if err != nil || maxAge <= 0 {
+			return ParamsInvalidError("keepReleasesMaxAge", "must be a positive duration, e.g. '168h'")
+		}
+	}
+
+	return nil
+}
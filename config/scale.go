@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+)
+
+const DefaultScaleProcessType = "web"
+
+// ProcessScale is what halfpipe-scale sets on one process type of the live app, unset fields are left as they are.
+type ProcessScale struct {
+	Type      string `yaml:"type"`
+	Instances *int   `yaml:"instances"`
+	Memory    string `yaml:"memory"`
+	Disk      string `yaml:"disk"`
+}
+
+func (s ProcessScale) TypeOrDefault() string {
+	if s.Type == "" {
+		return DefaultScaleProcessType
+	}
+	return s.Type
+}
+
+// MemoryInMB and DiskInMB return 0 when not set.
+func (s ProcessScale) MemoryInMB() int {
+	// The params have already been verified, so we know the size parses.
+	mb, _ := ParseMegabytes(s.Memory)
+	return mb
+}
+
+func (s ProcessScale) DiskInMB() int {
+	mb, _ := ParseMegabytes(s.Disk)
+	return mb
+}
+
+func (s ProcessScale) String() string {
+	str := s.TypeOrDefault()
+	if s.Instances != nil {
+		str += fmt.Sprintf(" to %d instances", *s.Instances)
+	}
+	if s.Memory != "" {
+		str += fmt.Sprintf(" with %dMB memory", s.MemoryInMB())
+	}
+	if s.Disk != "" {
+		str += fmt.Sprintf(" with %dMB disk", s.DiskInMB())
+	}
+	return str
+}
+
+// ScaleLimits are the bounds halfpipe-scale must stay within, zero values are unbounded.
+type ScaleLimits struct {
+	MinInstances int    `yaml:"minInstances"`
+	MaxInstances int    `yaml:"maxInstances"`
+	MaxMemory    string `yaml:"maxMemory"`
+	MaxDisk      string `yaml:"maxDisk"`
+}
+
+func (params Params) verifyScale() error {
+	limits := params.ScaleLimits
+	if params.Command == SCALE && len(params.Scale) == 0 {
+		return ParamsMissingError("scale")
+	}
+
+	if limits.MinInstances < 0 || limits.MaxInstances < 0 {
+		return ParamsInvalidError("scaleLimits", "instances must not be negative")
+	}
+	if limits.MaxInstances != 0 && limits.MinInstances > limits.MaxInstances {
+		return ParamsInvalidError("scaleLimits", "minInstances must not be more than maxInstances")
+	}
+	if err := verifySize("scaleLimits.maxMemory", limits.MaxMemory); err != nil {
+		return err
+	}
+	if err := verifySize("scaleLimits.maxDisk", limits.MaxDisk); err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	for _, scale := range params.Scale {
+		field := fmt.Sprintf("scale.%s", scale.TypeOrDefault())
+		if seen[scale.TypeOrDefault()] {
+			return ParamsInvalidError(field, "process type must only be scaled once")
+		}
+		seen[scale.TypeOrDefault()] = true
+
+		if scale.Instances == nil && scale.Memory == "" && scale.Disk == "" {
+			return ParamsInvalidError(field, "must set at least one of instances, memory and disk")
+		}
+
+		if scale.Instances != nil {
+			if *scale.Instances < limits.MinInstances {
+				return ParamsInvalidError(field, fmt.Sprintf("instances must be at least %d", limits.MinInstances))
+			}
+			if limits.MaxInstances != 0 && *scale.Instances > limits.MaxInstances {
+				return ParamsInvalidError(field, fmt.Sprintf("instances must be at most %d", limits.MaxInstances))
+			}
+		}
+
+		if err := verifySize(field+".memory", scale.Memory); err != nil {
+			return err
+		}
+		if err := verifySize(field+".disk", scale.Disk); err != nil {
+			return err
+		}
+
+		if max, _ := ParseMegabytes(limits.MaxMemory); max != 0 && scale.MemoryInMB() > max {
+			return ParamsInvalidError(field, fmt.Sprintf("memory must be at most %dMB", max))
+		}
+		if max, _ := ParseMegabytes(limits.MaxDisk); max != 0 && scale.DiskInMB() > max {
+			return ParamsInvalidError(field, fmt.Sprintf("disk must be at most %dMB", max))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestVerifyScale(t *testing.T) {
+	instances := func(i int) *int {
+		return &i
+	}
+
+	base := Params{
+		Command:      SCALE,
+		CliVersion:   "cf7",
+		ManifestPath: "path",
+		ScaleLimits:  ScaleLimits{MinInstances: 2, MaxInstances: 10, MaxMemory: "2G"},
+	}
+
+	missing := base
+	assert.Equal(t, ParamsMissingError("scale"), missing.Verify(false))
+
+	nothingToScale := base
+	nothingToScale.Scale = []ProcessScale{{Type: "worker"}}
+	assert.Equal(t, ParamsInvalidError("scale.worker", "must set at least one of instances, memory and disk"), nothingToScale.Verify(false))
+
+	twice := base
+	twice.Scale = []ProcessScale{{Instances: instances(3)}, {Type: "web", Memory: "1G"}}
+	assert.Equal(t, ParamsInvalidError("scale.web", "process type must only be scaled once"), twice.Verify(false))
+
+	tooFew := base
+	tooFew.Scale = []ProcessScale{{Instances: instances(1)}}
+	assert.Equal(t, ParamsInvalidError("scale.web", "instances must be at least 2"), tooFew.Verify(false))
+
+	tooMany := base
+	tooMany.Scale = []ProcessScale{{Instances: instances(11)}}
+	assert.Equal(t, ParamsInvalidError("scale.web", "instances must be at most 10"), tooMany.Verify(false))
+
+	tooMuchMemory := base
+	tooMuchMemory.Scale = []ProcessScale{{Memory: "4G"}}
+	assert.Equal(t, ParamsInvalidError("scale.web", "memory must be at most 2048MB"), tooMuchMemory.Verify(false))
+
+	invalidDisk := base
+	invalidDisk.Scale = []ProcessScale{{Disk: "big"}}
+	assert.Equal(t, ParamsInvalidError("scale.web.disk", "must be a size like '512M' or '1G'"), invalidDisk.Verify(false))
+
+	invalidLimits := base
+	invalidLimits.ScaleLimits = ScaleLimits{MinInstances: 5, MaxInstances: 2}
+	invalidLimits.Scale = []ProcessScale{{Instances: instances(3)}}
+	assert.Equal(t, ParamsInvalidError("scaleLimits", "minInstances must not be more than maxInstances"), invalidLimits.Verify(false))
+
+	allesOk := base
+	allesOk.Scale = []ProcessScale{{Instances: instances(3), Memory: "1G"}, {Type: "worker", Disk: "4G"}}
+	assert.NoError(t, allesOk.Verify(false))
+
+	unbounded := base
+	unbounded.ScaleLimits = ScaleLimits{}
+	unbounded.Scale = []ProcessScale{{Instances: instances(0), Memory: "16G"}}
+	assert.NoError(t, unbounded.Verify(false))
+}
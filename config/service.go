@@ -0,0 +1,60 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Service describes a service instance that must exist in the space before the app is pushed.
+// If Offering is set it is a managed service instance, otherwise it is a user-provided service instance.
+type Service struct {
+	Name            string         `yaml:"name"`
+	Offering        string         `yaml:"offering"`
+	Plan            string         `yaml:"plan"`
+	Parameters      map[string]any `yaml:"parameters"`
+	Credentials     map[string]any `yaml:"credentials"`
+	RouteServiceURL string         `yaml:"routeServiceUrl"`
+	SyslogDrainURL  string         `yaml:"syslogDrainUrl"`
+	Tags            []string       `yaml:"tags"`
+}
+
+// ServicesFile is the format of the file pointed to by Params.ServicesPath.
+type ServicesFile struct {
+	Services []Service `yaml:"services"`
+}
+
+func (s Service) IsUserProvided() bool {
+	return s.Offering == ""
+}
+
+func ServiceInvalidError(name string, reason string) error {
+	return errors.New(fmt.Sprintf("Service '%s': %s", name, reason))
+}
+
+func verifyServices(services []Service) error {
+	seen := make(map[string]bool)
+	for _, service := range services {
+		if service.Name == "" {
+			return ServiceInvalidError(service.Name, "must contain name")
+		}
+
+		if seen[service.Name] {
+			return ServiceInvalidError(service.Name, "is defined more than once")
+		}
+		seen[service.Name] = true
+
+		if service.IsUserProvided() {
+			if service.Plan != "" || service.Parameters != nil {
+				return ServiceInvalidError(service.Name, "plan and parameters can only be used together with offering")
+			}
+		} else {
+			if service.Plan == "" {
+				return ServiceInvalidError(service.Name, "must contain plan when offering is set")
+			}
+			if service.Credentials != nil || service.RouteServiceURL != "" || service.SyslogDrainURL != "" {
+				return ServiceInvalidError(service.Name, "credentials, routeServiceUrl and syslogDrainUrl can only be used for user-provided services")
+			}
+		}
+	}
+	return nil
+}
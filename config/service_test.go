@@ -0,0 +1,50 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestVerifyServices(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		assert.NoError(t, verifyServices([]Service{
+			{Name: "db", Offering: "postgres", Plan: "small", Parameters: map[string]any{"a": "b"}},
+			{Name: "ups", Credentials: map[string]any{"a": "b"}},
+			{Name: "route-service", RouteServiceURL: "https://route.service"},
+		}))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		tests := map[string]struct {
+			services []Service
+			expected error
+		}{
+			"missing name": {
+				services: []Service{{Offering: "postgres", Plan: "small"}},
+				expected: ServiceInvalidError("", "must contain name"),
+			},
+			"duplicate": {
+				services: []Service{{Name: "a"}, {Name: "a"}},
+				expected: ServiceInvalidError("a", "is defined more than once"),
+			},
+			"managed without plan": {
+				services: []Service{{Name: "a", Offering: "postgres"}},
+				expected: ServiceInvalidError("a", "must contain plan when offering is set"),
+			},
+			"managed with credentials": {
+				services: []Service{{Name: "a", Offering: "postgres", Plan: "small", Credentials: map[string]any{}}},
+				expected: ServiceInvalidError("a", "credentials, routeServiceUrl and syslogDrainUrl can only be used for user-provided services"),
+			},
+			"user-provided with plan": {
+				services: []Service{{Name: "a", Plan: "small"}},
+				expected: ServiceInvalidError("a", "plan and parameters can only be used together with offering"),
+			},
+		}
+
+		for name, test := range tests {
+			t.Run(name, func(t *testing.T) {
+				assert.Equal(t, test.expected, verifyServices(test.services))
+			})
+		}
+	})
+}
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseMegabytes parses memory and disk sizes from a manifest, e.g. '512M', '1G' or '2GB'.
+func ParseMegabytes(size string) (int, error) {
+	s := strings.ToUpper(strings.TrimSpace(size))
+	s = strings.TrimSuffix(s, "B")
+
+	multiplier := 1
+	switch {
+	case strings.HasSuffix(s, "T"):
+		multiplier = 1024 * 1024
+	case strings.HasSuffix(s, "G"):
+		multiplier = 1024
+	case strings.HasSuffix(s, "M"):
+		multiplier = 1
+	default:
+		return 0, errors.New(fmt.Sprintf("invalid size '%s', must be a number followed by M, MB, G, GB, T or TB", size))
+	}
+
+	value, err := strconv.Atoi(strings.TrimSpace(s[:len(s)-1]))
+	if err != nil || value < 0 {
+		return 0, errors.New(fmt.Sprintf("invalid size '%s', must be a number followed by M, MB, G, GB, T or TB", size))
+	}
+	return value * multiplier, nil
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseMegabytes(t *testing.T) {
+	tests := []struct {
+		size     string
+		expected int
+		err      bool
+	}{
+		{"512M", 512, false},
+		{"512MB", 512, false},
+		{"1G", 1024, false},
+		{"2gb", 2048, false},
+		{"1T", 1024 * 1024, false},
+		{"512", 0, true},
+		{"lots", 0, true},
+		{"M", 0, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.size, func(t *testing.T) {
+			mb, err := ParseMegabytes(test.size)
+			if test.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.expected, mb)
+			}
+		})
+	}
+}
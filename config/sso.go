@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+const DefaultSSOServiceName = "sso"
+const DefaultSSORouteServiceURL = "https://ee-sso.public.springernature.app"
+const DefaultSSODomain = "public.springernature.app"
+
+const SSOModeBind = "bind"
+const SSOModeUnbind = "unbind"
+
+// SSOConfig is the route service configuration for the halfpipe-sso command with all defaults applied.
+type SSOConfig struct {
+	ServiceName     string
+	RouteServiceURL string
+	Domain          string
+	Hosts           []string
+	Path            string
+	Unbind          bool
+}
+
+// Route returns the route for the given host as it would be written in a manifest.
+func (s SSOConfig) Route(host string) string {
+	route := fmt.Sprintf("%s.%s", host, s.Domain)
+	if s.Path != "" {
+		route = fmt.Sprintf("%s/%s", route, strings.TrimPrefix(s.Path, "/"))
+	}
+	return route
+}
+
+func (params Params) SSOConfig() SSOConfig {
+	sso := SSOConfig{
+		ServiceName:     params.SSOServiceName,
+		RouteServiceURL: params.SSORouteServiceURL,
+		Domain:          params.SSODomain,
+		Path:            params.SSOPath,
+		Unbind:          params.SSOMode == SSOModeUnbind,
+	}
+
+	if sso.ServiceName == "" {
+		sso.ServiceName = DefaultSSOServiceName
+	}
+	if sso.RouteServiceURL == "" {
+		sso.RouteServiceURL = DefaultSSORouteServiceURL
+	}
+	if sso.Domain == "" {
+		sso.Domain = DefaultSSODomain
+	}
+	if sso.Path != "" && !strings.HasPrefix(sso.Path, "/") {
+		sso.Path = "/" + sso.Path
+	}
+
+	for _, host := range append(strings.Split(params.SSOHost, ","), params.SSOHosts...) {
+		if h := strings.TrimSpace(host); h != "" {
+			sso.Hosts = append(sso.Hosts, h)
+		}
+	}
+
+	return sso
+}
+
+func (params Params) verifySSO() error {
+	if len(params.SSOConfig().Hosts) == 0 {
+		return ParamsMissingError("ssoHost")
+	}
+
+	if params.SSOMode != "" && params.SSOMode != SSOModeBind && params.SSOMode != SSOModeUnbind {
+		return ParamsInvalidError("ssoMode", fmt.Sprintf("must be either '%s' or '%s'", SSOModeBind, SSOModeUnbind))
+	}
+
+	return nil
+}
@@ -0,0 +1,18 @@
+package config
+
+const StatusFormatText = "text"
+const StatusFormatJSON = "json"
+
+func (params Params) StatusFormatOrDefault() string {
+	if params.StatusFormat == "" {
+		return StatusFormatText
+	}
+	return params.StatusFormat
+}
+
+func (params Params) verifyStatusFormat() error {
+	if params.StatusFormatOrDefault() != StatusFormatText && params.StatusFormatOrDefault() != StatusFormatJSON {
+		return ParamsInvalidError("statusFormat", "must be either 'text' or 'json'")
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+const DefaultTaskName = "halfpipe-task"
+const DefaultTaskTimeout = 10 * time.Minute
+
+// Task is a one-off command, like a database migration, that is run on the droplet of the candidate before it is promoted.
+type Task struct {
+	Command string `yaml:"command"`
+	Name    string `yaml:"name"`
+	Memory  string `yaml:"memory"`
+	Disk    string `yaml:"disk"`
+	Timeout string `yaml:"timeout"`
+}
+
+func (t Task) IsEmpty() bool {
+	return t.Command == ""
+}
+
+func (t Task) NameOrDefault() string {
+	if t.Name == "" {
+		return DefaultTaskName
+	}
+	return t.Name
+}
+
+// MemoryInMB and DiskInMB return 0 when not set, which leaves it to CF to use the defaults of the app.
+func (t Task) MemoryInMB() int {
+	// The params have already been verified, so we know the size parses.
+	mb, _ := ParseMegabytes(t.Memory)
+	return mb
+}
+
+func (t Task) DiskInMB() int {
+	mb, _ := ParseMegabytes(t.Disk)
+	return mb
+}
+
+func (t Task) TimeoutOrDefault() time.Duration {
+	if t.Timeout == "" {
+		return DefaultTaskTimeout
+	}
+	timeout, _ := time.ParseDuration(t.Timeout)
+	return timeout
+}
+
+func (params Params) verifyTask() error {
+	task := params.Task
+	if params.Command == RUN_TASK && task.IsEmpty() {
+		return ParamsMissingError("task.command")
+	}
+
+	if task.IsEmpty() {
+		if task != (Task{}) {
+			return ParamsMissingError("task.command")
+		}
+		return nil
+	}
+
+	if strings.TrimSpace(task.Command) == "" {
+		return ParamsInvalidError("task.command", "must not be blank")
+	}
+
+	if err := verifySize("task.memory", task.Memory); err != nil {
+		return err
+	}
+
+	if err := verifySize("task.disk", task.Disk); err != nil {
+		return err
+	}
+
+	if task.Timeout != "" {
+		timeout, err := time.ParseDuration(task.Timeout)
+		if err != nil || timeout <= 0 {
+			return ParamsInvalidError("task.timeout", "must be a positive duration, e.g. '10m'")
+		}
+	}
+
+	// The task is waited for inside a single step of the plan, which is stopped after the timeout of the resource.
+	if params.Timeout != "" {
+		if timeout, err := time.ParseDuration(params.Timeout); err == nil && task.TimeoutOrDefault() >= timeout {
+			return ParamsInvalidError("task.timeout", "must be shorter than timeout")
+		}
+	}
+	return nil
+}
+
+func verifySize(field string, size string) error {
+	if size == "" {
+		return nil
+	}
+	if mb, err := ParseMegabytes(size); err != nil || mb <= 0 {
+		return ParamsInvalidError(field, "must be a size like '512M' or '1G'")
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestVerifyTask(t *testing.T) {
+	base := Params{
+		Command:      RUN_TASK,
+		CliVersion:   "cf7",
+		ManifestPath: "path",
+	}
+
+	missingCommand := base
+	assert.Equal(t, ParamsMissingError("task.command"), missingCommand.Verify(false))
+
+	missingCommandInAll := base
+	missingCommandInAll.Command = ALL
+	missingCommandInAll.EAID = "eaid"
+	missingCommandInAll.Task = Task{Memory: "1G"}
+	assert.Equal(t, ParamsMissingError("task.command"), missingCommandInAll.Verify(false))
+
+	invalidMemory := base
+	invalidMemory.Task = Task{Command: "migrate", Memory: "lots"}
+	assert.Equal(t, ParamsInvalidError("task.memory", "must be a size like '512M' or '1G'"), invalidMemory.Verify(false))
+
+	invalidDisk := base
+	invalidDisk.Task = Task{Command: "migrate", Disk: "0M"}
+	assert.Equal(t, ParamsInvalidError("task.disk", "must be a size like '512M' or '1G'"), invalidDisk.Verify(false))
+
+	invalidTimeout := base
+	invalidTimeout.Task = Task{Command: "migrate", Timeout: "soon"}
+	assert.Equal(t, ParamsInvalidError("task.timeout", "must be a positive duration, e.g. '10m'"), invalidTimeout.Verify(false))
+
+	timeoutTooLong := base
+	timeoutTooLong.Timeout = "20m"
+	timeoutTooLong.Task = Task{Command: "migrate", Timeout: "30m"}
+	assert.Equal(t, ParamsInvalidError("task.timeout", "must be shorter than timeout"), timeoutTooLong.Verify(false))
+
+	allesOk := base
+	allesOk.Task = Task{Command: "migrate", Memory: "512M", Disk: "1G", Timeout: "5m"}
+	assert.NoError(t, allesOk.Verify(false))
+	assert.Equal(t, 512, allesOk.Task.MemoryInMB())
+	assert.Equal(t, 1024, allesOk.Task.DiskInMB())
+	assert.Equal(t, 5*time.Minute, allesOk.Task.TimeoutOrDefault())
+	assert.Equal(t, DefaultTaskName, allesOk.Task.NameOrDefault())
+}
+
+func TestTaskDefaults(t *testing.T) {
+	task := Task{Command: "migrate"}
+
+	assert.Equal(t, 0, task.MemoryInMB())
+	assert.Equal(t, 0, task.DiskInMB())
+	assert.Equal(t, DefaultTaskTimeout, task.TimeoutOrDefault())
+	assert.True(t, Task{}.IsEmpty())
+}
@@ -0,0 +1,456 @@
+package fakecf
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/cli/util/manifestparser"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/google/uuid"
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/springernature/halfpipe-deploy-resource/logger"
+	"github.com/springernature/halfpipe-deploy-resource/manifest"
+	"github.com/springernature/halfpipe-deploy-resource/plan"
+)
+
+type executor struct {
+	server            *Server
+	manifestReadWrite manifest.ReaderWriter
+	logger            *logger.CapturingWriter
+	space             *resource.Space
+}
+
+// NewExecutor runs the cf commands of a plan against the state of the server, printing roughly what the cf CLI would.
+// The manifest of 'cf push' is read with the same ReaderWriter the planner wrote it with.
+func NewExecutor(server *Server, manifestReadWrite manifest.ReaderWriter, logger *logger.CapturingWriter) plan.Executor {
+	return &executor{
+		server:            server,
+		manifestReadWrite: manifestReadWrite,
+		logger:            logger,
+	}
+}
+
+func (e *executor) CliCommand(command plan.Command) (out []string, err error) {
+	if command.Cmd() != "cf" {
+		return nil, fmt.Errorf("fakecf only runs cf, not '%s'", command.Cmd())
+	}
+
+	args := command.Args()
+	e.server.mu.Lock()
+	e.server.executions = append(e.server.executions, strings.Join(args, " "))
+	failure := e.server.failing[strings.Join(args, " ")]
+	e.server.mu.Unlock()
+
+	if failure != nil {
+		out, err = []string{failure.Error()}, failure
+	} else {
+		out, err = e.run(args)
+	}
+
+	for _, line := range out {
+		e.logger.Println(line)
+	}
+	if err != nil {
+		e.logger.Println("FAILED")
+	}
+	return
+}
+
+func (e *executor) run(args []string) ([]string, error) {
+	if len(args) == 0 {
+		return nil, errors.New("no cf command")
+	}
+
+	if args[0] == "--version" {
+		return []string{"cf version 7.0.0+fakecf"}, nil
+	}
+	if args[0] == "login" {
+		return e.login(args[1:])
+	}
+	if e.space == nil {
+		return nil, errors.New("Not logged in. Use 'cf login' to log in.")
+	}
+
+	switch args[0] {
+	case "push":
+		return e.push(args[1:])
+	case "map-route":
+		return e.mapRoute(args[1:])
+	case "unmap-route":
+		return e.unmapRoute(args[1:])
+	case "start":
+		return e.start(args[1:])
+	case "stop":
+		return e.stop(args[1:])
+	case "rename":
+		return e.rename(args[1:])
+	case "delete":
+		return e.delete(args[1:])
+	case "logs":
+		return e.logs(args[1:])
+	}
+	return nil, fmt.Errorf("fakecf does not implement 'cf %s'", args[0])
+}
+
+func (e *executor) login(args []string) ([]string, error) {
+	_, flags := parseArgs(args, "-a", "-u", "-p", "-o", "-s")
+	if flags["-a"] != e.server.URL {
+		return nil, fmt.Errorf("Request error: Get \"%s\": no such host", flags["-a"])
+	}
+	if flags["-u"] != Username || flags["-p"] != Password {
+		return nil, errors.New("Credentials were rejected, please try again.")
+	}
+
+	e.server.mu.Lock()
+	defer e.server.mu.Unlock()
+	if flags["-o"] != e.server.org.Name {
+		return nil, fmt.Errorf("Organization '%s' not found.", flags["-o"])
+	}
+	space := e.server.findSpace(flags["-s"])
+	if space == nil {
+		return nil, fmt.Errorf("Space '%s' not found.", flags["-s"])
+	}
+	e.space = space
+	return []string{fmt.Sprintf("API endpoint: %s", e.server.URL), "Authenticating...", "OK", fmt.Sprintf("Targeted org %s.", e.server.org.Name), fmt.Sprintf("Targeted space %s.", space.Name)}, nil
+}
+
+// push stages and creates or updates the app from the manifest, it always behaves like '--no-route --no-start'.
+func (e *executor) push(args []string) ([]string, error) {
+	positional, flags := parseArgs(args, "-f", "-p", "-i", "--docker-image", "--docker-username")
+	if len(positional) != 1 || flags["-f"] == "" {
+		return nil, errors.New("fakecf only pushes a named app with a manifest")
+	}
+	name := positional[0]
+
+	man, err := e.manifestReadWrite.ReadManifest(flags["-f"])
+	if err != nil {
+		return nil, err
+	}
+	if len(man.Applications) == 0 {
+		return nil, fmt.Errorf("no applications in manifest '%s'", flags["-f"])
+	}
+	application := man.Applications[0]
+
+	processes, err := manifestProcesses(application, flags["-i"])
+	if err != nil {
+		return nil, err
+	}
+
+	s := e.server
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	app := s.findApp(e.space.GUID, name)
+	if app == nil {
+		app = s.createApp(e.space, name)
+	}
+	app.State = "STOPPED"
+	app.Lifecycle = resource.Lifecycle{Type: "buildpack", Data: &resource.BuildpackLifecycle{Stack: "cflinuxfs4"}}
+	if flags["--docker-image"] != "" {
+		app.Lifecycle = resource.Lifecycle{Type: "docker", Data: &resource.DockerLifecycle{Image: flags["--docker-image"]}}
+	}
+	for key, value := range manifestMetadata(application, "labels") {
+		app.Metadata.Labels[key] = &value
+	}
+	for key, value := range manifestMetadata(application, "annotations") {
+		app.Metadata.Annotations[key] = &value
+	}
+	app.UpdatedAt = s.now()
+	s.env[app.GUID] = manifestEnv(application)
+
+	s.processes = slices.DeleteFunc(s.processes, func(p *resource.Process) bool { return p.Relationships.App.Data.GUID == app.GUID })
+	for _, process := range processes {
+		process.Relationships.App = resource.ToOneRelationship{Data: &resource.Relationship{GUID: app.GUID}}
+		process.Resource = s.resource()
+		s.processes = append(s.processes, process)
+	}
+
+	droplet := &resource.Droplet{
+		State:         resource.DropletState(resource.DropletStateStaged),
+		Lifecycle:     app.Lifecycle,
+		Metadata:      newMetadata(),
+		Relationships: resource.AppRelationship{App: resource.ToOneRelationship{Data: &resource.Relationship{GUID: app.GUID}}},
+		Resource:      s.resource(),
+	}
+	s.droplets = append(s.droplets, droplet)
+	app.Relationships.CurrentDroplet = resource.ToOneRelationship{Data: &resource.Relationship{GUID: droplet.GUID}}
+
+	return []string{fmt.Sprintf("Pushing app %s to org %s / space %s as %s...", name, s.org.Name, e.space.Name, Username), "OK"}, nil
+}
+
+func (e *executor) mapRoute(args []string) ([]string, error) {
+	positional, flags := parseArgs(args, "--hostname", "-n", "--path", "--port", "--app-protocol")
+	if len(positional) != 2 {
+		return nil, errors.New("Incorrect Usage: the required arguments `APP_NAME` and `DOMAIN` were not provided")
+	}
+	host, path, port := routeFlags(flags)
+
+	s := e.server
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	app := s.findApp(e.space.GUID, positional[0])
+	if app == nil {
+		return nil, fmt.Errorf("App '%s' not found.", positional[0])
+	}
+	domain := s.findDomain(positional[1])
+	if domain == nil {
+		return nil, fmt.Errorf("Domain '%s' not found.", positional[1])
+	}
+
+	route := s.findRoute(domain, host, path, port)
+	if route == nil {
+		route = s.createRoute(e.space, domain, host, path, port)
+	}
+	if route.Relationships.Space.Data.GUID != e.space.GUID {
+		return nil, fmt.Errorf("Route '%s' already exists in another space.", route.URL)
+	}
+
+	out := []string{fmt.Sprintf("Mapping route %s to app %s in org %s / space %s as %s...", route.URL, app.Name, s.org.Name, e.space.Name, Username)}
+	if slices.ContainsFunc(route.Destinations, func(d resource.RouteDestination) bool { return *d.App.GUID == app.GUID }) {
+		out = append(out, fmt.Sprintf("App '%s' is already mapped to route '%s'. Nothing has been updated.", app.Name, route.URL))
+		return append(out, "OK"), nil
+	}
+
+	destinationGUID, appGUID, protocol := uuid.NewString(), app.GUID, "http1"
+	if flags["--app-protocol"] != "" {
+		protocol = flags["--app-protocol"]
+	}
+	route.Destinations = append(route.Destinations, resource.RouteDestination{
+		GUID:     &destinationGUID,
+		App:      resource.RouteDestinationApp{GUID: &appGUID, Process: &resource.RouteDestinationAppProcess{Type: "web"}},
+		Protocol: &protocol,
+	})
+	route.UpdatedAt = s.now()
+	return append(out, "OK"), nil
+}
+
+func (e *executor) unmapRoute(args []string) ([]string, error) {
+	positional, flags := parseArgs(args, "--hostname", "-n", "--path", "--port")
+	if len(positional) != 2 {
+		return nil, errors.New("Incorrect Usage: the required arguments `APP_NAME` and `DOMAIN` were not provided")
+	}
+	host, path, port := routeFlags(flags)
+
+	s := e.server
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	app := s.findApp(e.space.GUID, positional[0])
+	if app == nil {
+		return nil, fmt.Errorf("App '%s' not found.", positional[0])
+	}
+	domain := s.findDomain(positional[1])
+	if domain == nil {
+		return nil, fmt.Errorf("Domain '%s' not found.", positional[1])
+	}
+	route := s.findRoute(domain, host, path, port)
+	if route == nil {
+		return nil, fmt.Errorf("Route with host '%s', domain '%s' and path '%s' not found.", host, domain.Name, path)
+	}
+
+	out := []string{fmt.Sprintf("Removing route %s from app %s in org %s / space %s as %s...", route.URL, app.Name, s.org.Name, e.space.Name, Username)}
+	before := len(route.Destinations)
+	route.Destinations = slices.DeleteFunc(route.Destinations, func(d resource.RouteDestination) bool { return *d.App.GUID == app.GUID })
+	if len(route.Destinations) == before {
+		out = append(out, "Route to be unmapped is not currently mapped to the application.")
+	}
+	route.UpdatedAt = s.now()
+	return append(out, "OK"), nil
+}
+
+func (e *executor) start(args []string) ([]string, error) {
+	s := e.server
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	app, err := e.namedApp(args)
+	if err != nil {
+		return nil, err
+	}
+	s.setState(app, "STARTED")
+
+	out := []string{fmt.Sprintf("Starting app %s in org %s / space %s as %s...", app.Name, s.org.Name, e.space.Name, Username)}
+	if s.crashing[app.Name] {
+		out = append(out, "Start unsuccessful", "", fmt.Sprintf("TIP: use 'cf logs %s --recent' for more information", app.Name))
+		return out, errors.New("exit status 1")
+	}
+	return append(out, "", "state:   running"), nil
+}
+
+func (e *executor) stop(args []string) ([]string, error) {
+	s := e.server
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	app, err := e.namedApp(args)
+	if err != nil {
+		return nil, err
+	}
+	s.setState(app, "STOPPED")
+	return []string{fmt.Sprintf("Stopping app %s in org %s / space %s as %s...", app.Name, s.org.Name, e.space.Name, Username), "OK"}, nil
+}
+
+func (e *executor) rename(args []string) ([]string, error) {
+	if len(args) != 2 {
+		return nil, errors.New("Incorrect Usage: the required arguments `APP_NAME` and `NEW_APP_NAME` were not provided")
+	}
+
+	s := e.server
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	app, err := e.namedApp(args[:1])
+	if err != nil {
+		return nil, err
+	}
+	if s.findApp(e.space.GUID, args[1]) != nil {
+		return nil, fmt.Errorf("App with the name '%s' already exists.", args[1])
+	}
+	app.Name = args[1]
+	app.UpdatedAt = s.now()
+	return []string{fmt.Sprintf("Renaming app %s to %s in org %s / space %s as %s...", args[0], args[1], s.org.Name, e.space.Name, Username), "OK"}, nil
+}
+
+func (e *executor) delete(args []string) ([]string, error) {
+	positional, flags := parseArgs(args)
+	if len(positional) != 1 {
+		return nil, errors.New("Incorrect Usage: the required argument `APP_NAME` was not provided")
+	}
+	if _, forced := flags["-f"]; !forced {
+		return nil, errors.New("fakecf cannot answer the confirmation, delete with '-f'")
+	}
+
+	s := e.server
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := []string{fmt.Sprintf("Deleting app %s in org %s / space %s as %s...", positional[0], s.org.Name, e.space.Name, Username)}
+	app := s.findApp(e.space.GUID, positional[0])
+	if app == nil {
+		return append(out, fmt.Sprintf("App '%s' does not exist.", positional[0]), "OK"), nil
+	}
+	s.removeApp(app)
+	return append(out, "OK"), nil
+}
+
+func (e *executor) logs(args []string) ([]string, error) {
+	s := e.server
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	app, err := e.namedApp(args[:min(len(args), 1)])
+	if err != nil {
+		return nil, err
+	}
+	return []string{fmt.Sprintf("Retrieving logs for app %s in org %s / space %s as %s...", app.Name, s.org.Name, e.space.Name, Username)}, nil
+}
+
+// namedApp finds the app named by the first arg in the targeted space, the lock must be held.
+func (e *executor) namedApp(args []string) (*resource.App, error) {
+	if len(args) == 0 {
+		return nil, errors.New("Incorrect Usage: the required argument `APP_NAME` was not provided")
+	}
+	app := e.server.findApp(e.space.GUID, args[0])
+	if app == nil {
+		return nil, fmt.Errorf("App '%s' not found.", args[0])
+	}
+	return app, nil
+}
+
+// parseArgs splits the args in positional args and flags, the flags in withValue take the next arg as their value.
+func parseArgs(args []string, withValue ...string) (positional []string, flags map[string]string) {
+	flags = map[string]string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case slices.Contains(withValue, arg) && i+1 < len(args):
+			flags[arg] = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "-"):
+			flags[arg] = ""
+		default:
+			positional = append(positional, arg)
+		}
+	}
+	return
+}
+
+func routeFlags(flags map[string]string) (host, path string, port int) {
+	host = flags["--hostname"]
+	if host == "" {
+		host = flags["-n"]
+	}
+	if flags["--path"] != "" {
+		path = "/" + strings.TrimPrefix(flags["--path"], "/")
+	}
+	port, _ = strconv.Atoi(flags["--port"])
+	return
+}
+
+// manifestProcesses are the processes cf push creates, the top level memory and instances belong to the web process.
+func manifestProcesses(application manifestparser.Application, instances string) (processes []*resource.Process, err error) {
+	web := manifestparser.Process{Type: "web", Memory: application.Memory, DiskQuota: application.DiskQuota, Instances: application.Instances}
+	all := []manifestparser.Process{web}
+	for _, process := range application.Processes {
+		if process.Type == "web" {
+			all[0] = process
+			continue
+		}
+		all = append(all, process)
+	}
+	if instances != "" {
+		i, err := strconv.Atoi(instances)
+		if err != nil {
+			return nil, fmt.Errorf("Incorrect Usage: invalid argument for flag `-i' (expected int)")
+		}
+		all[0].Instances = &i
+	}
+
+	for _, process := range all {
+		p := &resource.Process{Type: process.Type, Instances: 1, MemoryInMB: 1024, DiskInMB: 1024, HealthCheck: resource.ProcessHealthCheck{Type: "port"}}
+		if process.Instances != nil {
+			p.Instances = *process.Instances
+		}
+		if process.Memory != "" {
+			if p.MemoryInMB, err = config.ParseMegabytes(process.Memory); err != nil {
+				return
+			}
+		}
+		if process.DiskQuota != "" {
+			if p.DiskInMB, err = config.ParseMegabytes(process.DiskQuota); err != nil {
+				return
+			}
+		}
+		if process.HealthCheckType != "" {
+			p.HealthCheck.Type = string(process.HealthCheckType)
+		}
+		processes = append(processes, p)
+	}
+	return
+}
+
+func manifestEnv(application manifestparser.Application) map[string]*string {
+	env := map[string]*string{}
+	if raw, ok := application.RemainingManifestFields["env"].(map[any]any); ok {
+		for key, value := range raw {
+			v := fmt.Sprint(value)
+			env[fmt.Sprint(key)] = &v
+		}
+	}
+	return env
+}
+
+func manifestMetadata(application manifestparser.Application, kind string) map[string]string {
+	values := map[string]string{}
+	metadata, _ := application.RemainingManifestFields["metadata"].(map[any]any)
+	if raw, ok := metadata[kind].(map[any]any); ok {
+		for key, value := range raw {
+			values[fmt.Sprint(key)] = fmt.Sprint(value)
+		}
+	}
+	return values
+}
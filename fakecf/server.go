@@ -0,0 +1,1059 @@
+// Package fakecf is an in-process fake of Cloud Foundry for end-to-end tests of the plans.
+//
+// Server fakes the parts of the CF v3 API, UAA and the network policy API that the client commands use,
+// and Executor applies the cf CLI commands of a plan to the same state, so that whole
+// push, check, promote and cleanup cycles run in go test without a real CF.
+package fakecf
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/google/uuid"
+	"github.com/springernature/halfpipe-deploy-resource/config"
+)
+
+const (
+	Username = "admin"
+	Password = "admin"
+
+	accessToken = "fakecf-token"
+)
+
+// networkPolicy is a policy as the policy server API returns and accepts it.
+type networkPolicy struct {
+	Source struct {
+		ID string `json:"id"`
+	} `json:"source"`
+	Destination struct {
+		ID       string `json:"id"`
+		Protocol string `json:"protocol"`
+		Ports    struct {
+			Start int `json:"start"`
+			End   int `json:"end"`
+		} `json:"ports"`
+	} `json:"destination"`
+}
+
+// Server keeps one org with its spaces, domains, apps, processes, droplets and routes in memory.
+// Everything the resource creates is created in the space it was created for, the other spaces are only there
+// to have routes that belong to another space.
+type Server struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	last       time.Time
+	org        *resource.Organization
+	space      *resource.Space
+	spaces     []*resource.Space
+	domains    []*resource.Domain
+	apps       []*resource.App
+	env        map[string]map[string]*string
+	processes  []*resource.Process
+	droplets   []*resource.Droplet
+	routes     []*resource.Route
+	policies   []networkPolicy
+	crashing   map[string]bool
+	failing    map[string]error
+	executions []string
+	// withoutNetworkPolicyAPI is a foundation whose root has no network_policy_v1 link.
+	withoutNetworkPolicyAPI bool
+	// spaceUpdatesRejected is a user that is a SpaceDeveloper, who cannot update the space.
+	spaceUpdatesRejected bool
+
+	servicePlans     []servicePlan
+	serviceInstances []*resource.ServiceInstance
+	routeBindings    []*resource.ServiceRouteBinding
+	brokerFailures   map[string]string
+	jobs             map[string]*job
+}
+
+// New starts a server with the org and the space the requests deploy to. Close it when done.
+func New(org, space string) *Server {
+	s := &Server{
+		env:            map[string]map[string]*string{},
+		crashing:       map[string]bool{},
+		failing:        map[string]error{},
+		brokerFailures: map[string]string{},
+		jobs:           map[string]*job{},
+	}
+	s.org = &resource.Organization{Name: org, Resource: s.resource()}
+	s.AddSpace(space)
+	s.space = s.spaces[0]
+	s.Server = httptest.NewServer(s.handler())
+	return s
+}
+
+// Source is the source of a request that deploys to the org and space of the server.
+func (s *Server) Source() config.Source {
+	return config.Source{
+		API:      s.URL,
+		Org:      s.org.Name,
+		Space:    s.space.Name,
+		Username: Username,
+		Password: Password,
+	}
+}
+
+// AddSpace adds another space to the org.
+func (s *Server) AddSpace(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.spaces = append(s.spaces, &resource.Space{
+		Name: name,
+		Relationships: &resource.SpaceRelationships{
+			Organization: relationship(s.org.GUID),
+		},
+		Metadata: newMetadata(),
+		Resource: s.resource(),
+	})
+}
+
+// SetSpaceLabel sets a metadata label on the space the requests deploy to.
+func (s *Server) SetSpaceLabel(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.space.Metadata.SetLabel("", key, value)
+}
+
+// AddSharedDomain adds a domain that is shared with every org.
+func (s *Server) AddSharedDomain(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.domains = append(s.domains, &resource.Domain{Name: name, SupportedProtocols: []string{"http"}, Resource: s.resource()})
+}
+
+// AddPrivateDomain adds a domain that is private to the org.
+func (s *Server) AddPrivateDomain(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.domains = append(s.domains, &resource.Domain{
+		Name:               name,
+		SupportedProtocols: []string{"http"},
+		Relationships:      resource.DomainRelationships{Organization: relationship(s.org.GUID)},
+		Resource:           s.resource(),
+	})
+}
+
+// AddRoute adds a route without destinations to a space, e.g. to have a route that belongs to another space.
+func (s *Server) AddRoute(space, host, domain string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sp := s.findSpace(space)
+	if sp == nil {
+		return fmt.Errorf("space '%s' not found", space)
+	}
+	d := s.findDomain(domain)
+	if d == nil {
+		return fmt.Errorf("domain '%s' not found", domain)
+	}
+	s.createRoute(sp, d, host, "", 0)
+	return nil
+}
+
+// AddApp adds a stopped app that has never been staged, like a candidate whose push failed before it was staged.
+func (s *Server) AddApp(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.createApp(s.space, name)
+}
+
+// CrashOnStart makes the instances of the app crash the next times it is started.
+func (s *Server) CrashOnStart(app string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.crashing[app] = true
+}
+
+// FailOn makes the cf command with the given args fail with err, e.g. FailOn(err, "rename", "my-app", "my-app-OLD").
+// It keeps failing until it is cleared with a nil err.
+func (s *Server) FailOn(err error, args ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := strings.Join(args, " ")
+	if err == nil {
+		delete(s.failing, key)
+		return
+	}
+	s.failing[key] = err
+}
+
+// AddNetworkPolicy adds a tcp network policy on port 8080 between two apps in the space the requests deploy to.
+func (s *Server) AddNetworkPolicy(source, destination string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	src, dst := s.findApp(s.space.GUID, source), s.findApp(s.space.GUID, destination)
+	if src == nil || dst == nil {
+		return fmt.Errorf("apps '%s' and '%s' must exist", source, destination)
+	}
+	var policy networkPolicy
+	policy.Source.ID = src.GUID
+	policy.Destination.ID = dst.GUID
+	policy.Destination.Protocol = "tcp"
+	policy.Destination.Ports.Start = 8080
+	policy.Destination.Ports.End = 8080
+	s.policies = append(s.policies, policy)
+	return nil
+}
+
+// NetworkPolicies are the network policies between the apps, as 'source -> destination', sorted.
+func (s *Server) NetworkPolicies() (policies []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := func(guid string) string {
+		if app := s.findAppByGUID(guid); app != nil {
+			return app.Name
+		}
+		return guid
+	}
+	for _, policy := range s.policies {
+		policies = append(policies, fmt.Sprintf("%s -> %s", name(policy.Source.ID), name(policy.Destination.ID)))
+	}
+	slices.Sort(policies)
+	return
+}
+
+// WithoutNetworkPolicyAPI makes the server a foundation without the network policy API.
+func (s *Server) WithoutNetworkPolicyAPI() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.withoutNetworkPolicyAPI = true
+}
+
+// RejectSpaceUpdates makes updates of spaces fail like they do for a SpaceDeveloper.
+func (s *Server) RejectSpaceUpdates() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.spaceUpdatesRejected = true
+}
+
+// Executions are the cf commands the executors have run, without 'cf' and in order.
+func (s *Server) Executions() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.executions)
+}
+
+// SpaceAnnotations returns the metadata annotations of the space the requests deploy to.
+func (s *Server) SpaceAnnotations() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	annotations := map[string]string{}
+	for key, value := range s.space.Metadata.Annotations {
+		annotations[key] = *value
+	}
+	return annotations
+}
+
+// AppAnnotations returns the metadata annotations of the app in the space the requests deploy to.
+func (s *Server) AppAnnotations(name string) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	annotations := map[string]string{}
+	if app := s.findApp(s.space.GUID, name); app != nil {
+		for key, value := range app.Metadata.Annotations {
+			annotations[key] = *value
+		}
+	}
+	return annotations
+}
+
+// Apps are the apps in the space the requests deploy to, sorted by name.
+func (s *Server) Apps() (apps []resource.App) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, app := range s.appsInSpace(s.space.GUID) {
+		apps = append(apps, *app)
+	}
+	slices.SortFunc(apps, func(a, b resource.App) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return
+}
+
+// AppNames are the names of the apps in the space the requests deploy to, sorted.
+func (s *Server) AppNames() (names []string) {
+	for _, app := range s.Apps() {
+		names = append(names, app.Name)
+	}
+	return
+}
+
+// App is the app with the name in the space the requests deploy to, nil if there is none.
+func (s *Server) App(name string) *resource.App {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if app := s.findApp(s.space.GUID, name); app != nil {
+		copied := *app
+		return &copied
+	}
+	return nil
+}
+
+// Routes are the urls of the routes mapped to the app, sorted.
+func (s *Server) Routes(app string) (urls []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := s.findApp(s.space.GUID, app)
+	if a == nil {
+		return
+	}
+	for _, route := range s.routesForApp(a.GUID) {
+		urls = append(urls, route.URL)
+	}
+	slices.Sort(urls)
+	return
+}
+
+// RouteURLs are the urls of all the routes in the space the requests deploy to, sorted.
+func (s *Server) RouteURLs() (urls []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, route := range s.routes {
+		if route.Relationships.Space.Data.GUID == s.space.GUID {
+			urls = append(urls, route.URL)
+		}
+	}
+	slices.Sort(urls)
+	return
+}
+
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.getRoot)
+	mux.HandleFunc("POST /oauth/token", s.postToken)
+
+	mux.HandleFunc("GET /v3/organizations", s.listOrganizations)
+	mux.HandleFunc("GET /v3/organizations/{guid}/domains", s.listOrganizationDomains)
+	mux.HandleFunc("GET /v3/organizations/{guid}/usage_summary", s.getOrganizationUsageSummary)
+	mux.HandleFunc("GET /v3/spaces", s.listSpaces)
+	mux.HandleFunc("PATCH /v3/spaces/{guid}", s.updateSpace)
+	mux.HandleFunc("GET /v3/spaces/{guid}/usage_summary", s.getSpaceUsageSummary)
+	mux.HandleFunc("GET /v3/domains", s.listDomains)
+	mux.HandleFunc("GET /v3/domains/{guid}/route_reservations", s.getRouteReservations)
+
+	mux.HandleFunc("GET /v3/apps", s.listApps)
+	mux.HandleFunc("PATCH /v3/apps/{guid}", s.updateApp)
+	mux.HandleFunc("DELETE /v3/apps/{guid}", s.deleteApp)
+	mux.HandleFunc("POST /v3/apps/{guid}/actions/start", s.startApp)
+	mux.HandleFunc("POST /v3/apps/{guid}/actions/stop", s.stopApp)
+	mux.HandleFunc("GET /v3/apps/{guid}/environment_variables", s.getAppEnvironmentVariables)
+	mux.HandleFunc("GET /v3/apps/{guid}/processes", s.listAppProcesses)
+	mux.HandleFunc("GET /v3/apps/{guid}/processes/{type}/stats", s.getAppProcessStats)
+	mux.HandleFunc("GET /v3/apps/{guid}/relationships/current_droplet", s.getAppCurrentDroplet)
+	mux.HandleFunc("GET /v3/apps/{guid}/routes", s.listAppRoutes)
+	mux.HandleFunc("PATCH /v3/droplets/{guid}", s.updateDroplet)
+
+	mux.HandleFunc("GET /v3/routes", s.listRoutes)
+	mux.HandleFunc("POST /v3/routes", s.postRoute)
+	mux.HandleFunc("DELETE /v3/routes/{guid}", s.deleteRoute)
+	mux.HandleFunc("GET /v3/jobs/{guid}", s.getJob)
+	mux.HandleFunc("GET /v3/service_plans", s.listServicePlans)
+	mux.HandleFunc("GET /v3/service_instances", s.listServiceInstances)
+	mux.HandleFunc("POST /v3/service_instances", s.createServiceInstance)
+	mux.HandleFunc("GET /v3/service_instances/{guid}", s.getServiceInstance)
+	mux.HandleFunc("PATCH /v3/service_instances/{guid}", s.updateServiceInstance)
+	mux.HandleFunc("GET /v3/service_route_bindings", s.listServiceRouteBindings)
+	mux.HandleFunc("POST /v3/service_route_bindings", s.createServiceRouteBinding)
+	mux.HandleFunc("DELETE /v3/service_route_bindings/{guid}", s.deleteServiceRouteBinding)
+
+	mux.HandleFunc("GET /networking/v1/external/policies", s.listNetworkPolicies)
+	mux.HandleFunc("POST /networking/v1/external/policies", s.createNetworkPolicies)
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "CF-NotFound", fmt.Sprintf("fakecf does not implement %s %s", r.Method, r.URL.Path))
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" && r.URL.Path != "/oauth/token" && r.Header.Get("Authorization") != "Bearer "+accessToken {
+			writeError(w, http.StatusUnauthorized, "CF-InvalidAuthToken", "Invalid Auth Token")
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) getRoot(w http.ResponseWriter, _ *http.Request) {
+	root := resource.Root{}
+	root.Links.Self.Href = s.URL
+	root.Links.CloudControllerV3.Href = s.URL + "/v3"
+	root.Links.Login.Href = s.URL
+	root.Links.Uaa.Href = s.URL
+	if !s.withoutNetworkPolicyAPI {
+		root.Links.NetworkPolicyV1.Href = s.URL + "/networking/v1/external"
+	}
+	writeJSON(w, http.StatusOK, root)
+}
+
+func (s *Server) postToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("username") != Username || r.PostForm.Get("password") != Password {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized", "error_description": "Bad credentials"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token":  accessToken,
+		"refresh_token": accessToken,
+		"token_type":    "bearer",
+		"expires_in":    3600,
+	})
+}
+
+func (s *Server) listOrganizations(w http.ResponseWriter, r *http.Request) {
+	var orgs []*resource.Organization
+	if matches(filter(r, "names"), s.org.Name) && matches(filter(r, "guids"), s.org.GUID) {
+		orgs = append(orgs, s.org)
+	}
+	writeList(w, orgs)
+}
+
+func (s *Server) listOrganizationDomains(w http.ResponseWriter, r *http.Request) {
+	if r.PathValue("guid") != s.org.GUID {
+		writeNotFound(w, "Organization")
+		return
+	}
+	s.writeDomains(w, r)
+}
+
+func (s *Server) getOrganizationUsageSummary(w http.ResponseWriter, r *http.Request) {
+	if r.PathValue("guid") != s.org.GUID {
+		writeNotFound(w, "Organization")
+		return
+	}
+	writeJSON(w, http.StatusOK, resource.OrganizationUsageSummary{UsageSummary: s.usage(func(string) bool { return true })})
+}
+
+func (s *Server) listSpaces(w http.ResponseWriter, r *http.Request) {
+	var spaces []*resource.Space
+	for _, space := range s.spaces {
+		if matches(filter(r, "names"), space.Name) && matches(filter(r, "guids"), space.GUID) && matches(filter(r, "organization_guids"), s.org.GUID) {
+			spaces = append(spaces, space)
+		}
+	}
+	writeList(w, spaces)
+}
+
+func (s *Server) updateSpace(w http.ResponseWriter, r *http.Request) {
+	space := s.findSpaceByGUID(r.PathValue("guid"))
+	if space == nil {
+		writeNotFound(w, "Space")
+		return
+	}
+	if s.spaceUpdatesRejected {
+		writeError(w, http.StatusForbidden, "CF-NotAuthorized", "You are not authorized to perform the requested action")
+		return
+	}
+	var update resource.SpaceUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		writeError(w, http.StatusBadRequest, "CF-MessageParseError", err.Error())
+		return
+	}
+	if update.Name != "" {
+		space.Name = update.Name
+	}
+	mergeMetadata(space.Metadata, update.Metadata)
+	space.UpdatedAt = s.now()
+	writeJSON(w, http.StatusOK, space)
+}
+
+func (s *Server) getSpaceUsageSummary(w http.ResponseWriter, r *http.Request) {
+	guid := r.PathValue("guid")
+	if s.findSpaceByGUID(guid) == nil {
+		writeNotFound(w, "Space")
+		return
+	}
+	writeJSON(w, http.StatusOK, resource.SpaceUsageSummary{UsageSummary: s.usage(func(space string) bool { return space == guid })})
+}
+
+func (s *Server) listDomains(w http.ResponseWriter, r *http.Request) {
+	s.writeDomains(w, r)
+}
+
+func (s *Server) writeDomains(w http.ResponseWriter, r *http.Request) {
+	var domains []*resource.Domain
+	for _, domain := range s.domains {
+		if matches(filter(r, "names"), domain.Name) && matches(filter(r, "guids"), domain.GUID) {
+			domains = append(domains, domain)
+		}
+	}
+	writeList(w, domains)
+}
+
+func (s *Server) getRouteReservations(w http.ResponseWriter, r *http.Request) {
+	guid := r.PathValue("guid")
+	query := r.URL.Query()
+	port, _ := strconv.Atoi(query.Get("port"))
+	reserved := false
+	for _, route := range s.routes {
+		if route.Relationships.Domain.Data.GUID == guid && route.Host == query.Get("host") && route.Path == query.Get("path") && routePort(route) == port {
+			reserved = true
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"matching_route": reserved})
+}
+
+func (s *Server) listApps(w http.ResponseWriter, r *http.Request) {
+	var apps []*resource.App
+	for _, app := range s.apps {
+		if matches(filter(r, "names"), app.Name) && matches(filter(r, "guids"), app.GUID) && matches(filter(r, "space_guids"), app.Relationships.Space.Data.GUID) {
+			apps = append(apps, app)
+		}
+	}
+	writeList(w, apps)
+}
+
+func (s *Server) updateApp(w http.ResponseWriter, r *http.Request) {
+	app := s.findAppByGUID(r.PathValue("guid"))
+	if app == nil {
+		writeNotFound(w, "App")
+		return
+	}
+	var update resource.AppUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		writeError(w, http.StatusBadRequest, "CF-MessageParseError", err.Error())
+		return
+	}
+	if err := verifyMetadata(update.Metadata); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "CF-UnprocessableEntity", err.Error())
+		return
+	}
+	if update.Name != "" && update.Name != app.Name {
+		if s.findApp(app.Relationships.Space.Data.GUID, update.Name) != nil {
+			writeError(w, http.StatusUnprocessableEntity, "CF-UnprocessableEntity", fmt.Sprintf("App with the name '%s' already exists.", update.Name))
+			return
+		}
+		app.Name = update.Name
+	}
+	mergeMetadata(app.Metadata, update.Metadata)
+	app.UpdatedAt = s.now()
+	writeJSON(w, http.StatusOK, app)
+}
+
+func (s *Server) deleteApp(w http.ResponseWriter, r *http.Request) {
+	app := s.findAppByGUID(r.PathValue("guid"))
+	if app == nil {
+		writeNotFound(w, "App")
+		return
+	}
+	s.removeApp(app)
+	s.writeJob(w)
+}
+
+func (s *Server) startApp(w http.ResponseWriter, r *http.Request) {
+	app := s.findAppByGUID(r.PathValue("guid"))
+	if app == nil {
+		writeNotFound(w, "App")
+		return
+	}
+	s.setState(app, "STARTED")
+	writeJSON(w, http.StatusOK, app)
+}
+
+func (s *Server) stopApp(w http.ResponseWriter, r *http.Request) {
+	app := s.findAppByGUID(r.PathValue("guid"))
+	if app == nil {
+		writeNotFound(w, "App")
+		return
+	}
+	s.setState(app, "STOPPED")
+	writeJSON(w, http.StatusOK, app)
+}
+
+func (s *Server) getAppEnvironmentVariables(w http.ResponseWriter, r *http.Request) {
+	app := s.findAppByGUID(r.PathValue("guid"))
+	if app == nil {
+		writeNotFound(w, "App")
+		return
+	}
+	env := s.env[app.GUID]
+	if env == nil {
+		env = map[string]*string{}
+	}
+	writeJSON(w, http.StatusOK, resource.EnvVarResponse{EnvVar: resource.EnvVar{Var: env}})
+}
+
+func (s *Server) listAppProcesses(w http.ResponseWriter, r *http.Request) {
+	app := s.findAppByGUID(r.PathValue("guid"))
+	if app == nil {
+		writeNotFound(w, "App")
+		return
+	}
+	var processes []*resource.Process
+	for _, process := range s.processesForApp(app.GUID) {
+		if matches(filter(r, "types"), process.Type) {
+			processes = append(processes, process)
+		}
+	}
+	writeList(w, processes)
+}
+
+func (s *Server) getAppProcessStats(w http.ResponseWriter, r *http.Request) {
+	app := s.findAppByGUID(r.PathValue("guid"))
+	if app == nil {
+		writeNotFound(w, "App")
+		return
+	}
+	var process *resource.Process
+	for _, p := range s.processesForApp(app.GUID) {
+		if p.Type == r.PathValue("type") {
+			process = p
+		}
+	}
+	if process == nil {
+		writeNotFound(w, "Process")
+		return
+	}
+
+	state := "DOWN"
+	if app.State == "STARTED" {
+		state = "RUNNING"
+		if s.crashing[app.Name] {
+			state = "CRASHED"
+		}
+	}
+	stats := resource.ProcessStats{Stats: []resource.ProcessStat{}}
+	for i := 0; i < process.Instances; i++ {
+		stats.Stats = append(stats.Stats, resource.ProcessStat{
+			Type:        process.Type,
+			Index:       i,
+			State:       state,
+			MemoryQuota: process.MemoryInMB * 1024 * 1024,
+			DiskQuota:   process.DiskInMB * 1024 * 1024,
+		})
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+func (s *Server) getAppCurrentDroplet(w http.ResponseWriter, r *http.Request) {
+	app := s.findAppByGUID(r.PathValue("guid"))
+	if app == nil {
+		writeNotFound(w, "App")
+		return
+	}
+	if app.Relationships.CurrentDroplet.Data == nil {
+		writeNotFound(w, "Droplet")
+		return
+	}
+	writeJSON(w, http.StatusOK, resource.DropletCurrent{Data: *app.Relationships.CurrentDroplet.Data})
+}
+
+func (s *Server) listAppRoutes(w http.ResponseWriter, r *http.Request) {
+	app := s.findAppByGUID(r.PathValue("guid"))
+	if app == nil {
+		writeNotFound(w, "App")
+		return
+	}
+	writeList(w, s.routesForApp(app.GUID))
+}
+
+func (s *Server) updateDroplet(w http.ResponseWriter, r *http.Request) {
+	var droplet *resource.Droplet
+	for _, d := range s.droplets {
+		if d.GUID == r.PathValue("guid") {
+			droplet = d
+		}
+	}
+	if droplet == nil {
+		writeNotFound(w, "Droplet")
+		return
+	}
+	var update resource.DropletUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		writeError(w, http.StatusBadRequest, "CF-MessageParseError", err.Error())
+		return
+	}
+	mergeMetadata(droplet.Metadata, update.Metadata)
+	droplet.UpdatedAt = s.now()
+	writeJSON(w, http.StatusOK, droplet)
+}
+
+func (s *Server) listRoutes(w http.ResponseWriter, r *http.Request) {
+	var routes []*resource.Route
+	for _, route := range s.routes {
+		if matches(filter(r, "space_guids"), route.Relationships.Space.Data.GUID) &&
+			matches(filter(r, "domain_guids"), route.Relationships.Domain.Data.GUID) &&
+			matches(filter(r, "hosts"), route.Host) &&
+			matches(filter(r, "paths"), route.Path) &&
+			matches(filter(r, "ports"), strconv.Itoa(routePort(route))) &&
+			matches(filter(r, "organization_guids"), s.org.GUID) {
+			routes = append(routes, route)
+		}
+	}
+	writeList(w, routes)
+}
+
+func (s *Server) postRoute(w http.ResponseWriter, r *http.Request) {
+	var create resource.RouteCreate
+	if err := json.NewDecoder(r.Body).Decode(&create); err != nil {
+		writeError(w, http.StatusBadRequest, "CF-MessageParseError", err.Error())
+		return
+	}
+	space := s.findSpaceByGUID(create.Relationships.Space.Data.GUID)
+	var domain *resource.Domain
+	for _, d := range s.domains {
+		if d.GUID == create.Relationships.Domain.Data.GUID {
+			domain = d
+		}
+	}
+	if space == nil || domain == nil {
+		writeError(w, http.StatusUnprocessableEntity, "CF-UnprocessableEntity", "Invalid space or domain. Ensure that they exist and you have access to them.")
+		return
+	}
+	var host, path string
+	var port int
+	if create.Host != nil {
+		host = *create.Host
+	}
+	if create.Path != nil {
+		path = *create.Path
+	}
+	if create.Port != nil {
+		port = *create.Port
+	}
+	if s.findRoute(domain, host, path, port) != nil {
+		writeError(w, http.StatusUnprocessableEntity, "CF-UnprocessableEntity", "Route already exists.")
+		return
+	}
+	writeJSON(w, http.StatusCreated, s.createRoute(space, domain, host, path, port))
+}
+
+func (s *Server) deleteRoute(w http.ResponseWriter, r *http.Request) {
+	i := slices.IndexFunc(s.routes, func(route *resource.Route) bool { return route.GUID == r.PathValue("guid") })
+	if i < 0 {
+		writeNotFound(w, "Route")
+		return
+	}
+	s.routes = slices.Delete(s.routes, i, i+1)
+	s.writeJob(w)
+}
+
+// writeJob answers like CF does for asynchronous deletes. The fake deletes right away, so the job is already complete.
+func (s *Server) writeJob(w http.ResponseWriter) {
+	w.Header().Set("Location", fmt.Sprintf("%s/v3/jobs/%s", s.URL, uuid.NewString()))
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// getJob answers with the state of a job of a service broker, the other jobs are always complete.
+func (s *Server) getJob(w http.ResponseWriter, r *http.Request) {
+	guid := r.PathValue("guid")
+	result := resource.Job{State: resource.JobStateComplete, Resource: resource.Resource{GUID: guid}}
+	if j, found := s.jobs[guid]; found {
+		switch {
+		case !j.polled:
+			j.polled = true
+			result.State = resource.JobStateProcessing
+		case j.failure != "":
+			result.State = resource.JobStateFailed
+			result.Errors = []resource.CloudFoundryError{{Code: 10001, Title: "CF-ServiceBrokerRequestRejected", Detail: "Service broker error: " + j.failure}}
+		}
+		if result.State != resource.JobStateProcessing && j.done != nil {
+			j.done(j.failure)
+			j.done = nil
+		}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) listNetworkPolicies(w http.ResponseWriter, r *http.Request) {
+	policies := []networkPolicy{}
+	for _, policy := range s.policies {
+		if ids := filter(r, "id"); matches(ids, policy.Source.ID) || matches(ids, policy.Destination.ID) {
+			policies = append(policies, policy)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"total_policies": len(policies), "policies": policies})
+}
+
+func (s *Server) createNetworkPolicies(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Policies []networkPolicy `json:"policies"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "CF-MessageParseError", err.Error())
+		return
+	}
+	for _, policy := range body.Policies {
+		if !slices.Contains(s.policies, policy) {
+			s.policies = append(s.policies, policy)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{})
+}
+
+// usage sums up what the started apps in the spaces use, like the usage summary of CF.
+func (s *Server) usage(inSpace func(guid string) bool) (usage resource.UsageSummary) {
+	for _, app := range s.apps {
+		if !inSpace(app.Relationships.Space.Data.GUID) || app.State != "STARTED" {
+			continue
+		}
+		for _, process := range s.processesForApp(app.GUID) {
+			usage.StartedInstances += process.Instances
+			usage.MemoryInMb += process.Instances * process.MemoryInMB
+		}
+	}
+	for _, route := range s.routes {
+		if inSpace(route.Relationships.Space.Data.GUID) {
+			usage.Routes++
+		}
+	}
+	return
+}
+
+// The functions below expect the lock to be held.
+
+// now is the time of a change, it always moves forward so that the order of changes in the same instant is kept.
+func (s *Server) now() time.Time {
+	now := time.Now()
+	if !now.After(s.last) {
+		now = s.last.Add(time.Millisecond)
+	}
+	s.last = now
+	return now
+}
+
+func (s *Server) resource() resource.Resource {
+	now := s.now()
+	return resource.Resource{GUID: uuid.NewString(), CreatedAt: now, UpdatedAt: now}
+}
+
+func (s *Server) findSpace(name string) *resource.Space {
+	for _, space := range s.spaces {
+		if space.Name == name {
+			return space
+		}
+	}
+	return nil
+}
+
+func (s *Server) findSpaceByGUID(guid string) *resource.Space {
+	for _, space := range s.spaces {
+		if space.GUID == guid {
+			return space
+		}
+	}
+	return nil
+}
+
+func (s *Server) findDomain(name string) *resource.Domain {
+	for _, domain := range s.domains {
+		if domain.Name == name {
+			return domain
+		}
+	}
+	return nil
+}
+
+func (s *Server) appsInSpace(spaceGUID string) (apps []*resource.App) {
+	for _, app := range s.apps {
+		if app.Relationships.Space.Data.GUID == spaceGUID {
+			apps = append(apps, app)
+		}
+	}
+	return
+}
+
+func (s *Server) findApp(spaceGUID, name string) *resource.App {
+	for _, app := range s.appsInSpace(spaceGUID) {
+		if app.Name == name {
+			return app
+		}
+	}
+	return nil
+}
+
+func (s *Server) findAppByGUID(guid string) *resource.App {
+	for _, app := range s.apps {
+		if app.GUID == guid {
+			return app
+		}
+	}
+	return nil
+}
+
+func (s *Server) processesForApp(appGUID string) (processes []*resource.Process) {
+	for _, process := range s.processes {
+		if process.Relationships.App.Data.GUID == appGUID {
+			processes = append(processes, process)
+		}
+	}
+	return
+}
+
+func (s *Server) routesForApp(appGUID string) (routes []*resource.Route) {
+	for _, route := range s.routes {
+		if slices.ContainsFunc(route.Destinations, func(d resource.RouteDestination) bool { return *d.App.GUID == appGUID }) {
+			routes = append(routes, route)
+		}
+	}
+	return
+}
+
+func (s *Server) findRoute(domain *resource.Domain, host, path string, port int) *resource.Route {
+	for _, route := range s.routes {
+		if route.Relationships.Domain.Data.GUID == domain.GUID && route.Host == host && route.Path == path && routePort(route) == port {
+			return route
+		}
+	}
+	return nil
+}
+
+func (s *Server) createApp(space *resource.Space, name string) *resource.App {
+	app := &resource.App{
+		Name:  name,
+		State: "STOPPED",
+		Relationships: resource.AppRelationships{
+			Space: resource.ToOneRelationship{Data: &resource.Relationship{GUID: space.GUID}},
+		},
+		Metadata: newMetadata(),
+		Resource: s.resource(),
+	}
+	s.apps = append(s.apps, app)
+	return app
+}
+
+func (s *Server) createRoute(space *resource.Space, domain *resource.Domain, host, path string, port int) *resource.Route {
+	url := domain.Name
+	if host != "" {
+		url = host + "." + url
+	}
+	if port != 0 {
+		url = fmt.Sprintf("%s:%d", url, port)
+	}
+	route := &resource.Route{
+		Host:         host,
+		Path:         path,
+		URL:          url + path,
+		Protocol:     "http",
+		Destinations: []resource.RouteDestination{},
+		Metadata:     newMetadata(),
+		Relationships: resource.RouteRelationships{
+			Space:  resource.ToOneRelationship{Data: &resource.Relationship{GUID: space.GUID}},
+			Domain: resource.ToOneRelationship{Data: &resource.Relationship{GUID: domain.GUID}},
+		},
+		Resource: s.resource(),
+	}
+	if port != 0 {
+		route.Port = &port
+		route.Protocol = "tcp"
+	}
+	s.routes = append(s.routes, route)
+	return route
+}
+
+func (s *Server) setState(app *resource.App, state string) {
+	app.State = state
+	app.UpdatedAt = s.now()
+}
+
+func (s *Server) removeApp(app *resource.App) {
+	s.apps = slices.DeleteFunc(s.apps, func(a *resource.App) bool { return a.GUID == app.GUID })
+	s.processes = slices.DeleteFunc(s.processes, func(p *resource.Process) bool { return p.Relationships.App.Data.GUID == app.GUID })
+	s.droplets = slices.DeleteFunc(s.droplets, func(d *resource.Droplet) bool { return d.Relationships.App.Data.GUID == app.GUID })
+	delete(s.env, app.GUID)
+	for _, route := range s.routes {
+		route.Destinations = slices.DeleteFunc(route.Destinations, func(d resource.RouteDestination) bool { return *d.App.GUID == app.GUID })
+	}
+}
+
+func relationship(guid string) *resource.ToOneRelationship {
+	return &resource.ToOneRelationship{Data: &resource.Relationship{GUID: guid}}
+}
+
+func routePort(route *resource.Route) int {
+	if route.Port == nil {
+		return 0
+	}
+	return *route.Port
+}
+
+// mergeMetadata updates metadata like a PATCH in CF, a nil value removes the key.
+// verifyMetadata rejects the annotations that are longer than CF allows.
+func verifyMetadata(update *resource.Metadata) error {
+	if update == nil {
+		return nil
+	}
+	for key, value := range update.Annotations {
+		if value != nil && len(*value) > 5000 {
+			return fmt.Errorf("Metadata annotations error: value of '%s' is greater than 5000 characters", key)
+		}
+	}
+	return nil
+}
+
+func mergeMetadata(metadata *resource.Metadata, update *resource.Metadata) {
+	if update == nil {
+		return
+	}
+	merge := func(existing map[string]*string, updated map[string]*string) {
+		for key, value := range updated {
+			if value == nil {
+				delete(existing, key)
+				continue
+			}
+			existing[key] = value
+		}
+	}
+	merge(metadata.Labels, update.Labels)
+	merge(metadata.Annotations, update.Annotations)
+}
+
+// filter are the values of a list filter, nil if the query does not filter on key.
+func filter(r *http.Request, key string) []string {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+func matches(values []string, value string) bool {
+	return values == nil || slices.Contains(values, value)
+}
+
+// writeList writes all resources in a single page.
+func writeList[T any](w http.ResponseWriter, resources []T) {
+	if resources == nil {
+		resources = []T{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"pagination": resource.Pagination{TotalResults: len(resources), TotalPages: 1},
+		"resources":  resources,
+	})
+}
+
+func writeNotFound(w http.ResponseWriter, kind string) {
+	writeError(w, http.StatusNotFound, "CF-ResourceNotFound", fmt.Sprintf("%s not found", kind))
+}
+
+// errorCodes are the codes CF gives its errors, so that the client can tell them apart.
+var errorCodes = map[string]int{
+	"CF-InvalidAuthToken":    1000,
+	"CF-MessageParseError":   1001,
+	"CF-NotFound":            10000,
+	"CF-NotAuthorized":       10003,
+	"CF-UnprocessableEntity": 10008,
+	"CF-ResourceNotFound":    10010,
+}
+
+func writeError(w http.ResponseWriter, status int, title, detail string) {
+	writeJSON(w, status, resource.CloudFoundryErrors{Errors: []resource.CloudFoundryError{{Code: errorCodes[title], Title: title, Detail: detail}}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func newMetadata() *resource.Metadata {
+	return &resource.Metadata{Labels: map[string]*string{}, Annotations: map[string]*string{}}
+}
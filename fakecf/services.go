@@ -0,0 +1,297 @@
+package fakecf
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/google/uuid"
+)
+
+// servicePlan is a plan of a service offering of the marketplace.
+type servicePlan struct {
+	offering string
+	plan     *resource.ServicePlan
+}
+
+// job is an asynchronous operation of a service broker. Like a real broker it is still processing when
+// it is polled the first time, and done when it is polled again.
+type job struct {
+	polled bool
+	// failure is the description the broker failed with, empty when it succeeds.
+	failure string
+	done    func(failure string)
+}
+
+// AddServicePlan adds a plan of a service offering to the marketplace, so that managed service instances can be created with it.
+func (s *Server) AddServicePlan(offering, plan string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.servicePlans = append(s.servicePlans, servicePlan{
+		offering: offering,
+		plan:     &resource.ServicePlan{Name: plan, Available: true, Resource: s.resource()},
+	})
+}
+
+// FailBroker makes the next operation of the service broker on the managed service instance fail with the description.
+func (s *Server) FailBroker(serviceInstance, description string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.brokerFailures[serviceInstance] = description
+}
+
+// ServiceInstance is the service instance with the name in the space the requests deploy to, nil if there is none.
+func (s *Server) ServiceInstance(name string) *resource.ServiceInstance {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if instance := s.findServiceInstance(s.space.GUID, name); instance != nil {
+		copied := *instance
+		return &copied
+	}
+	return nil
+}
+
+// RoutesBoundTo are the urls of the routes the service instance in the space the requests deploy to is bound to, sorted.
+func (s *Server) RoutesBoundTo(serviceInstance string) (urls []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	instance := s.findServiceInstance(s.space.GUID, serviceInstance)
+	if instance == nil {
+		return
+	}
+	for _, binding := range s.routeBindings {
+		if binding.Relationships.ServiceInstance.Data.GUID != instance.GUID {
+			continue
+		}
+		for _, route := range s.routes {
+			if route.GUID == binding.Relationships.Route.Data.GUID {
+				urls = append(urls, route.URL)
+			}
+		}
+	}
+	slices.Sort(urls)
+	return
+}
+
+func (s *Server) listServicePlans(w http.ResponseWriter, r *http.Request) {
+	var plans []*resource.ServicePlan
+	for _, p := range s.servicePlans {
+		if matches(filter(r, "names"), p.plan.Name) && matches(filter(r, "service_offering_names"), p.offering) {
+			plans = append(plans, p.plan)
+		}
+	}
+	writeList(w, plans)
+}
+
+func (s *Server) listServiceInstances(w http.ResponseWriter, r *http.Request) {
+	var instances []*resource.ServiceInstance
+	for _, instance := range s.serviceInstances {
+		if matches(filter(r, "names"), instance.Name) && matches(filter(r, "space_guids"), instance.Relationships.Space.Data.GUID) {
+			instances = append(instances, instance)
+		}
+	}
+	writeList(w, instances)
+}
+
+func (s *Server) getServiceInstance(w http.ResponseWriter, r *http.Request) {
+	instance := s.findServiceInstanceByGUID(r.PathValue("guid"))
+	if instance == nil {
+		writeNotFound(w, "Service instance")
+		return
+	}
+	writeJSON(w, http.StatusOK, instance)
+}
+
+func (s *Server) createServiceInstance(w http.ResponseWriter, r *http.Request) {
+	var create struct {
+		resource.ServiceInstanceUserProvidedCreate
+		Parameters *json.RawMessage `json:"parameters"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&create); err != nil {
+		writeError(w, http.StatusBadRequest, "CF-MessageParseError", err.Error())
+		return
+	}
+	space := s.findSpaceByGUID(create.Relationships.Space.Data.GUID)
+	if space == nil {
+		writeError(w, http.StatusUnprocessableEntity, "CF-UnprocessableEntity", "Invalid space. Ensure that the space exists and you have access to it.")
+		return
+	}
+	if s.findServiceInstance(space.GUID, create.Name) != nil {
+		writeError(w, http.StatusUnprocessableEntity, "CF-UnprocessableEntity", fmt.Sprintf("The service instance name is taken: %s.", create.Name))
+		return
+	}
+
+	instance := &resource.ServiceInstance{
+		Name:          create.Name,
+		Type:          create.Type,
+		Tags:          create.Tags,
+		Relationships: resource.ServiceInstanceRelationships{Space: relationship(space.GUID)},
+		Metadata:      newMetadata(),
+		Resource:      s.resource(),
+	}
+
+	if create.Type == "user-provided" {
+		instance.RouteServiceURL = create.RouteServiceURL
+		instance.SyslogDrainURL = create.SyslogDrainURL
+		instance.LastOperation = resource.LastOperation{Type: "create", State: "succeeded"}
+		s.serviceInstances = append(s.serviceInstances, instance)
+		writeJSON(w, http.StatusCreated, instance)
+		return
+	}
+
+	if create.Relationships.ServicePlan == nil || s.findServicePlanByGUID(create.Relationships.ServicePlan.Data.GUID) == nil {
+		writeError(w, http.StatusUnprocessableEntity, "CF-UnprocessableEntity", "Invalid service plan. Ensure that the service plan exists, is available, and you have access to it.")
+		return
+	}
+	instance.Relationships.ServicePlan = create.Relationships.ServicePlan
+	s.serviceInstances = append(s.serviceInstances, instance)
+	s.startBrokerOperation(w, instance, "create")
+}
+
+func (s *Server) updateServiceInstance(w http.ResponseWriter, r *http.Request) {
+	instance := s.findServiceInstanceByGUID(r.PathValue("guid"))
+	if instance == nil {
+		writeNotFound(w, "Service instance")
+		return
+	}
+	var update struct {
+		resource.ServiceInstanceUserProvidedUpdate
+		Relationships *resource.ServiceInstanceRelationships `json:"relationships"`
+		Parameters    *json.RawMessage                       `json:"parameters"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		writeError(w, http.StatusBadRequest, "CF-MessageParseError", err.Error())
+		return
+	}
+
+	if update.Tags != nil {
+		instance.Tags = update.Tags
+	}
+	instance.UpdatedAt = s.now()
+
+	if instance.Type == "user-provided" {
+		instance.RouteServiceURL = update.RouteServiceURL
+		instance.SyslogDrainURL = update.SyslogDrainURL
+		instance.LastOperation = resource.LastOperation{Type: "update", State: "succeeded"}
+		writeJSON(w, http.StatusOK, instance)
+		return
+	}
+
+	// Like CF, only changes of the plan or the parameters go to the broker.
+	if update.Relationships == nil && update.Parameters == nil {
+		writeJSON(w, http.StatusOK, instance)
+		return
+	}
+	if update.Relationships != nil && update.Relationships.ServicePlan != nil {
+		if s.findServicePlanByGUID(update.Relationships.ServicePlan.Data.GUID) == nil {
+			writeError(w, http.StatusUnprocessableEntity, "CF-UnprocessableEntity", "Invalid service plan. Ensure that the service plan exists, is available, and you have access to it.")
+			return
+		}
+		instance.Relationships.ServicePlan = update.Relationships.ServicePlan
+	}
+	s.startBrokerOperation(w, instance, "update")
+}
+
+func (s *Server) listServiceRouteBindings(w http.ResponseWriter, r *http.Request) {
+	var bindings []*resource.ServiceRouteBinding
+	for _, binding := range s.routeBindings {
+		if matches(filter(r, "route_guids"), binding.Relationships.Route.Data.GUID) && matches(filter(r, "service_instance_guids"), binding.Relationships.ServiceInstance.Data.GUID) {
+			bindings = append(bindings, binding)
+		}
+	}
+	writeList(w, bindings)
+}
+
+// createServiceRouteBinding binds a route service, which is done right away as the fake only has user-provided route services.
+func (s *Server) createServiceRouteBinding(w http.ResponseWriter, r *http.Request) {
+	var create resource.ServiceRouteBindingCreate
+	if err := json.NewDecoder(r.Body).Decode(&create); err != nil {
+		writeError(w, http.StatusBadRequest, "CF-MessageParseError", err.Error())
+		return
+	}
+	instance := s.findServiceInstanceByGUID(create.Relationships.ServiceInstance.Data.GUID)
+	if instance == nil || instance.RouteServiceURL == nil {
+		writeError(w, http.StatusUnprocessableEntity, "CF-UnprocessableEntity", "This service instance does not support route binding.")
+		return
+	}
+	if !slices.ContainsFunc(s.routes, func(route *resource.Route) bool { return route.GUID == create.Relationships.Route.Data.GUID }) {
+		writeError(w, http.StatusUnprocessableEntity, "CF-UnprocessableEntity", "The route could not be found.")
+		return
+	}
+	for _, binding := range s.routeBindings {
+		if binding.Relationships.Route.Data.GUID == create.Relationships.Route.Data.GUID {
+			writeError(w, http.StatusUnprocessableEntity, "CF-UnprocessableEntity", "A route may only be bound to a single service instance.")
+			return
+		}
+	}
+
+	binding := &resource.ServiceRouteBinding{
+		RouteServiceURL: *instance.RouteServiceURL,
+		LastOperation:   resource.LastOperation{Type: "create", State: "succeeded"},
+		Relationships:   create.Relationships,
+		Metadata:        newMetadata(),
+		Resource:        s.resource(),
+	}
+	s.routeBindings = append(s.routeBindings, binding)
+	writeJSON(w, http.StatusCreated, binding)
+}
+
+func (s *Server) deleteServiceRouteBinding(w http.ResponseWriter, r *http.Request) {
+	i := slices.IndexFunc(s.routeBindings, func(binding *resource.ServiceRouteBinding) bool { return binding.GUID == r.PathValue("guid") })
+	if i < 0 {
+		writeNotFound(w, "Service route binding")
+		return
+	}
+	s.routeBindings = slices.Delete(s.routeBindings, i, i+1)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// startBrokerOperation answers with the job of the operation of the broker on the managed service instance.
+func (s *Server) startBrokerOperation(w http.ResponseWriter, instance *resource.ServiceInstance, operation string) {
+	instance.LastOperation = resource.LastOperation{Type: operation, State: "in progress"}
+	failure := s.brokerFailures[instance.Name]
+	delete(s.brokerFailures, instance.Name)
+
+	guid := uuid.NewString()
+	s.jobs[guid] = &job{
+		failure: failure,
+		done: func(failure string) {
+			instance.LastOperation = resource.LastOperation{Type: operation, State: "succeeded"}
+			if failure != "" {
+				instance.LastOperation = resource.LastOperation{Type: operation, State: "failed", Description: failure}
+			}
+			instance.UpdatedAt = s.now()
+		},
+	}
+	w.Header().Set("Location", fmt.Sprintf("%s/v3/jobs/%s", s.URL, guid))
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) findServicePlanByGUID(guid string) *resource.ServicePlan {
+	for _, p := range s.servicePlans {
+		if p.plan.GUID == guid {
+			return p.plan
+		}
+	}
+	return nil
+}
+
+func (s *Server) findServiceInstance(spaceGUID, name string) *resource.ServiceInstance {
+	for _, instance := range s.serviceInstances {
+		if instance.Relationships.Space.Data.GUID == spaceGUID && instance.Name == name {
+			return instance
+		}
+	}
+	return nil
+}
+
+func (s *Server) findServiceInstanceByGUID(guid string) *resource.ServiceInstance {
+	for _, instance := range s.serviceInstances {
+		if instance.GUID == guid {
+			return instance
+		}
+	}
+	return nil
+}
@@ -2,30 +2,80 @@ package fixes
 
 import (
 	"fmt"
+	"regexp"
+
 	"github.com/springernature/halfpipe-deploy-resource/config"
-	"strings"
 )
 
-var suggestDeveloperSpaceRole = func(log []byte, request config.Request) (err error) {
-	if strings.Contains(string(log), "You are not authorized to perform the requested action") {
-		errorMsg := `'%s' does not have 'SpaceDeveloper' permissions on org/space '%s/%s'
-To fix ask your org admin to run 'cf set-space-role %s %s %s SpaceDeveloper'`
-		err = fmt.Errorf(errorMsg,
-			request.Source.Username,
-			request.Source.Org,
-			request.Source.Space,
-			request.Source.Username,
-			request.Source.Org,
-			request.Source.Space,
-		)
+// Fix is a known failure found in the log, together with what to do about it.
+type Fix struct {
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+	Remedy  string `json:"remedy"`
+	DocsURL string `json:"docsUrl,omitempty"`
+}
+
+func (f Fix) Error() string {
+	s := fmt.Sprintf("[%s] %s\n%s", f.ID, f.Title, f.Remedy)
+	if f.DocsURL != "" {
+		s += fmt.Sprintf("\nSee %s", f.DocsURL)
 	}
-	return
+	return s
+}
+
+// Rule describes a known failure. Matcher is run against the log, and if it matches Remedy is
+// called with the submatches of the first match so the remedy can refer to what was in the log.
+type Rule struct {
+	ID      string
+	Title   string
+	DocsURL string
+	Matcher *regexp.Regexp
+	Remedy  func(match []string, request config.Request) string
 }
 
-func SuggestFix(log []byte, request config.Request) (fixes []error) {
-	if err := suggestDeveloperSpaceRole(log, request); err != nil {
-		fixes = append(fixes, err)
+func (r Rule) suggest(log []byte, request config.Request) (fix Fix, found bool) {
+	match := r.Matcher.FindSubmatch(log)
+	if match == nil {
+		return
+	}
+
+	var submatches []string
+	for _, m := range match {
+		submatches = append(submatches, string(m))
 	}
 
+	return Fix{
+		ID:      r.ID,
+		Title:   r.Title,
+		Remedy:  r.Remedy(submatches, request),
+		DocsURL: r.DocsURL,
+	}, true
+}
+
+type Registry struct {
+	rules []Rule
+}
+
+func NewRegistry(rules ...Rule) *Registry {
+	return &Registry{rules: rules}
+}
+
+func (r *Registry) Register(rule Rule) {
+	r.rules = append(r.rules, rule)
+}
+
+// Suggest returns a fix for every rule that matches the log, in the order the rules were registered.
+func (r *Registry) Suggest(log []byte, request config.Request) (fixes []Fix) {
+	for _, rule := range r.rules {
+		if fix, found := rule.suggest(log, request); found {
+			fixes = append(fixes, fix)
+		}
+	}
 	return
 }
+
+var DefaultRegistry = NewRegistry(defaultRules...)
+
+func SuggestFix(log []byte, request config.Request) (fixes []Fix) {
+	return DefaultRegistry.Suggest(log, request)
+}
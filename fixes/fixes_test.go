@@ -1,53 +1,99 @@
 package fixes
 
 import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
 	"github.com/springernature/halfpipe-deploy-resource/config"
 	"github.com/stretchr/testify/assert"
-	"testing"
 )
 
-func TestFixForNotAuthorizedToPerformAction(t *testing.T) {
-	r := config.Request{
-		Source: config.Source{
-			Org:      "myOrg",
-			Space:    "mySpace",
-			Username: "myUser",
-		},
-	}
+var r = config.Request{
+	Source: config.Source{
+		Org:      "myOrg",
+		Space:    "mySpace",
+		Username: "myUser",
+	},
+}
 
-	errorLog := []byte(`Getting app info...
-Creating app with these attributes...
-+ name:         integration-test-app-CANDIDATE
-  path:         /Users/simonjohansson/src/halfpipe-deploy-resource/.integration_test
-  buildpacks:
-+   staticfile_buildpack
-  env:
-+   GIT_REVISION
-
-Creating app integration-test-app-CANDIDATE...
-You are not authorized to perform the requested action
-FAILED
-exit status 1
-`)
-
-	fixes := SuggestFix(errorLog, r)
-	assert.Len(t, fixes, 1)
-	assert.Contains(t, fixes, suggestDeveloperSpaceRole(errorLog, r))
+func readFixture(t *testing.T, name string) []byte {
+	log, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return log
 }
 
-func TestNoFixWhenLogDoesntContainATrigger(t *testing.T) {
-	r := config.Request{
-		Source: config.Source{
-			Org:      "myOrg",
-			Space:    "mySpace",
-			Username: "myUser",
-		},
+func TestSuggestFixWithRealWorldLogs(t *testing.T) {
+	tests := []struct {
+		fixture     string
+		expectedIDs []string
+	}{
+		{fixture: "not-authorized.log", expectedIDs: []string{"not-authorized"}},
+		{fixture: "memory-quota-exceeded-org.log", expectedIDs: []string{"memory-quota-exceeded"}},
+		{fixture: "memory-quota-exceeded-space.log", expectedIDs: []string{"memory-quota-exceeded"}},
+		{fixture: "route-taken.log", expectedIDs: []string{"route-taken"}},
+		{fixture: "route-different-space.log", expectedIDs: []string{"route-taken"}},
+		{fixture: "staging-failed.log", expectedIDs: []string{"staging-failed"}},
+		{fixture: "docker-pull-unauthorized.log", expectedIDs: []string{"docker-pull-unauthorized"}},
+		{fixture: "docker-image-not-found.log", expectedIDs: []string{"docker-image-not-found"}},
+		{fixture: "insufficient-resources.log", expectedIDs: []string{"insufficient-resources"}},
+		{fixture: "app-instance-limit.log", expectedIDs: []string{"app-instance-limit"}},
+		{fixture: "stack-not-found.log", expectedIDs: []string{"stack-not-found"}},
+		{fixture: "no-fix.log", expectedIDs: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.fixture, func(t *testing.T) {
+			var ids []string
+			for _, fix := range SuggestFix(readFixture(t, test.fixture), r) {
+				ids = append(ids, fix.ID)
+				assert.NotEmpty(t, fix.Title)
+				assert.NotEmpty(t, fix.Remedy)
+			}
+			assert.Equal(t, test.expectedIDs, ids)
+		})
 	}
+}
+
+func TestFixForNotAuthorizedToPerformAction(t *testing.T) {
+	fixes := SuggestFix(readFixture(t, "not-authorized.log"), r)
 
-	errorLog := []byte(`Getting app info...
-Meehp meehp I am CF log.
-`)
+	assert.Equal(t, []Fix{{
+		ID:    "not-authorized",
+		Title: "Missing SpaceDeveloper role",
+		Remedy: `'myUser' does not have 'SpaceDeveloper' permissions on org/space 'myOrg/mySpace'
+To fix ask your org admin to run 'cf set-space-role myUser myOrg mySpace SpaceDeveloper'`,
+		DocsURL: "https://docs.cloudfoundry.org/concepts/roles.html",
+	}}, fixes)
+}
+
+func TestRemedyUsesTheMatchedLog(t *testing.T) {
+	routeFix := SuggestFix(readFixture(t, "route-taken.log"), r)[0]
+	assert.Contains(t, routeFix.Remedy, "'my-app.public.springernature.app' is reserved in another space than 'myOrg/mySpace'")
 
-	fixes := SuggestFix(errorLog, r)
-	assert.Len(t, fixes, 0)
+	stackFix := SuggestFix(readFixture(t, "stack-not-found.log"), r)[0]
+	assert.Contains(t, stackFix.Remedy, "The stack 'cflinuxfs2'")
+}
+
+func TestFixError(t *testing.T) {
+	fix := Fix{ID: "id", Title: "title", Remedy: "remedy", DocsURL: "https://docs"}
+	assert.Equal(t, "[id] title\nremedy\nSee https://docs", fix.Error())
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	assert.Empty(t, registry.Suggest([]byte("kehe"), r))
+
+	registry.Register(Rule{
+		ID:      "kehe",
+		Title:   "Kehe",
+		Matcher: regexp.MustCompile(`ke(he)`),
+		Remedy: func(match []string, request config.Request) string {
+			return match[1] + " in " + request.Source.Space
+		},
+	})
+	assert.Equal(t, []Fix{{ID: "kehe", Title: "Kehe", Remedy: "he in mySpace"}}, registry.Suggest([]byte("kehe"), r))
 }
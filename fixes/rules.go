@@ -0,0 +1,150 @@
+package fixes
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/springernature/halfpipe-deploy-resource/config"
+)
+
+var notAuthorized = Rule{
+	ID:      "not-authorized",
+	Title:   "Missing SpaceDeveloper role",
+	DocsURL: "https://docs.cloudfoundry.org/concepts/roles.html",
+	Matcher: regexp.MustCompile(`You are not authorized to perform the requested action`),
+	Remedy: func(_ []string, request config.Request) string {
+		return fmt.Sprintf(`'%s' does not have 'SpaceDeveloper' permissions on org/space '%s/%s'
+To fix ask your org admin to run 'cf set-space-role %s %s %s SpaceDeveloper'`,
+			request.Source.Username,
+			request.Source.Org,
+			request.Source.Space,
+			request.Source.Username,
+			request.Source.Org,
+			request.Source.Space,
+		)
+	},
+}
+
+var memoryQuotaExceeded = Rule{
+	ID:      "memory-quota-exceeded",
+	Title:   "Memory quota exceeded",
+	DocsURL: "https://docs.cloudfoundry.org/adminguide/quota-plans.html",
+	Matcher: regexp.MustCompile(`(?i)(exceeded your organization's memory limit|exceeded the memory limit for this space|memory quota_exceeded|memory quota exceeded)`),
+	Remedy: func(_ []string, request config.Request) string {
+		return fmt.Sprintf(`The memory quota of org/space '%s/%s' does not allow the candidate to run next to the live app.
+During a blue/green deploy both need to fit, so either lower 'memory' or 'instances' in the manifest,
+delete unused apps with 'cf apps' / 'cf delete', or ask your org admin for a bigger quota.`,
+			request.Source.Org,
+			request.Source.Space,
+		)
+	},
+}
+
+var routeTaken = Rule{
+	ID:      "route-taken",
+	Title:   "Route already taken",
+	DocsURL: "https://docs.cloudfoundry.org/devguide/deploy-apps/routes-domains.html",
+	Matcher: regexp.MustCompile(`(?i)route '?([^\s']+?)'? (?:is already in use|is already taken|already exists in a different space)|because the route exists in a different space`),
+	Remedy: func(match []string, request config.Request) string {
+		route := "the route"
+		if match[1] != "" {
+			route = fmt.Sprintf("'%s'", match[1])
+		}
+		return fmt.Sprintf(`%s is reserved in another space than '%s/%s'.
+Either use another hostname in the manifest, or delete the route in the other space with 'cf delete-route'.`,
+			route,
+			request.Source.Org,
+			request.Source.Space,
+		)
+	},
+}
+
+var stagingFailed = Rule{
+	ID:      "staging-failed",
+	Title:   "Staging failed",
+	DocsURL: "https://docs.cloudfoundry.org/devguide/deploy-apps/troubleshoot-app-health.html",
+	Matcher: regexp.MustCompile(`(?i)(BuildpackCompileFailed|App staging failed in the buildpack compile phase|Failed to compile droplet|NoAppDetectedError|None of the buildpacks detected a compatible application)`),
+	Remedy: func(match []string, _ config.Request) string {
+		return fmt.Sprintf(`The buildpack failed with '%s'.
+Check the staging output above for the buildpack's own error, and make sure the 'buildpacks' in the manifest match the app and 'path' points at the built artifact.`,
+			match[1],
+		)
+	},
+}
+
+var dockerPullUnauthorized = Rule{
+	ID:      "docker-pull-unauthorized",
+	Title:   "Docker image could not be pulled",
+	DocsURL: "https://docs.cloudfoundry.org/devguide/deploy-apps/push-docker.html",
+	Matcher: regexp.MustCompile(`(?i)(unauthorized: authentication required|pull access denied|Docker credentials were not provided)`),
+	Remedy: func(match []string, _ config.Request) string {
+		return fmt.Sprintf(`CF could not pull the docker image: '%s'.
+Check that 'dockerUsername' and 'dockerPassword' are set and have pull access to the registry.`,
+			match[1],
+		)
+	},
+}
+
+var dockerImageNotFound = Rule{
+	ID:      "docker-image-not-found",
+	Title:   "Docker image not found",
+	DocsURL: "https://docs.cloudfoundry.org/devguide/deploy-apps/push-docker.html",
+	Matcher: regexp.MustCompile(`(?i)(manifest unknown|manifest for \S+ not found)`),
+	Remedy: func(match []string, _ config.Request) string {
+		return fmt.Sprintf(`The registry does not have the docker image: '%s'.
+Check that the image in the manifest and the tag exist, e.g. that the build that pushes the image has run and pushed that tag.`,
+			match[1],
+		)
+	},
+}
+
+var insufficientResources = Rule{
+	ID:      "insufficient-resources",
+	Title:   "Insufficient resources in the platform",
+	Matcher: regexp.MustCompile(`insufficient resources: ?([a-z, ]+)`),
+	Remedy: func(match []string, _ config.Request) string {
+		return fmt.Sprintf(`CF does not have enough %s available on its cells to start all instances.
+This is not a quota problem, it means that CF is not scaled properly, please contact us in #ee`,
+			match[1],
+		)
+	},
+}
+
+var appInstanceLimit = Rule{
+	ID:      "app-instance-limit",
+	Title:   "App instance limit exceeded",
+	DocsURL: "https://docs.cloudfoundry.org/adminguide/quota-plans.html",
+	Matcher: regexp.MustCompile(`(?i)(exceeded the instance limit|app_instance_limit|instance limit for your (?:space|organization)'s quota)`),
+	Remedy: func(_ []string, request config.Request) string {
+		return fmt.Sprintf(`The quota of org/space '%s/%s' limits the total number of app instances.
+During a blue/green deploy the candidate and the live app both count, so lower 'instances' or ask your org admin for a bigger quota.`,
+			request.Source.Org,
+			request.Source.Space,
+		)
+	},
+}
+
+var stackNotFound = Rule{
+	ID:      "stack-not-found",
+	Title:   "Stack not found",
+	DocsURL: "https://ee.public.springernature.app/paas/cf/stacks/",
+	Matcher: regexp.MustCompile(`(?i)stack '([^']+)' (?:does not exist|not found)`),
+	Remedy: func(match []string, _ config.Request) string {
+		return fmt.Sprintf(`The stack '%s' in the manifest does not exist in this foundation.
+Run 'cf stacks' to see which stacks are available and update 'stack' in the manifest.`,
+			match[1],
+		)
+	},
+}
+
+var defaultRules = []Rule{
+	notAuthorized,
+	memoryQuotaExceeded,
+	routeTaken,
+	stagingFailed,
+	dockerPullUnauthorized,
+	dockerImageNotFound,
+	insufficientResources,
+	appInstanceLimit,
+	stackNotFound,
+}
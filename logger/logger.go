@@ -3,24 +3,62 @@ package logger
 import (
 	"fmt"
 	"io"
+	"sync"
 )
 
+// WindowSize is how much of the output of the current command is kept for pattern matching.
+const WindowSize = 256 * 1024
+
+// TailSize is how much of the total output is kept for suggesting fixes when the deploy fails.
+const TailSize = 1024 * 1024
+
+// CapturingWriter writes everything to Writer, and keeps a bounded capture of what was written.
+// The window holds the output since StartWindow was last called, the tail holds the end of all output.
 type CapturingWriter struct {
-	Writer       io.Writer
-	BytesWritten []byte
+	Writer io.Writer
+	mu     *sync.Mutex
+	window *ringBuffer
+	tail   *ringBuffer
 }
 
 func NewLogger(writer io.Writer) CapturingWriter {
 	return CapturingWriter{
 		Writer: writer,
+		mu:     &sync.Mutex{},
+		window: newRingBuffer(WindowSize),
+		tail:   newRingBuffer(TailSize),
 	}
 }
 
 func (k *CapturingWriter) Write(p []byte) (n int, err error) {
-	k.BytesWritten = append(k.BytesWritten, p...)
+	k.mu.Lock()
+	k.window.Write(p)
+	k.tail.Write(p)
+	k.mu.Unlock()
 	return k.Writer.Write(p)
 }
 
 func (k *CapturingWriter) Println(v ...any) (n int, err error) {
 	return k.Write(fmt.Appendln(nil, v...))
 }
+
+// StartWindow discards the current window, so that Window only contains what is written from now on.
+func (k *CapturingWriter) StartWindow() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.window.Reset()
+}
+
+// Window returns the last WindowSize bytes written since StartWindow was called.
+func (k *CapturingWriter) Window() []byte {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.window.Bytes()
+}
+
+// Tail returns the last TailSize bytes written.
+func (k *CapturingWriter) Tail() []byte {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.tail.Bytes()
+}
@@ -0,0 +1,55 @@
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRingBuffer(t *testing.T) {
+	r := newRingBuffer(5)
+	assert.Empty(t, r.Bytes())
+
+	r.Write([]byte("abc"))
+	assert.Equal(t, "abc", string(r.Bytes()))
+
+	r.Write([]byte("de"))
+	assert.Equal(t, "abcde", string(r.Bytes()))
+
+	r.Write([]byte("fg"))
+	assert.Equal(t, "cdefg", string(r.Bytes()))
+
+	r.Write([]byte("hijklmn"))
+	assert.Equal(t, "jklmn", string(r.Bytes()))
+
+	r.Reset()
+	assert.Empty(t, r.Bytes())
+}
+
+func TestCapturingWriter(t *testing.T) {
+	var out bytes.Buffer
+	l := NewLogger(&out)
+
+	l.Println("first command")
+	l.StartWindow()
+	l.Println("second command")
+
+	assert.Equal(t, "first command\nsecond command\n", out.String())
+	assert.Equal(t, "second command\n", string(l.Window()))
+	assert.Equal(t, "first command\nsecond command\n", string(l.Tail()))
+}
+
+func TestCapturingWriterIsBounded(t *testing.T) {
+	var out bytes.Buffer
+	l := NewLogger(&out)
+
+	l.Write([]byte(strings.Repeat("a", TailSize)))
+	l.Write([]byte("the end"))
+
+	assert.Len(t, l.Window(), WindowSize)
+	assert.Len(t, l.Tail(), TailSize)
+	assert.True(t, strings.HasSuffix(string(l.Window()), "the end"))
+	assert.True(t, strings.HasSuffix(string(l.Tail()), "the end"))
+}
@@ -0,0 +1,43 @@
+package logger
+
+// ringBuffer keeps the last size bytes written to it.
+type ringBuffer struct {
+	buf  []byte
+	next int
+	full bool
+}
+
+func newRingBuffer(size int) *ringBuffer {
+	return &ringBuffer{buf: make([]byte, size)}
+}
+
+func (r *ringBuffer) Write(p []byte) {
+	if len(p) >= len(r.buf) {
+		copy(r.buf, p[len(p)-len(r.buf):])
+		r.next = 0
+		r.full = true
+		return
+	}
+
+	n := copy(r.buf[r.next:], p)
+	if n < len(p) {
+		copy(r.buf, p[n:])
+		r.full = true
+	}
+	r.next = (r.next + len(p)) % len(r.buf)
+	if r.next == 0 && len(p) > 0 {
+		r.full = true
+	}
+}
+
+func (r *ringBuffer) Bytes() []byte {
+	if !r.full {
+		return append([]byte(nil), r.buf[:r.next]...)
+	}
+	return append(append([]byte(nil), r.buf[r.next:]...), r.buf[:r.next]...)
+}
+
+func (r *ringBuffer) Reset() {
+	r.next = 0
+	r.full = false
+}
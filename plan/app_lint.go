@@ -3,23 +3,46 @@ package plan
 import (
 	"code.cloudfoundry.org/cli/util/manifestparser"
 	"context"
+	"encoding/json"
 	"fmt"
 	cfclient "github.com/cloudfoundry/go-cfclient/v3/client"
 	"github.com/cloudfoundry/go-cfclient/v3/resource"
 	"github.com/gookit/color"
+	"github.com/springernature/halfpipe-deploy-resource/config"
 	"github.com/springernature/halfpipe-deploy-resource/logger"
+	"slices"
+	"sort"
+	"strings"
 )
 
 type AppLintPlan interface {
-	Plan(manifest manifestparser.Application, org, space string) (pl Plan)
+	Plan(manifest manifestparser.Application, request config.Request) (pl Plan)
 }
 
 type appLintPlan struct {
 }
 
-func (p appLintPlan) Plan(manifest manifestparser.Application, org, space string) (pl Plan) {
+const labelsDocsURL = "https://ee.public.springernature.app/inventory/"
+
+// lintLabels are the labels of the manifest and the space, the ones in the manifest win.
+type lintLabels struct {
+	manifest map[string]string
+	space    map[string]string
+}
+
+func (l lintLabels) get(label string) (value string, in string, found bool) {
+	if value, found = l.manifest[label]; found {
+		return value, "manifest", true
+	}
+	if value, found = l.space[label]; found {
+		return value, "space", true
+	}
+	return "", "", false
+}
+
+func (p appLintPlan) Plan(manifest manifestparser.Application, request config.Request) (pl Plan) {
 	desc := "Linting application"
-	pl = append(pl, NewClientCommand(p.createFunc(manifest, org, space), desc))
+	pl = append(pl, NewClientCommand(p.createFunc(manifest, request), desc))
 	return
 }
 
@@ -34,102 +57,168 @@ func (p appLintPlan) getMetadataInOrgSpace(ctx context.Context, cf *cfclient.Cli
 	return
 }
 
-func (p appLintPlan) getLabelsForApp(manifest manifestparser.Application) map[any]any {
-	labels := make(map[any]any)
+func (p appLintPlan) getLabelsForApp(manifest manifestparser.Application) map[string]string {
+	labels := make(map[string]string)
 	if manifest.RemainingManifestFields["metadata"] != nil {
 		metadata := manifest.RemainingManifestFields["metadata"].(map[any]any)
 		if metadata["labels"] != nil {
-			labels = metadata["labels"].(map[any]any)
+			for key, value := range metadata["labels"].(map[any]any) {
+				labels[fmt.Sprint(key)] = fmt.Sprint(value)
+			}
 		}
 	}
 	return labels
 }
 
-func (p appLintPlan) checkProduct() {
-
+func stringLabels(labels map[string]*string) map[string]string {
+	values := make(map[string]string)
+	for key, value := range labels {
+		if value != nil {
+			values[key] = *value
+		}
+	}
+	return values
 }
 
-func stringLabel(labels map[string]*string, key string) (value string, found bool) {
-	v, ok := labels[key]
-	if !ok || v == nil {
-		return "", false
+// needsSpaceLabels is true when a label rule is on and the manifest does not have all the labels it looks at.
+func (p appLintPlan) needsSpaceLabels(policy config.LintPolicy, manifestLabels map[string]string) bool {
+	var labels []string
+	if policy.Level(config.LintRuleRequiredLabels, config.LintWarn) != config.LintOff {
+		labels = append(labels, policy.RequiredLabelsOrDefault()...)
+	}
+	if policy.Level(config.LintRuleAllowedLabelValues, config.LintWarn) != config.LintOff {
+		for label := range policy.AllowedLabelValues {
+			labels = append(labels, label)
+		}
 	}
-	return *v, true
+	for _, label := range labels {
+		if _, found := manifestLabels[label]; !found {
+			return true
+		}
+	}
+	return false
 }
 
-func (p appLintPlan) createFunc(manifest manifestparser.Application, org, space string) func(*cfclient.Client, *logger.CapturingWriter) error {
-	return func(cfClient *cfclient.Client, logger *logger.CapturingWriter) error {
+// lint runs the label rules, which need the labels of the space, and the manifest rules.
+func (p appLintPlan) lint(manifest manifestparser.Application, policy config.LintPolicy, labels lintLabels) (results []LintResult) {
+	results = []LintResult{}
+	add := func(rule string, messages ...string) {
+		level := policy.Level(rule, config.LintWarn)
+		if level == config.LintOff {
+			return
+		}
+		for _, message := range messages {
+			results = append(results, LintResult{Rule: rule, Level: level, Message: message, DocsURL: labelsDocsURL})
+		}
+	}
 
-		printWarning := func(msg string) {
-			logger.Println(color.New(color.FgRed).Sprintf("**WARNING** "), msg)
+	add(config.LintRuleRequiredLabels, p.lintRequiredLabels(policy, labels)...)
+	add(config.LintRuleAllowedLabelValues, p.lintAllowedLabelValues(policy, labels)...)
+	return append(results, LintManifest(manifest, policy, ManifestLintRules(policy))...)
+}
+
+func (p appLintPlan) lintRequiredLabels(policy config.LintPolicy, labels lintLabels) (messages []string) {
+	for _, label := range policy.RequiredLabelsOrDefault() {
+		if _, _, found := labels.get(label); !found {
+			messages = append(messages, fmt.Sprintf("'%s' is missing in both manifest and space", label))
 		}
+	}
+	return
+}
 
-		if manifest.Stack == "cflinuxfs3" {
-			printWarning("CF stack 'cflinuxfs3' is deprecated. Please see <https://ee.public.springernature.app/paas/cf/stacks/>")
+func (p appLintPlan) lintAllowedLabelValues(policy config.LintPolicy, labels lintLabels) (messages []string) {
+	var names []string
+	for label := range policy.AllowedLabelValues {
+		names = append(names, label)
+	}
+	sort.Strings(names)
+
+	for _, label := range names {
+		allowed := policy.AllowedLabelValues[label]
+		if value, in, found := labels.get(label); found && !slices.Contains(allowed, value) {
+			messages = append(messages, fmt.Sprintf("'%s' is '%s' in %s, must be one of %s", label, value, in, strings.Join(allowed, ", ")))
 		}
+	}
+	return
+}
 
-		labels := p.getLabelsForApp(manifest)
-		manifestProduct, manifestProductFound := labels["product"]
-		manifestEnvironment, manifestEnvironmentFound := labels["environment"]
-		manifestEAID, manifestEAIDFound := labels["eaid"]
+func (p appLintPlan) createFunc(manifest manifestparser.Application, request config.Request) func(*cfclient.Client, *logger.CapturingWriter) error {
+	return func(cfClient *cfclient.Client, logger *logger.CapturingWriter) error {
+		policy := request.Params.LintPolicy
+		labels := lintLabels{manifest: p.getLabelsForApp(manifest), space: map[string]string{}}
+
+		if p.needsSpaceLabels(policy, labels.manifest) {
+			logger.Println(fmt.Sprintf("Fetching metadata labels set on '%s/%s'", request.Source.Org, request.Source.Space))
+			metadata, err := p.getMetadataInOrgSpace(context.Background(), cfClient, request.Source.Org, request.Source.Space)
+			if err != nil {
+				logger.Println(fmt.Sprintf(`\t Failed to fetch: %s`, err.Error()))
+				logger.Println("\t Lets continue...")
+			} else {
+				labels.space = stringLabels(metadata.Labels)
+			}
+		}
 
-		if manifestProductFound && manifestEnvironmentFound && manifestEAIDFound {
-			logger.Println(fmt.Sprintf(`Found product '%s', environment '%s'  and EAID '%s' in manifest`, manifestProduct, manifestEnvironment, manifestEAID))
-			return nil
+		if policy.FormatOrDefault() == config.LintFormatText {
+			p.printLabels(logger, policy, labels)
 		}
+		return reportLint(logger, policy, p.lint(manifest, policy, labels))
+	}
+}
 
-		logger.Println(fmt.Sprintf("Fetching metadata labels set on '%s/%s'", org, space))
-		metadata, err := p.getMetadataInOrgSpace(context.Background(), cfClient, org, space)
+// reportLint prints the results in the format of the policy and fails when a rule at level error found a problem.
+func reportLint(logger *logger.CapturingWriter, policy config.LintPolicy, results []LintResult) error {
+	if policy.FormatOrDefault() == config.LintFormatJSON {
+		out, err := json.MarshalIndent(results, "", "  ")
 		if err != nil {
-			logger.Println(fmt.Sprintf(`\t Failed to fetch: %s`, err.Error()))
-			logger.Println("\t Lets continue...")
-			return nil
+			return err
 		}
+		logger.Println(string(out))
+	} else {
+		printLintResults(logger, results)
+	}
 
-		spaceProduct, spaceProductFound := stringLabel(metadata.Labels, "product")
-		spaceEnvironment, spaceEnvironmentFound := stringLabel(metadata.Labels, "environment")
-		spaceEAID, spaceEAIDFound := stringLabel(metadata.Labels, "eaid")
-
-		if manifestProductFound || spaceProductFound {
-			p := manifestProduct
-			in := "manifest"
-			if !manifestProductFound {
-				p = spaceProduct
-				in = "space"
-			}
-			logger.Println(fmt.Sprintf("'product' is set. Found '%s' in %s", p, in))
-		} else {
-			printWarning("'product' is missing in both manifest and space")
+	errors := 0
+	for _, result := range results {
+		if result.Level == config.LintError {
+			errors++
 		}
+	}
+	if errors > 0 {
+		return fmt.Errorf("linting failed with %d error(s), see the lint policy for the rules that fail the deploy", errors)
+	}
+	return nil
+}
 
-		if manifestEnvironmentFound || spaceEnvironmentFound {
-			e := manifestEnvironment
-			in := "manifest"
-			if !manifestEnvironmentFound {
-				e = spaceEnvironment
-				in = "space"
+func (p appLintPlan) printLabels(logger *logger.CapturingWriter, policy config.LintPolicy, labels lintLabels) {
+	if policy.Level(config.LintRuleRequiredLabels, config.LintWarn) != config.LintOff {
+		for _, label := range policy.RequiredLabelsOrDefault() {
+			if value, in, found := labels.get(label); found {
+				logger.Println(fmt.Sprintf("'%s' is set. Found '%s' in %s", label, value, in))
 			}
-			logger.Println(fmt.Sprintf("'environment' is set. Found '%s' in %s", e, in))
-		} else {
-			printWarning("'environment' is missing in both manifest and space")
 		}
+	}
+}
 
-		if manifestEAIDFound || spaceEAIDFound {
-			e := manifestEAID
-			in := "manifest"
-			if !manifestEAIDFound {
-				e = spaceEAID
-				in = "space"
-			}
-			logger.Println(fmt.Sprintf("'eaid' is set. Found '%s' in %s", e, in))
+func printLintResults(logger *logger.CapturingWriter, results []LintResult) {
+	aboutLabels := false
+	for _, result := range results {
+		prefix := color.New(color.FgRed).Sprintf("**WARNING** ")
+		if result.Level == config.LintError {
+			prefix = color.New(color.FgRed).Sprintf("**ERROR** ")
+		}
+		logger.Println(prefix, fmt.Sprintf("%s [%s]", result.Message, result.Rule))
+		if result.DocsURL == labelsDocsURL {
+			aboutLabels = true
 		} else {
-			printWarning("'eaid' is missing in both manifest and space")
+			logger.Println(fmt.Sprintf("\t Please see <%s>", result.DocsURL))
 		}
+	}
 
-		if !(manifestProductFound || spaceProductFound || spaceEAIDFound) || !(manifestEnvironmentFound || spaceEnvironmentFound || manifestEAIDFound) {
-			logger.Println("Please see https://ee.public.springernature.app/inventory/ for more information about labels and how to set them!")
-		}
-		return nil
+	if aboutLabels {
+		logger.Println(fmt.Sprintf("Please see %s for more information about labels and how to set them!", labelsDocsURL))
+	}
+	if len(results) == 0 {
+		logger.Println("No problems found")
 	}
 }
 
@@ -0,0 +1,83 @@
+package plan
+
+import (
+	halfpipe_deploy_resource "github.com/springernature/halfpipe-deploy-resource"
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAppLintDefaultPolicy(t *testing.T) {
+	man := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp
+  stack: cflinuxfs3
+  health-check-type: http
+  timeout: 120
+  routes:
+  - route: myApp.domain.com
+  metadata:
+    labels:
+      product: myProduct`).Applications[0]
+
+	labels := lintLabels{manifest: appLintPlan{}.getLabelsForApp(man), space: map[string]string{"environment": "live"}}
+	results := appLintPlan{}.lint(man, config.LintPolicy{}, labels)
+
+	assert.Equal(t, []LintResult{
+		{Rule: config.LintRuleRequiredLabels, Level: config.LintWarn, Message: "'eaid' is missing in both manifest and space", DocsURL: labelsDocsURL},
+		{Rule: config.LintRuleDeprecatedStack, Level: config.LintWarn, Message: "CF stack 'cflinuxfs3' is deprecated", DocsURL: "https://ee.public.springernature.app/paas/cf/stacks/"},
+	}, results)
+}
+
+func TestAppLintPolicy(t *testing.T) {
+	man := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp
+  memory: 4G
+  no-route: true
+  routes:
+  - route: myApp.domain.com
+  processes:
+  - type: worker
+    memory: 1G
+  metadata:
+    labels:
+      product: myProduct
+      environment: prod
+      eaid: 1`).Applications[0]
+
+	policy := config.LintPolicy{
+		Rules: map[string]config.LintLevel{
+			config.LintRuleAllowedLabelValues: config.LintError,
+			config.LintRuleMaxMemory:          config.LintError,
+			config.LintRuleHealthCheckType:    config.LintWarn,
+			config.LintRuleNoRoute:            config.LintOff,
+			config.LintRuleMissingTimeout:     config.LintOff,
+		},
+		AllowedLabelValues: map[string][]string{"environment": {"dev", "live"}},
+		MaxMemory:          "2G",
+	}
+
+	labels := lintLabels{manifest: appLintPlan{}.getLabelsForApp(man)}
+	assert.False(t, appLintPlan{}.needsSpaceLabels(policy, labels.manifest))
+
+	results := appLintPlan{}.lint(man, policy, labels)
+	assert.Equal(t, []LintResult{
+		{Rule: config.LintRuleAllowedLabelValues, Level: config.LintError, Message: "'environment' is 'prod' in manifest, must be one of dev, live", DocsURL: labelsDocsURL},
+		{Rule: config.LintRuleMaxMemory, Level: config.LintError, Message: "memory of 'myApp' is 4096MB, must be at most 2048MB", DocsURL: "https://docs.cloudfoundry.org/devguide/deploy-apps/manifest-attributes.html#memory"},
+		{Rule: config.LintRuleHealthCheckType, Level: config.LintWarn, Message: "'health-check-type' is not set, use 'http' together with 'health-check-http-endpoint' for apps with routes and 'process' for workers", DocsURL: "https://docs.cloudfoundry.org/devguide/deploy-apps/manifest-attributes.html#health-check-type"},
+	}, results)
+
+	t.Run("errors fail the lint", func(t *testing.T) {
+		request := validRequest
+		request.Params.LintPolicy = policy
+
+		err := appLintPlan{}.createFunc(man, request)(nil, &discardLogger)
+		assert.EqualError(t, err, "linting failed with 2 error(s), see the lint policy for the rules that fail the deploy")
+	})
+
+	t.Run("warnings do not", func(t *testing.T) {
+		request := validRequest
+		request.Params.LintPolicy = config.LintPolicy{Format: config.LintFormatJSON}
+
+		assert.NoError(t, appLintPlan{}.createFunc(man, request)(nil, &discardLogger))
+	})
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 
 	cfclient "github.com/cloudfoundry/go-cfclient/v3/client"
+	cfconfig "github.com/cloudfoundry/go-cfclient/v3/config"
 	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/springernature/halfpipe-deploy-resource/config"
 )
 
 // getOrgAndSpace finds the org and space resources for the given org/space names.
@@ -35,3 +37,33 @@ func getAppsInOrgSpace(ctx context.Context, cf *cfclient.Client, orgName, spaceN
 	apps, err = cf.Applications.ListAll(ctx, appOpts)
 	return
 }
+
+// GetApps logs in to the api of the request and returns the client together with the apps in the space
+// and the domains of the org, which the planner needs before any command runs.
+func GetApps(request config.Request) (client *cfclient.Client, appSummary []*resource.App, privateDomains []*resource.Domain, err error) {
+	ctx := context.Background()
+
+	c, err := cfconfig.New(request.Source.API, cfconfig.UserPassword(request.Source.Username, request.Source.Password))
+	if err != nil {
+		return
+	}
+	client, err = cfclient.New(c)
+	if err != nil {
+		return
+	}
+
+	org, space, err := getOrgAndSpace(ctx, client, request.Source.Org, request.Source.Space)
+	if err != nil {
+		return
+	}
+
+	appOpts := cfclient.NewAppListOptions()
+	appOpts.SpaceGUIDs = cfclient.Filter{Values: []string{space.GUID}}
+	appSummary, err = client.Applications.ListAll(ctx, appOpts)
+	if err != nil {
+		return
+	}
+
+	privateDomains, err = client.Domains.ListForOrganizationAll(ctx, org.GUID, cfclient.NewDomainListOptions())
+	return
+}
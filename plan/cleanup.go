@@ -2,26 +2,135 @@ package plan
 
 import (
 	"code.cloudfoundry.org/cli/util/manifestparser"
+	"context"
+	"fmt"
+	cfclient "github.com/cloudfoundry/go-cfclient/v3/client"
 	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/springernature/halfpipe-deploy-resource/logger"
+	"sort"
 	"strings"
+	"time"
 )
 
 type CleanupPlan interface {
-	Plan(manifest manifestparser.Application, summary []*resource.App) (pl Plan)
+	Plan(manifest manifestparser.Application, retention config.Retention, summary []*resource.App) (pl Plan)
 }
 
 type cleanupPlan struct {
+	now func() time.Time
 }
 
-func (p cleanupPlan) Plan(manifest manifestparser.Application, summary []*resource.App) (pl Plan) {
-	for _, app := range summary {
-		if strings.HasPrefix(app.Name, createDeleteName(manifest.Name, 0)) {
-			pl = append(pl, NewCfCommand("delete", app.Name, "-f"))
+// Plan deletes the previous releases. Without a retention they are all deleted with cf, otherwise the description
+// has the decision for each release and the releases that are kept are stopped.
+func (p cleanupPlan) Plan(manifest manifestparser.Application, retention config.Retention, summary []*resource.App) (pl Plan) {
+	decisions := decideReleases(manifest.Name, summary, retention, p.now())
+	if len(decisions) == 0 {
+		return
+	}
+
+	if retention.IsEmpty() {
+		for _, decision := range decisions {
+			pl = append(pl, NewCfCommand("delete", decision.app.Name, "-f"))
 		}
+		return
+	}
+
+	var described []string
+	for _, decision := range decisions {
+		described = append(described, decision.String())
+	}
+	desc := fmt.Sprintf("Cleaning up the previous releases of '%s', %s: %s", manifest.Name, retention, strings.Join(described, "; "))
+	pl = append(pl, NewClientCommand(func(cfClient *cfclient.Client, logger *logger.CapturingWriter) error {
+		return applyReleaseDecisions(context.Background(), cfClient, logger, decisions)
+	}, desc))
+	return
+}
+
+type releaseDecision struct {
+	app    *resource.App
+	keep   bool
+	reason string
+}
+
+func (d releaseDecision) String() string {
+	switch {
+	case !d.keep:
+		return fmt.Sprintf("deleting '%s', %s", d.app.Name, d.reason)
+	case d.app.State == "STARTED":
+		return fmt.Sprintf("keeping and stopping '%s', %s", d.app.Name, d.reason)
+	}
+	return fmt.Sprintf("keeping '%s', %s", d.app.Name, d.reason)
+}
+
+// applyReleaseDecisions deletes the previous releases that are not kept and stops the ones that are.
+func applyReleaseDecisions(ctx context.Context, cfClient *cfclient.Client, logger *logger.CapturingWriter, decisions []releaseDecision) error {
+	for _, decision := range decisions {
+		logger.Println(fmt.Sprintf("%s%s", strings.ToUpper(decision.String()[:1]), decision.String()[1:]))
+		switch {
+		case !decision.keep:
+			if _, err := cfClient.Applications.Delete(ctx, decision.app.GUID); err != nil {
+				return err
+			}
+		case decision.app.State == "STARTED":
+			if _, err := cfClient.Applications.Stop(ctx, decision.app.GUID); err != nil {
+				return err
+			}
+		}
+	}
+	logger.Println("OK")
+	return nil
+}
+
+// deployedAt is when the release was deployed, from its provenance or else when the app was created. Unlike the time
+// the app was last updated, it does not change when the app is renamed, stopped or annotated.
+func deployedAt(app *resource.App) time.Time {
+	if deployed, err := time.Parse(time.RFC3339, readProvenance(app.Metadata).DeployedAt); err == nil {
+		return deployed
+	}
+	return app.CreatedAt
+}
+
+// decideReleases finds the previous releases of the app, the <app>-DELETE apps, and decides which to keep
+// based on when they were deployed rather than on their name, as the index in the name is reused.
+func decideReleases(appName string, apps []*resource.App, retention config.Retention, now time.Time) (decisions []releaseDecision) {
+	var previousReleases []*resource.App
+	for _, app := range apps {
+		if strings.HasPrefix(app.Name, createDeleteName(appName, 0)) {
+			previousReleases = append(previousReleases, app)
+		}
+	}
+
+	sort.SliceStable(previousReleases, func(i, j int) bool {
+		a, b := previousReleases[i], previousReleases[j]
+		if !deployedAt(a).Equal(deployedAt(b)) {
+			return deployedAt(a).After(deployedAt(b))
+		}
+		return a.CreatedAt.After(b.CreatedAt)
+	})
+
+	for i, app := range previousReleases {
+		age := now.Sub(deployedAt(app)).Round(time.Minute)
+		decision := releaseDecision{app: app}
+		switch {
+		case retention.LimitsCount() && i >= retention.Count:
+			decision.reason = fmt.Sprintf("release %d is older than the %d most recent previous releases", i+1, retention.Count)
+		case retention.MaxAge != 0 && age > retention.MaxAge:
+			decision.reason = fmt.Sprintf("deployed %s ago which is more than %s", age, retention.MaxAge)
+		case !retention.LimitsCount():
+			decision.keep = true
+			decision.reason = fmt.Sprintf("release %d, deployed %s ago which is within %s", i+1, age, retention.MaxAge)
+		default:
+			decision.keep = true
+			decision.reason = fmt.Sprintf("release %d of %d to keep, deployed %s ago", i+1, retention.Count, age)
+		}
+		decisions = append(decisions, decision)
 	}
 	return
 }
 
 func NewCleanupPlan() CleanupPlan {
-	return cleanupPlan{}
+	return cleanupPlan{
+		now: time.Now,
+	}
 }
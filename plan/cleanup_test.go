@@ -3,8 +3,10 @@ package plan
 import (
 	"github.com/cloudfoundry/go-cfclient/v3/resource"
 	halfpipe_deploy_resource "github.com/springernature/halfpipe-deploy-resource"
+	"github.com/springernature/halfpipe-deploy-resource/config"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestDoesNothingWhenNoAppsToCleanup(t *testing.T) {
@@ -18,7 +20,7 @@ func TestDoesNothingWhenNoAppsToCleanup(t *testing.T) {
 	man := halfpipe_deploy_resource.ParseManifest(`applications:
 - name: MyApp`).Applications[0]
 
-	p := NewCleanupPlan().Plan(man, summary)
+	p := NewCleanupPlan().Plan(man, config.Retention{}, summary)
 	assert.Empty(t, p)
 
 }
@@ -41,7 +43,7 @@ func TestDeletesAppThatNeedsACleanup(t *testing.T) {
 		NewCfCommand("delete", "myApp-DELETE", "-f"),
 	}
 
-	p := NewCleanupPlan().Plan(man, summary)
+	p := NewCleanupPlan().Plan(man, config.Retention{}, summary)
 
 	assert.Equal(t, expectedPlan, p)
 }
@@ -76,7 +78,58 @@ func TestDeletesAppsThatNeedsACleanup(t *testing.T) {
 		NewCfCommand("delete", "myApp-DELETE-2", "-f"),
 	}
 
-	p := NewCleanupPlan().Plan(man, summary)
+	p := NewCleanupPlan().Plan(man, config.Retention{}, summary)
 
 	assert.Equal(t, expectedPlan, p)
 }
+
+func TestKeepsPreviousReleasesAccordingToRetention(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	deployed := resource.NewMetadata()
+	deployed.SetAnnotation(annotationPrefix, "deployed-at", now.Add(-1*time.Hour).Format(time.RFC3339))
+	summary := []*resource.App{
+		{Name: "myApp", State: "STARTED", Resource: resource.Resource{CreatedAt: now, UpdatedAt: now}},
+		{Name: "myApp-DELETE", State: "STOPPED", Resource: resource.Resource{CreatedAt: now.Add(-72 * time.Hour), UpdatedAt: now}},
+		{Name: "myApp-DELETE-1", State: "STOPPED", Resource: resource.Resource{CreatedAt: now.Add(-96 * time.Hour), UpdatedAt: now.Add(-1 * time.Hour)}, Metadata: deployed},
+		{Name: "myApp-DELETE-2", State: "STARTED", Resource: resource.Resource{CreatedAt: now.Add(-2 * time.Hour), UpdatedAt: now.Add(-2 * time.Hour)}},
+		{Name: "myApp-DELETE-3", State: "STOPPED", Resource: resource.Resource{CreatedAt: now.Add(-3 * time.Hour), UpdatedAt: now.Add(-3 * time.Hour)}},
+	}
+
+	man := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp`).Applications[0]
+
+	p := cleanupPlan{now: func() time.Time { return now }}
+
+	t.Run("count", func(t *testing.T) {
+		pl := p.Plan(man, config.Retention{Count: 2}, summary)
+
+		assert.Len(t, pl, 1)
+		assert.Equal(t, "Cleaning up the previous releases of 'myApp', keeping the 2 most recent previous releases: "+
+			"keeping 'myApp-DELETE-1', release 1 of 2 to keep, deployed 1h0m0s ago; "+
+			"keeping and stopping 'myApp-DELETE-2', release 2 of 2 to keep, deployed 2h0m0s ago; "+
+			"deleting 'myApp-DELETE-3', release 3 is older than the 2 most recent previous releases; "+
+			"deleting 'myApp-DELETE', release 4 is older than the 2 most recent previous releases", pl[0].String())
+	})
+
+	t.Run("count and age", func(t *testing.T) {
+		pl := p.Plan(man, config.Retention{Count: 10, MaxAge: 90 * time.Minute}, summary)
+
+		assert.Len(t, pl, 1)
+		assert.Equal(t, "Cleaning up the previous releases of 'myApp', keeping the 10 most recent previous releases deployed within 1h30m0s: "+
+			"keeping 'myApp-DELETE-1', release 1 of 10 to keep, deployed 1h0m0s ago; "+
+			"deleting 'myApp-DELETE-2', deployed 2h0m0s ago which is more than 1h30m0s; "+
+			"deleting 'myApp-DELETE-3', deployed 3h0m0s ago which is more than 1h30m0s; "+
+			"deleting 'myApp-DELETE', deployed 72h0m0s ago which is more than 1h30m0s", pl[0].String())
+	})
+
+	t.Run("age", func(t *testing.T) {
+		pl := p.Plan(man, config.Retention{MaxAge: 150 * time.Minute}, summary)
+
+		assert.Len(t, pl, 1)
+		assert.Equal(t, "Cleaning up the previous releases of 'myApp', keeping the previous releases deployed within 2h30m0s: "+
+			"keeping 'myApp-DELETE-1', release 1, deployed 1h0m0s ago which is within 2h30m0s; "+
+			"keeping and stopping 'myApp-DELETE-2', release 2, deployed 2h0m0s ago which is within 2h30m0s; "+
+			"deleting 'myApp-DELETE-3', deployed 3h0m0s ago which is more than 2h30m0s; "+
+			"deleting 'myApp-DELETE', deployed 72h0m0s ago which is more than 2h30m0s", pl[0].String())
+	})
+}
@@ -0,0 +1,47 @@
+package plan
+
+import (
+	"github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/springernature/halfpipe-deploy-resource/logger"
+)
+
+// ResolveCommand decides which command to execute from the state of CF when it is executed, nil when there is nothing to do.
+type ResolveCommand func(client *client.Client, logger *logger.CapturingWriter) (Command, error)
+
+type dynamicCommand struct {
+	resolve     ResolveCommand
+	description string
+}
+
+func (c dynamicCommand) String() string {
+	return c.description
+}
+
+func (c dynamicCommand) Args() []string {
+	panic("Args should never be called on a dynamicCommand")
+}
+
+func (c dynamicCommand) Env() []string {
+	panic("Env should never be called on a dynamicCommand")
+}
+
+func (c dynamicCommand) AddToArgs(args ...string) Command {
+	panic("AddToArgs should never be called on a dynamicCommand")
+}
+
+func (c dynamicCommand) AddToEnv(env ...string) Command {
+	panic("AddToEnv should never be called on a dynamicCommand")
+}
+
+func (c dynamicCommand) Cmd() string {
+	return ""
+}
+
+// NewDynamicCommand is a command that is resolved when it is executed rather than when it is planned,
+// for steps that depend on what the steps before them did.
+func NewDynamicCommand(resolve ResolveCommand, description string) Command {
+	return dynamicCommand{
+		resolve:     resolve,
+		description: description,
+	}
+}
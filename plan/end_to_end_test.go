@@ -0,0 +1,383 @@
+package plan_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/springernature/halfpipe-deploy-resource/fakecf"
+	"github.com/springernature/halfpipe-deploy-resource/logger"
+	"github.com/springernature/halfpipe-deploy-resource/manifest"
+	"github.com/springernature/halfpipe-deploy-resource/plan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const endToEndManifest = `applications:
+- name: my-app
+  instances: 2
+  memory: 256M
+  routes:
+  - route: my-app.domain.com
+  - route: my-app.private.com/api
+`
+
+// endToEnd runs the plans like the out binary does, but against a fakecf server.
+type endToEnd struct {
+	t                 *testing.T
+	server            *fakecf.Server
+	manifestPath      string
+	manifestReadWrite manifest.ReaderWriter
+	output            bytes.Buffer
+}
+
+func newEndToEnd(t *testing.T, manifestContent string) *endToEnd {
+	server := fakecf.New("my-org", "my-space")
+	t.Cleanup(server.Close)
+	server.AddSharedDomain("domain.com")
+	server.AddSharedDomain("test.com")
+	server.AddPrivateDomain("private.com")
+
+	// The manifest is always read from disk.
+	manifestPath := filepath.Join(t.TempDir(), "manifest.yml")
+	require.NoError(t, os.WriteFile(manifestPath, []byte(manifestContent), 0600))
+
+	return &endToEnd{
+		t:                 t,
+		server:            server,
+		manifestPath:      manifestPath,
+		manifestReadWrite: manifest.NewManifestReadWrite(afero.Afero{Fs: afero.NewOsFs()}),
+	}
+}
+
+func (e *endToEnd) request(command string) config.Request {
+	return config.Request{
+		Source: e.server.Source(),
+		Params: config.Params{
+			Command:      command,
+			ManifestPath: e.manifestPath,
+			AppPath:      "target/app.jar",
+			TestDomain:   "test.com",
+			CliVersion:   "cf7",
+			Team:         "my-team",
+		},
+		Metadata: config.Metadata{
+			GitRef:     "0123456789abcdef",
+			Version:    "1",
+			DeployedBy: "https://ci/builds/1",
+			AppName:    "my-app",
+		},
+	}
+}
+
+func (e *endToEnd) run(request config.Request) error {
+	cfClient, appsSummary, privateDomains, err := plan.GetApps(request)
+	require.NoError(e.t, err)
+
+	e.output.Reset()
+	log := logger.NewLogger(&e.output)
+
+	resumer := plan.NewResumer(request, cfClient)
+	appsToPlanWith, err := resumer.AppsToPlanWith(appsSummary)
+	if err != nil {
+		return err
+	}
+
+	liveHasNetworkPolicies, err := plan.LiveHasNetworkPolicies(cfClient, request, appsToPlanWith)
+	require.NoError(e.t, err)
+	inherited, err := plan.LookupInherited(cfClient, request, appsToPlanWith)
+	require.NoError(e.t, err)
+
+	p, err := plan.NewPlanner(e.manifestReadWrite, plan.NewPushPlan(liveHasNetworkPolicies, inherited), plan.NewCheckPlan(), plan.NewPromotePlan(privateDomains), plan.NewCleanupPlan(), plan.NewRollingDeployPlan(), plan.NewDeleteCandidatePlan(), plan.NewStopCandidatePlan(), plan.NewLogsPlan(), plan.NewCheckLabelsPlan(), plan.NewSSOPlan(),
+		plan.NewRouteCheckPlan(), plan.NewQuotaPlan(inherited), plan.NewServicesPlan(), plan.NewProvenancePlan(), plan.NewRunTaskPlan(), plan.NewDynamicCleanupPlan(), plan.NewRouteCleanupPlan(),
+		plan.NewStatusPlan(), plan.NewRestartPlan(), plan.NewRestagePlan(), plan.NewScalePlan(), plan.NewSetEnvPlan(), plan.NewLintPlan()).Plan(request, appsToPlanWith)
+	require.NoError(e.t, err)
+
+	return resumer.Execute(p, appsToPlanWith, fakecf.NewExecutor(e.server, e.manifestReadWrite, &log), &log, time.Minute, false)
+}
+
+// execute executes a single plan, e.g. the plan of a single step, rather than the plans of a command.
+func (e *endToEnd) execute(p plan.Plan) error {
+	cfClient, _, _, err := plan.GetApps(e.request(config.PUSH))
+	require.NoError(e.t, err)
+
+	e.output.Reset()
+	log := logger.NewLogger(&e.output)
+	return p.Execute(fakecf.NewExecutor(e.server, e.manifestReadWrite, &log), cfClient, &log, time.Minute, false)
+}
+
+func (e *endToEnd) mustRun(command string) {
+	if err := e.run(e.request(command)); err != nil {
+		e.t.Fatalf("%s failed: %s\n%s", command, err, e.output.String())
+	}
+}
+
+func TestEndToEndPushCheckPromoteCleanup(t *testing.T) {
+	e := newEndToEnd(t, endToEndManifest)
+
+	e.mustRun(config.PUSH)
+	assert.Equal(t, []string{"my-app-CANDIDATE"}, e.server.AppNames())
+	assert.Equal(t, "STARTED", e.server.App("my-app-CANDIDATE").State)
+	assert.Equal(t, []string{"my-app-my-space-CANDIDATE.test.com"}, e.server.Routes("my-app-CANDIDATE"))
+	assert.Equal(t, "0123456789abcdef", *e.server.App("my-app-CANDIDATE").Metadata.Annotations["halfpipe.io/git-ref"])
+
+	e.mustRun(config.CHECK)
+	assert.Contains(t, e.output.String(), "2/2 instances running")
+
+	e.mustRun(config.PROMOTE)
+	assert.Equal(t, []string{"my-app"}, e.server.AppNames())
+	assert.Equal(t, []string{"my-app.domain.com", "my-app.private.com/api"}, e.server.Routes("my-app"))
+
+	e.mustRun(config.CLEANUP)
+	assert.Equal(t, []string{"my-app.domain.com", "my-app.private.com/api"}, e.server.RouteURLs(), "the unmapped candidate route is deleted")
+
+	firstLive := e.server.App("my-app").GUID
+	for range 2 {
+		e.mustRun(config.PUSH)
+		e.mustRun(config.CHECK)
+		e.mustRun(config.PROMOTE)
+		e.mustRun(config.CLEANUP)
+	}
+
+	assert.Equal(t, []string{"my-app", "my-app-OLD"}, e.server.AppNames())
+	assert.Equal(t, "STARTED", e.server.App("my-app").State)
+	assert.Equal(t, "STOPPED", e.server.App("my-app-OLD").State)
+	assert.Equal(t, []string{"my-app.domain.com", "my-app.private.com/api"}, e.server.Routes("my-app-OLD"), "the previous live app keeps its routes until it is deleted")
+	assert.NotEqual(t, firstLive, e.server.App("my-app-OLD").GUID, "the first live app has been deleted by the cleanup")
+}
+
+func TestEndToEndCleanupOnlyDeletesRoutesOfTheApp(t *testing.T) {
+	e := newEndToEnd(t, endToEndManifest)
+	require.NoError(t, e.server.AddRoute("my-space", "other-app", "domain.com"))
+	require.NoError(t, e.server.AddRoute("my-space", "my-app-CANDIDATE", "domain.com"))
+
+	e.mustRun(config.ALL)
+
+	assert.Equal(t, []string{"my-app.domain.com", "my-app.private.com/api", "other-app.domain.com"}, e.server.RouteURLs())
+	assert.Contains(t, e.output.String(), "Keeping route other-app.domain.com, the hostname is not one of 'my-app'")
+}
+
+func TestEndToEndAll(t *testing.T) {
+	e := newEndToEnd(t, endToEndManifest)
+
+	for range 3 {
+		e.mustRun(config.ALL)
+	}
+
+	assert.Equal(t, []string{"my-app", "my-app-OLD"}, e.server.AppNames())
+	assert.Equal(t, []string{"my-app.domain.com", "my-app.private.com/api"}, e.server.Routes("my-app"))
+	assert.Equal(t, []string{"my-app.domain.com", "my-app.private.com/api"}, e.server.RouteURLs())
+}
+
+func TestEndToEndCopiesNetworkPolicies(t *testing.T) {
+	e := newEndToEnd(t, endToEndManifest)
+	e.mustRun(config.ALL)
+	e.mustRun(config.ALL)
+	assert.NotContains(t, e.output.String(), "Copying network policies", "the live app has no network policies")
+
+	require.NoError(t, e.server.AddNetworkPolicy("my-app-OLD", "my-app"))
+	e.mustRun(config.PUSH)
+
+	assert.Contains(t, e.output.String(), "Copying network policies of 'my-app' to 'my-app-CANDIDATE'")
+	assert.Equal(t, []string{"my-app-OLD -> my-app", "my-app-OLD -> my-app-CANDIDATE"}, e.server.NetworkPolicies())
+}
+
+func TestEndToEndWithoutTheNetworkPolicyAPI(t *testing.T) {
+	e := newEndToEnd(t, endToEndManifest)
+	e.server.WithoutNetworkPolicyAPI()
+
+	e.mustRun(config.ALL)
+	e.mustRun(config.ALL)
+
+	assert.NotContains(t, e.output.String(), "Copying network policies")
+	assert.Equal(t, []string{"my-app", "my-app-OLD"}, e.server.AppNames())
+}
+
+func TestEndToEndKeepsReleases(t *testing.T) {
+	e := newEndToEnd(t, endToEndManifest)
+
+	request := e.request(config.ALL)
+	request.Params.KeepReleases = 2
+	for range 5 {
+		require.NoError(t, e.run(request), e.output.String())
+	}
+
+	assert.Equal(t, []string{"my-app", "my-app-DELETE-1", "my-app-DELETE-2", "my-app-OLD"}, e.server.AppNames(), "the oldest release is deleted")
+	for _, name := range []string{"my-app-DELETE-1", "my-app-DELETE-2", "my-app-OLD"} {
+		assert.Equal(t, "STOPPED", e.server.App(name).State, name)
+	}
+}
+
+func TestEndToEndKeepsRecentReleases(t *testing.T) {
+	e := newEndToEnd(t, endToEndManifest)
+
+	request := e.request(config.ALL)
+	request.Params.KeepReleasesMaxAge = "1h"
+	for range 5 {
+		require.NoError(t, e.run(request), e.output.String())
+	}
+
+	assert.Equal(t, []string{"my-app", "my-app-DELETE", "my-app-DELETE-1", "my-app-DELETE-2", "my-app-OLD"}, e.server.AppNames(), "all releases are recent")
+	assert.Contains(t, e.output.String(), "Finding old apps to delete, keeping the previous releases deployed within 1h0m0s")
+}
+
+func TestEndToEndCandidateThatDoesNotStart(t *testing.T) {
+	e := newEndToEnd(t, endToEndManifest)
+	e.mustRun(config.ALL)
+
+	e.server.CrashOnStart("my-app-CANDIDATE")
+	assert.Error(t, e.run(e.request(config.PUSH)))
+	assert.Contains(t, e.output.String(), "Retrieving logs for app my-app-CANDIDATE")
+	assert.Equal(t, "logs my-app-CANDIDATE --recent", e.server.Executions()[len(e.server.Executions())-1])
+	assert.Equal(t, "STARTED", e.server.App("my-app").State, "the live app is not touched")
+}
+
+func TestEndToEndRouteOfAnotherSpace(t *testing.T) {
+	e := newEndToEnd(t, endToEndManifest)
+	e.server.AddSpace("other-space")
+	require.NoError(t, e.server.AddRoute("other-space", "my-app", "domain.com"))
+
+	err := e.run(e.request(config.PUSH))
+	assert.ErrorContains(t, err, "route 'my-app.domain.com' belongs to another space")
+	assert.Empty(t, e.server.AppNames(), "nothing is pushed")
+}
+
+// promoteThatFailsToRenameTheCandidate leaves the previous live app renamed to my-app-OLD and stopped, and no live app.
+func promoteThatFailsToRenameTheCandidate(t *testing.T, e *endToEnd) {
+	e.mustRun(config.ALL)
+	e.mustRun(config.PUSH)
+	e.mustRun(config.CHECK)
+
+	e.server.FailOn(errors.New("Server error, status code: 502"), "rename", "my-app-CANDIDATE", "my-app")
+	assert.Error(t, e.run(e.request(config.PROMOTE)))
+	e.server.FailOn(nil, "rename", "my-app-CANDIDATE", "my-app")
+
+	assert.Equal(t, []string{"my-app-CANDIDATE", "my-app-OLD"}, e.server.AppNames())
+	assert.Contains(t, e.output.String(), "Step 10 of 10 failed, fix the cause and rerun with resume to continue from it")
+	assert.Contains(t, e.server.AppAnnotations("my-app-CANDIDATE"), "halfpipe.io/progress")
+	assert.Empty(t, e.server.SpaceAnnotations(), "the progress is not recorded on the space")
+}
+
+func TestEndToEndResumePromote(t *testing.T) {
+	e := newEndToEnd(t, endToEndManifest)
+	promoteThatFailsToRenameTheCandidate(t, e)
+	previousLive := e.server.App("my-app-OLD").GUID
+	candidate := e.server.App("my-app-CANDIDATE").GUID
+
+	request := e.request(config.PROMOTE)
+	request.Params.Resume = true
+	require.NoError(t, e.run(request), e.output.String())
+
+	assert.Contains(t, e.output.String(), "Resuming at step 10 of 10, 'cf rename my-app-CANDIDATE my-app'")
+	assert.Equal(t, []string{"my-app", "my-app-OLD"}, e.server.AppNames())
+	assert.Equal(t, candidate, e.server.App("my-app").GUID)
+	assert.Equal(t, previousLive, e.server.App("my-app-OLD").GUID, "the previous live app is not renamed again")
+	assert.Equal(t, "rename my-app-CANDIDATE my-app", e.server.Executions()[len(e.server.Executions())-1])
+	assert.NotContains(t, e.server.AppAnnotations("my-app"), "halfpipe.io/progress", "the progress is removed when the plan succeeds")
+}
+
+func TestEndToEndResumeAsASpaceDeveloper(t *testing.T) {
+	e := newEndToEnd(t, endToEndManifest)
+	e.server.RejectSpaceUpdates()
+	promoteThatFailsToRenameTheCandidate(t, e)
+
+	request := e.request(config.PROMOTE)
+	request.Params.Resume = true
+	require.NoError(t, e.run(request), e.output.String())
+
+	assert.Contains(t, e.output.String(), "Resuming at step 10 of 10, 'cf rename my-app-CANDIDATE my-app'")
+	assert.Equal(t, []string{"my-app", "my-app-OLD"}, e.server.AppNames())
+	assert.NotContains(t, e.server.AppAnnotations("my-app"), "halfpipe.io/progress")
+}
+
+func TestEndToEndResumeRefusesWhenTheAppsHaveChanged(t *testing.T) {
+	e := newEndToEnd(t, endToEndManifest)
+	promoteThatFailsToRenameTheCandidate(t, e)
+	e.mustRun(config.STOP_CANDIDATE)
+
+	request := e.request(config.PROMOTE)
+	request.Params.Resume = true
+	err := e.run(request)
+
+	assert.ErrorContains(t, err, "refusing to resume: the apps have changed since step 10, 'cf rename my-app-CANDIDATE my-app', failed")
+	assert.ErrorContains(t, err, "'my-app-CANDIDATE' was STARTED and is now STOPPED")
+	assert.Equal(t, []string{"my-app-CANDIDATE", "my-app-OLD"}, e.server.AppNames(), "nothing is executed")
+}
+
+func TestEndToEndResumeRefusesWithoutAFailure(t *testing.T) {
+	e := newEndToEnd(t, endToEndManifest)
+	e.mustRun(config.ALL)
+
+	request := e.request(config.PROMOTE)
+	request.Params.Resume = true
+	assert.ErrorContains(t, e.run(request), "there is no failed halfpipe-promote of 'my-app' to resume in space 'my-space'")
+}
+
+func TestEndToEndPromoteAgainAfterAFailure(t *testing.T) {
+	e := newEndToEnd(t, endToEndManifest)
+	promoteThatFailsToRenameTheCandidate(t, e)
+	previousLive := e.server.App("my-app-OLD").GUID
+	candidate := e.server.App("my-app-CANDIDATE").GUID
+
+	e.mustRun(config.PROMOTE)
+
+	assert.Contains(t, e.output.String(), "There is no 'my-app', so 'my-app-OLD' is kept")
+	assert.Equal(t, []string{"my-app", "my-app-OLD"}, e.server.AppNames())
+	assert.Equal(t, candidate, e.server.App("my-app").GUID)
+	assert.Equal(t, previousLive, e.server.App("my-app-OLD").GUID, "the previous live app is not renamed again")
+	assert.Equal(t, []string{"my-app.domain.com", "my-app.private.com/api"}, e.server.Routes("my-app"))
+	assert.Contains(t, e.server.AppAnnotations("my-app"), "halfpipe.io/progress", "only a resumed execution removes the progress")
+
+	request := e.request(config.PROMOTE)
+	request.Params.Resume = true
+	assert.ErrorContains(t, e.run(request), "refusing to resume: the apps have changed since step 10")
+}
+
+func TestEndToEndPromoteAgainAfterASuccess(t *testing.T) {
+	e := newEndToEnd(t, endToEndManifest)
+	e.mustRun(config.ALL)
+	e.mustRun(config.PUSH)
+	e.mustRun(config.CHECK)
+	e.mustRun(config.PROMOTE)
+	executions := len(e.server.Executions())
+	updatedAt := e.server.App("my-app").UpdatedAt
+
+	e.mustRun(config.PROMOTE)
+
+	assert.Contains(t, e.output.String(), "'my-app-CANDIDATE' has already been promoted to 'my-app'")
+	assert.Equal(t, []string{"--version", "login"}, func() (commands []string) {
+		for _, execution := range e.server.Executions()[executions:] {
+			commands = append(commands, strings.Fields(execution)[0])
+		}
+		return
+	}(), "nothing but the login is executed")
+	assert.Equal(t, []string{"my-app", "my-app-OLD"}, e.server.AppNames())
+	assert.Equal(t, updatedAt, e.server.App("my-app").UpdatedAt, "the progress is not written when nothing fails")
+}
+
+func TestEndToEndPromoteWithoutACandidate(t *testing.T) {
+	e := newEndToEnd(t, endToEndManifest)
+
+	assert.ErrorContains(t, e.run(e.request(config.PROMOTE)), "neither 'my-app-CANDIDATE' nor 'my-app' exist, there is nothing to promote")
+}
+
+func TestEndToEndPromoteWithoutACandidateOfTheVersion(t *testing.T) {
+	e := newEndToEnd(t, endToEndManifest)
+	e.mustRun(config.ALL)
+	executions := len(e.server.Executions())
+
+	request := e.request(config.PROMOTE)
+	request.Metadata.GitRef = "fedcba9876543210"
+	request.Metadata.Version = "2"
+	err := e.run(request)
+
+	assert.EqualError(t, err, "there is no 'my-app-CANDIDATE' to promote and 'my-app' is not version '2' of git ref 'fedcba9876543210', push the candidate first")
+	assert.Len(t, e.server.Executions(), executions+2, "nothing but the login is executed")
+	assert.Equal(t, []string{"my-app"}, e.server.AppNames())
+}
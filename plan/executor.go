@@ -25,6 +25,15 @@ func NewCFCliExecutor(logger *logger.CapturingWriter, request config.Request) Ex
 	}
 }
 
+// NewCFBinaryExecutor executes cf commands with the given cf binary instead of the one for the cliVersion of the request,
+// e.g. with 'cf' when running outside the docker image.
+func NewCFBinaryExecutor(logger *logger.CapturingWriter, binary string) Executor {
+	return cfCLIExecutor{
+		logger:    logger,
+		cfVersion: binary,
+	}
+}
+
 func (c cfCLIExecutor) CliCommand(command Command) (out []string, err error) {
 	var execCmd *exec.Cmd
 
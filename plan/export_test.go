@@ -0,0 +1,23 @@
+package plan
+
+import (
+	"time"
+
+	cfclient "github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+)
+
+// testPollingOptions poll the jobs of fakecf often, so that the tests don't wait as long as the plans do against a real CF.
+var testPollingOptions = &cfclient.PollingOptions{
+	FailedState:   string(resource.JobStateFailed),
+	Timeout:       time.Minute,
+	CheckInterval: 10 * time.Millisecond,
+}
+
+func NewServicesPlanForTest() ServicesPlan {
+	return servicesPlan{pollingOptions: testPollingOptions}
+}
+
+func NewSSOPlanForTest() SSOPlan {
+	return ssoPlan{pollingOptions: testPollingOptions}
+}
@@ -3,10 +3,14 @@ package plan
 import (
 	"code.cloudfoundry.org/cli/util/manifestparser"
 	"fmt"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
 	"github.com/springernature/halfpipe-deploy-resource/config"
 	"strings"
 )
 
+// annotationPrefix namespaces the annotations the resource sets on apps.
+const annotationPrefix = "halfpipe.io"
+
 func createCandidateAppName(name string) string {
 	return fmt.Sprintf("%s-CANDIDATE", name)
 }
@@ -28,3 +32,26 @@ func createDeleteName(name string, index int) string {
 	}
 	return fmt.Sprintf("%s-DELETE-%d", name, index)
 }
+
+func manifestRoutes(man manifestparser.Application) (rs []string) {
+	rawRoutes := []any{}
+
+	if man.RemainingManifestFields["routes"] != nil {
+		rawRoutes = man.RemainingManifestFields["routes"].([]any)
+	}
+
+	for _, r := range rawRoutes {
+		route := r.(map[any]any)["route"].(string)
+		rs = append(rs, route)
+	}
+	return rs
+}
+
+func findApp(name string, apps []*resource.App) *resource.App {
+	for _, app := range apps {
+		if app.Name == name {
+			return app
+		}
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package plan
+
+import (
+	"code.cloudfoundry.org/cli/util/manifestparser"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	cfclient "github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/springernature/halfpipe-deploy-resource/logger"
+)
+
+type InheritPlan interface {
+	Plan(manifest manifestparser.Application, request config.Request) (pl Plan)
+}
+
+// Inherited are the scale and the env of the live app, looked up before planning so that the plan shows what
+// the candidate inherits.
+type Inherited struct {
+	// Live is false when there is no live app, e.g. on the very first deploy.
+	Live      bool
+	Processes []*resource.Process
+	Env       map[string]*string
+}
+
+type inheritPlan struct {
+	inherited      Inherited
+	pollingOptions *cfclient.PollingOptions
+}
+
+func (p inheritPlan) Plan(manifest manifestparser.Application, request config.Request) (pl Plan) {
+	inherit := request.Params.Inherit
+	live, candidate := manifest.Name, createCandidateAppName(manifest.Name)
+	org, space := request.Source.Org, request.Source.Space
+
+	if inherit.Scale {
+		desc := fmt.Sprintf("Inheriting instances, memory and disk of '%s' for '%s': %s", live, candidate, p.describeScale())
+		pl = append(pl, NewClientCommand(p.withApps(live, candidate, org, space, p.inheritScale), desc))
+	}
+
+	if len(inherit.Env) > 0 {
+		desc := fmt.Sprintf("Inheriting env of '%s' for '%s': %s", live, candidate, p.describeEnv(inherit.Env))
+		pl = append(pl, NewClientCommand(p.withApps(live, candidate, org, space, p.inheritEnv(inherit.Env)), desc))
+	}
+
+	if inherit.Services {
+		desc := fmt.Sprintf("Inheriting service bindings of '%s' for '%s'", live, candidate)
+		pl = append(pl, NewClientCommand(p.withApps(live, candidate, org, space, p.inheritServices), desc))
+	}
+	return
+}
+
+func (p inheritPlan) describeScale() string {
+	if !p.inherited.Live {
+		return "no live app, nothing to inherit"
+	}
+	var processes []string
+	for _, process := range p.inherited.Processes {
+		processes = append(processes, fmt.Sprintf("'%s' with %d instances, %dMB memory and %dMB disk", process.Type, process.Instances, process.MemoryInMB, process.DiskInMB))
+	}
+	return strings.Join(processes, ", ")
+}
+
+// describeEnv shows which of the keys are set on the live app, never their values as they are often secrets.
+func (p inheritPlan) describeEnv(keys []string) string {
+	if !p.inherited.Live {
+		return "no live app, nothing to inherit"
+	}
+	var described []string
+	for _, key := range keys {
+		if value, found := p.inherited.Env[key]; !found || value == nil {
+			described = append(described, fmt.Sprintf("%s (not set)", key))
+			continue
+		}
+		described = append(described, key)
+	}
+	return fmt.Sprintf("[%s]", strings.Join(described, ", "))
+}
+
+type inheritFunc func(ctx context.Context, cfClient *cfclient.Client, logger *logger.CapturingWriter, live, candidate *resource.App) error
+
+// withApps looks up the live app and the candidate, there is nothing to inherit on the very first deploy.
+// The scale and the env are the ones looked up before planning, so that the candidate gets what the plan shows.
+func (p inheritPlan) withApps(liveName, candidateName, org, space string, inherit inheritFunc) func(*cfclient.Client, *logger.CapturingWriter) error {
+	return func(cfClient *cfclient.Client, logger *logger.CapturingWriter) error {
+		ctx := context.Background()
+		apps, err := getAppsInOrgSpace(ctx, cfClient, org, space)
+		if err != nil {
+			return err
+		}
+
+		live := findApp(liveName, apps)
+		if live == nil || !p.inherited.Live {
+			logger.Println(fmt.Sprintf("No live app '%s', nothing to inherit", liveName))
+			return nil
+		}
+
+		candidate := findApp(candidateName, apps)
+		if candidate == nil {
+			return fmt.Errorf("candidate app '%s' not found", candidateName)
+		}
+
+		if err := inherit(ctx, cfClient, logger, live, candidate); err != nil {
+			return err
+		}
+		logger.Println("OK")
+		return nil
+	}
+}
+
+func (p inheritPlan) inheritScale(ctx context.Context, cfClient *cfclient.Client, logger *logger.CapturingWriter, live, candidate *resource.App) error {
+	candidateProcesses, err := cfClient.Processes.ListForAppAll(ctx, candidate.GUID, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, candidateProcess := range candidateProcesses {
+		var liveProcess *resource.Process
+		for _, process := range p.inherited.Processes {
+			if process.Type == candidateProcess.Type {
+				liveProcess = process
+			}
+		}
+		if liveProcess == nil {
+			logger.Println(fmt.Sprintf("Process '%s' does not exist in '%s', keeping the scale from the manifest", candidateProcess.Type, live.Name))
+			continue
+		}
+
+		logger.Println(fmt.Sprintf("Scaling process '%s' to %d instances with %dMB memory and %dMB disk, was %d instances with %dMB memory and %dMB disk",
+			candidateProcess.Type,
+			liveProcess.Instances, liveProcess.MemoryInMB, liveProcess.DiskInMB,
+			candidateProcess.Instances, candidateProcess.MemoryInMB, candidateProcess.DiskInMB))
+
+		scale := resource.NewProcessScale().
+			WithInstances(liveProcess.Instances).
+			WithMemoryInMB(liveProcess.MemoryInMB).
+			WithDiskInMB(liveProcess.DiskInMB)
+		if _, err := cfClient.Processes.Scale(ctx, candidateProcess.GUID, scale); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inheritEnv copies the given keys from the user-provided env of the live app. Only the keys are logged as the values are often secrets.
+func (p inheritPlan) inheritEnv(keys []string) inheritFunc {
+	return func(ctx context.Context, cfClient *cfclient.Client, logger *logger.CapturingWriter, live, candidate *resource.App) error {
+		inherited := make(map[string]*string)
+		for _, key := range keys {
+			value, found := p.inherited.Env[key]
+			if !found || value == nil {
+				logger.Println(fmt.Sprintf("'%s' is not set on '%s', keeping the value from the manifest", key, live.Name))
+				continue
+			}
+			logger.Println(fmt.Sprintf("Setting '%s' to the value from '%s'", key, live.Name))
+			inherited[key] = value
+		}
+
+		if len(inherited) == 0 {
+			return nil
+		}
+		_, err := cfClient.Applications.SetEnvironmentVariables(ctx, candidate.GUID, inherited)
+		return err
+	}
+}
+
+func (p inheritPlan) inheritServices(ctx context.Context, cfClient *cfclient.Client, logger *logger.CapturingWriter, live, candidate *resource.App) error {
+	liveBindings, instances, err := p.appBindings(ctx, cfClient, live.GUID)
+	if err != nil {
+		return err
+	}
+	candidateBindings, _, err := p.appBindings(ctx, cfClient, candidate.GUID)
+	if err != nil {
+		return err
+	}
+
+	bound := make(map[string]bool)
+	for _, binding := range candidateBindings {
+		bound[binding.Relationships.ServiceInstance.Data.GUID] = true
+	}
+
+	for _, binding := range liveBindings {
+		instanceGUID := binding.Relationships.ServiceInstance.Data.GUID
+		name := instanceGUID
+		for _, instance := range instances {
+			if instance.GUID == instanceGUID {
+				name = instance.Name
+			}
+		}
+
+		if bound[instanceGUID] {
+			logger.Println(fmt.Sprintf("'%s' is already bound to '%s'", name, candidate.Name))
+			continue
+		}
+
+		logger.Println(fmt.Sprintf("Binding '%s' to '%s'", name, candidate.Name))
+		jobGUID, _, err := cfClient.ServiceCredentialBindings.Create(ctx, resource.NewServiceCredentialBindingCreateApp(instanceGUID, candidate.GUID))
+		if err != nil {
+			return err
+		}
+		if jobGUID != "" {
+			if err := cfClient.Jobs.PollComplete(ctx, jobGUID, p.pollingOptions); err != nil {
+				return fmt.Errorf("failed to bind '%s' to '%s': %w", name, candidate.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (p inheritPlan) appBindings(ctx context.Context, cfClient *cfclient.Client, appGUID string) ([]*resource.ServiceCredentialBinding, []*resource.ServiceInstance, error) {
+	opts := cfclient.NewServiceCredentialBindingListOptions()
+	opts.AppGUIDs = cfclient.Filter{Values: []string{appGUID}}
+	opts.Type = cfclient.Filter{Values: []string{"app"}}
+	return cfClient.ServiceCredentialBindings.ListIncludeServiceInstancesAll(ctx, opts)
+}
+
+// LookupInherited is looked up before planning, so that the plan shows the scale and the env the candidate inherits.
+func LookupInherited(cfClient *cfclient.Client, request config.Request, apps []*resource.App) (inherited Inherited, err error) {
+	inherit := request.Params.Inherit
+	if cfClient == nil || (request.Params.Command != config.PUSH && request.Params.Command != config.ALL) || (!inherit.Scale && len(inherit.Env) == 0) {
+		return
+	}
+	live := findApp(request.Metadata.AppName, apps)
+	if live == nil {
+		return
+	}
+	inherited.Live = true
+
+	ctx := context.Background()
+	if inherit.Scale {
+		if inherited.Processes, err = cfClient.Processes.ListForAppAll(ctx, live.GUID, nil); err != nil {
+			return
+		}
+	}
+	if len(inherit.Env) > 0 {
+		inherited.Env, err = cfClient.Applications.GetEnvironmentVariables(ctx, live.GUID)
+	}
+	return
+}
+
+// NewInheritPlan plans what the candidate inherits from the live app, inherited is looked up with LookupInherited.
+func NewInheritPlan(inherited Inherited) InheritPlan {
+	return inheritPlan{
+		inherited: inherited,
+		pollingOptions: &cfclient.PollingOptions{
+			FailedState:   string(resource.JobStateFailed),
+			Timeout:       10 * time.Minute,
+			CheckInterval: 2 * time.Second,
+		},
+	}
+}
@@ -0,0 +1,53 @@
+package plan
+
+import (
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	halfpipe_deploy_resource "github.com/springernature/halfpipe-deploy-resource"
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestInheritPlan(t *testing.T) {
+	man := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp`).Applications[0]
+
+	t.Run("nothing to inherit by default", func(t *testing.T) {
+		assert.Empty(t, NewInheritPlan(Inherited{}).Plan(man, validRequest))
+	})
+
+	r := validRequest
+	r.Params.Inherit = config.Inherit{
+		Scale:    true,
+		Env:      []string{"FEATURE_FLAG", "API_TOKEN", "LOG_LEVEL"},
+		Services: true,
+	}
+
+	t.Run("everything", func(t *testing.T) {
+		on, token := "on", "s3cr3t"
+		inherited := Inherited{
+			Live: true,
+			Processes: []*resource.Process{
+				{Type: "web", Instances: 3, MemoryInMB: 1024, DiskInMB: 2048},
+				{Type: "worker", Instances: 1, MemoryInMB: 512, DiskInMB: 1024},
+			},
+			Env: map[string]*string{"FEATURE_FLAG": &on, "API_TOKEN": &token},
+		}
+
+		p := NewInheritPlan(inherited).Plan(man, r)
+
+		assert.Len(t, p, 3)
+		assert.Equal(t, "Inheriting instances, memory and disk of 'myApp' for 'myApp-CANDIDATE': 'web' with 3 instances, 1024MB memory and 2048MB disk, 'worker' with 1 instances, 512MB memory and 1024MB disk", p[0].String())
+		assert.Equal(t, "Inheriting env of 'myApp' for 'myApp-CANDIDATE': [FEATURE_FLAG, API_TOKEN, LOG_LEVEL (not set)]", p[1].String())
+		assert.NotContains(t, p.String(), "s3cr3t")
+		assert.Equal(t, "Inheriting service bindings of 'myApp' for 'myApp-CANDIDATE'", p[2].String())
+	})
+
+	t.Run("without a live app", func(t *testing.T) {
+		p := NewInheritPlan(Inherited{}).Plan(man, r)
+
+		assert.Len(t, p, 3)
+		assert.Equal(t, "Inheriting instances, memory and disk of 'myApp' for 'myApp-CANDIDATE': no live app, nothing to inherit", p[0].String())
+		assert.Equal(t, "Inheriting env of 'myApp' for 'myApp-CANDIDATE': no live app, nothing to inherit", p[1].String())
+	})
+}
@@ -0,0 +1,43 @@
+package plan
+
+import (
+	"code.cloudfoundry.org/cli/util/manifestparser"
+	"fmt"
+	cfclient "github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/springernature/halfpipe-deploy-resource/logger"
+)
+
+type LintPlan interface {
+	Plan(manifest manifestparser.Application, request config.Request) (pl Plan)
+}
+
+// lintPlan lints the manifest like appLintPlan, but without CF. The labels of the space are not known,
+// so 'required-labels' is not checked and 'allowed-label-values' only looks at the labels in the manifest.
+type lintPlan struct{}
+
+func (p lintPlan) Plan(manifest manifestparser.Application, request config.Request) (pl Plan) {
+	desc := fmt.Sprintf("Linting '%s' without CF", manifest.Name)
+	pl = append(pl, NewClientCommand(p.createFunc(manifest, request), desc))
+	return
+}
+
+func (p lintPlan) policy(request config.Request) config.LintPolicy {
+	return request.Params.LintPolicy.Merge(config.LintPolicy{Rules: map[string]config.LintLevel{config.LintRuleRequiredLabels: config.LintOff}})
+}
+
+func (p lintPlan) createFunc(manifest manifestparser.Application, request config.Request) func(*cfclient.Client, *logger.CapturingWriter) error {
+	return func(_ *cfclient.Client, logger *logger.CapturingWriter) error {
+		policy := p.policy(request)
+		if policy.FormatOrDefault() == config.LintFormatText && request.Params.LintPolicy.Level(config.LintRuleRequiredLabels, config.LintWarn) != config.LintOff {
+			logger.Println("The labels of the space are not known without CF, 'required-labels' is checked when deploying")
+		}
+
+		labels := lintLabels{manifest: appLintPlan{}.getLabelsForApp(manifest)}
+		return reportLint(logger, policy, appLintPlan{}.lint(manifest, policy, labels))
+	}
+}
+
+func NewLintPlan() LintPlan {
+	return lintPlan{}
+}
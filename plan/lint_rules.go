@@ -0,0 +1,237 @@
+package plan
+
+import (
+	"code.cloudfoundry.org/cli/util/manifestparser"
+	"fmt"
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"slices"
+	"strings"
+)
+
+const manifestDocsURL = "https://docs.cloudfoundry.org/devguide/deploy-apps/manifest-attributes.html"
+
+// LintRule is a check of the manifest that needs nothing but the manifest, so it can run without CF credentials.
+// Severity is the level of the rule when the lint policy does not set one.
+type LintRule interface {
+	ID() string
+	Severity() config.LintLevel
+	DocsURL() string
+	Check(manifest manifestparser.Application) (messages []string)
+}
+
+// LintResult is a problem found by a rule, at the level the lint policy sets for it.
+type LintResult struct {
+	Rule    string           `json:"rule"`
+	Level   config.LintLevel `json:"level"`
+	Message string           `json:"message"`
+	DocsURL string           `json:"docsUrl"`
+}
+
+type lintRule struct {
+	id       string
+	severity config.LintLevel
+	docsURL  string
+}
+
+func (r lintRule) ID() string                 { return r.id }
+func (r lintRule) Severity() config.LintLevel { return r.severity }
+func (r lintRule) DocsURL() string            { return r.docsURL }
+
+// ManifestLintRules are the built-in rules, configured with the policy.
+func ManifestLintRules(policy config.LintPolicy) []LintRule {
+	return []LintRule{
+		deprecatedStackRule{lintRule{config.LintRuleDeprecatedStack, config.LintWarn, "https://ee.public.springernature.app/paas/cf/stacks/"}, policy.DeprecatedStacksOrDefault()},
+		maxMemoryRule{lintRule{config.LintRuleMaxMemory, config.LintWarn, manifestDocsURL + "#memory"}, policy.MaxMemoryInMB()},
+		healthCheckTypeRule{lintRule{config.LintRuleHealthCheckType, config.LintOff, manifestDocsURL + "#health-check-type"}},
+		noRouteRule{lintRule{config.LintRuleNoRoute, config.LintWarn, manifestDocsURL + "#no-route"}},
+		healthCheckProcessOnWebRule{lintRule{config.LintRuleHealthCheckProcessOnWeb, config.LintWarn, "https://docs.cloudfoundry.org/devguide/deploy-apps/healthchecks.html"}},
+		missingTimeoutRule{lintRule{config.LintRuleMissingTimeout, config.LintWarn, manifestDocsURL + "#timeout"}},
+		unpinnedBuildpackRule{lintRule{config.LintRuleUnpinnedBuildpack, config.LintWarn, manifestDocsURL + "#buildpack"}},
+		randomRouteRule{lintRule{config.LintRuleRandomRoute, config.LintWarn, manifestDocsURL + "#random-route"}},
+		underscoreInHostnameRule{lintRule{config.LintRuleUnderscoreInHostname, config.LintWarn, manifestDocsURL + "#routes"}},
+	}
+}
+
+// LintManifest runs the rules that are not turned off in the policy.
+func LintManifest(manifest manifestparser.Application, policy config.LintPolicy, rules []LintRule) (results []LintResult) {
+	results = []LintResult{}
+	for _, rule := range rules {
+		level := policy.Level(rule.ID(), rule.Severity())
+		if level == config.LintOff {
+			continue
+		}
+		for _, message := range rule.Check(manifest) {
+			results = append(results, LintResult{Rule: rule.ID(), Level: level, Message: message, DocsURL: rule.DocsURL()})
+		}
+	}
+	return
+}
+
+// webProcessValue is the value of a field of the web process, which can be set on the app or in the processes.
+func webProcessValue[T comparable](manifest manifestparser.Application, app T, process func(manifestparser.Process) T) T {
+	var zero T
+	for _, p := range manifest.Processes {
+		if p.Type == "web" && process(p) != zero {
+			return process(p)
+		}
+	}
+	return app
+}
+
+func hasRoutes(manifest manifestparser.Application) bool {
+	return !manifest.NoRoute && len(manifestRoutes(manifest)) > 0
+}
+
+type deprecatedStackRule struct {
+	lintRule
+	stacks []string
+}
+
+func (r deprecatedStackRule) Check(manifest manifestparser.Application) (messages []string) {
+	if slices.Contains(r.stacks, manifest.Stack) {
+		messages = append(messages, fmt.Sprintf("CF stack '%s' is deprecated", manifest.Stack))
+	}
+	return
+}
+
+type maxMemoryRule struct {
+	lintRule
+	maxMemory int
+}
+
+func (r maxMemoryRule) Check(manifest manifestparser.Application) (messages []string) {
+	if r.maxMemory == 0 {
+		return
+	}
+
+	check := func(what string, memory string) {
+		if mb, err := config.ParseMegabytes(memory); err == nil && mb > r.maxMemory {
+			messages = append(messages, fmt.Sprintf("memory of %s is %dMB, must be at most %dMB", what, mb, r.maxMemory))
+		}
+	}
+	check(fmt.Sprintf("'%s'", manifest.Name), manifest.Memory)
+	for _, process := range manifest.Processes {
+		check(fmt.Sprintf("process '%s'", process.Type), process.Memory)
+	}
+	return
+}
+
+type healthCheckTypeRule struct {
+	lintRule
+}
+
+func (r healthCheckTypeRule) Check(manifest manifestparser.Application) (messages []string) {
+	healthCheckType := webProcessValue(manifest, string(manifest.HealthCheckType), func(p manifestparser.Process) string { return string(p.HealthCheckType) })
+	if healthCheckType == "" {
+		messages = append(messages, "'health-check-type' is not set, use 'http' together with 'health-check-http-endpoint' for apps with routes and 'process' for workers")
+	}
+	return
+}
+
+type noRouteRule struct {
+	lintRule
+}
+
+func (r noRouteRule) Check(manifest manifestparser.Application) (messages []string) {
+	_, routes := manifest.RemainingManifestFields["routes"]
+	switch {
+	case manifest.NoRoute && routes:
+		messages = append(messages, "'no-route' is set together with 'routes', remove one of them")
+	case manifest.NoRoute && manifest.RandomRoute:
+		messages = append(messages, "'no-route' is set together with 'random-route', remove one of them")
+	case !manifest.NoRoute && !routes:
+		messages = append(messages, "there are no 'routes', set 'no-route: true' if the app must not be reachable")
+	}
+	return
+}
+
+// healthCheckProcessOnWebRule flags web apps that are only checked for a running process, so instances that
+// do not serve requests yet, or anymore, still get traffic, and rolling deploys do not wait for the app to be ready.
+type healthCheckProcessOnWebRule struct {
+	lintRule
+}
+
+func (r healthCheckProcessOnWebRule) Check(manifest manifestparser.Application) (messages []string) {
+	healthCheckType := webProcessValue(manifest, string(manifest.HealthCheckType), func(p manifestparser.Process) string { return string(p.HealthCheckType) })
+	if hasRoutes(manifest) && healthCheckType == "process" {
+		messages = append(messages, "'health-check-type: process' is set on an app with routes, use 'http' together with 'health-check-http-endpoint'")
+	}
+	return
+}
+
+type missingTimeoutRule struct {
+	lintRule
+}
+
+func (r missingTimeoutRule) Check(manifest manifestparser.Application) (messages []string) {
+	timeout := webProcessValue(manifest, manifest.HealthCheckTimeout, func(p manifestparser.Process) int64 { return p.HealthCheckTimeout })
+	if timeout == 0 {
+		messages = append(messages, "'timeout' is not set, CF gives the app 60 seconds to start before it is restarted")
+	}
+	return
+}
+
+// unpinnedBuildpackRule flags buildpacks that can change between two pushes of the same code. Buildpacks installed
+// on the platform are updated by the platform, a git url without a '#<tag>' follows the default branch.
+type unpinnedBuildpackRule struct {
+	lintRule
+}
+
+func (r unpinnedBuildpackRule) buildpacks(manifest manifestparser.Application) (buildpacks []string) {
+	if buildpack, ok := manifest.RemainingManifestFields["buildpack"].(string); ok {
+		buildpacks = append(buildpacks, buildpack)
+	}
+	if list, ok := manifest.RemainingManifestFields["buildpacks"].([]any); ok {
+		for _, buildpack := range list {
+			buildpacks = append(buildpacks, fmt.Sprint(buildpack))
+		}
+	}
+	return
+}
+
+func (r unpinnedBuildpackRule) Check(manifest manifestparser.Application) (messages []string) {
+	if manifest.Docker != nil {
+		return
+	}
+	for _, buildpack := range r.buildpacks(manifest) {
+		switch {
+		case !strings.Contains(buildpack, "://"):
+			messages = append(messages, fmt.Sprintf("buildpack '%s' is installed on the platform and changes when the platform updates it, use the url of a released version", buildpack))
+		case !strings.Contains(buildpack, "#") && !strings.HasSuffix(buildpack, ".zip"):
+			messages = append(messages, fmt.Sprintf("buildpack '%s' is not pinned to a version, add '#<tag>' to the url", buildpack))
+		}
+	}
+	return
+}
+
+// randomRouteRule flags 'random-route', the candidate gets its own test route and the promote maps the routes
+// in the manifest, so a random route is left behind on every deploy.
+type randomRouteRule struct {
+	lintRule
+}
+
+func (r randomRouteRule) Check(manifest manifestparser.Application) (messages []string) {
+	if manifest.RandomRoute {
+		messages = append(messages, "'random-route: true' conflicts with the CANDIDATE test route and is left behind on every deploy, use 'routes'")
+	}
+	return
+}
+
+// underscoreInHostnameRule flags '_' in hostnames, which is not valid in DNS. The candidate hostname is derived from
+// the app name with '_' replaced by '-', so it does not match the name either.
+type underscoreInHostnameRule struct {
+	lintRule
+}
+
+func (r underscoreInHostnameRule) Check(manifest manifestparser.Application) (messages []string) {
+	if strings.Contains(manifest.Name, "_") && !manifest.NoRoute {
+		messages = append(messages, fmt.Sprintf("app name '%s' contains '_', the hostname of the candidate route uses '%s' instead", manifest.Name, strings.ReplaceAll(manifest.Name, "_", "-")))
+	}
+	for _, route := range manifestRoutes(manifest) {
+		hostname := strings.SplitN(route, ".", 2)[0]
+		if strings.Contains(hostname, "_") {
+			messages = append(messages, fmt.Sprintf("hostname '%s' of route '%s' contains '_', which is not valid in DNS", hostname, route))
+		}
+	}
+	return
+}
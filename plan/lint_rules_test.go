@@ -0,0 +1,184 @@
+package plan
+
+import (
+	halfpipe_deploy_resource "github.com/springernature/halfpipe-deploy-resource"
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func checkRule(rule string, manifest string) []string {
+	for _, r := range ManifestLintRules(config.LintPolicy{MaxMemory: "1G"}) {
+		if r.ID() == rule {
+			return r.Check(halfpipe_deploy_resource.ParseManifest(manifest).Applications[0])
+		}
+	}
+	panic("no rule " + rule)
+}
+
+func TestManifestLintRulesAreKnownToThePolicy(t *testing.T) {
+	for _, rule := range ManifestLintRules(config.LintPolicy{}) {
+		assert.Contains(t, config.LintRules(), rule.ID())
+		assert.NotEmpty(t, rule.DocsURL())
+	}
+}
+
+func TestLintManifest(t *testing.T) {
+	man := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: my_app
+  random-route: true
+  buildpacks:
+  - https://github.com/cloudfoundry/java-buildpack.git#v4.50
+  health-check-type: http
+  timeout: 120`).Applications[0]
+
+	t.Run("default severities", func(t *testing.T) {
+		results := LintManifest(man, config.LintPolicy{}, ManifestLintRules(config.LintPolicy{}))
+
+		var rules []string
+		for _, result := range results {
+			assert.Equal(t, config.LintWarn, result.Level)
+			rules = append(rules, result.Rule)
+		}
+		assert.Equal(t, []string{config.LintRuleNoRoute, config.LintRuleRandomRoute, config.LintRuleUnderscoreInHostname}, rules)
+	})
+
+	t.Run("levels from the policy", func(t *testing.T) {
+		policy := config.LintPolicy{Rules: map[string]config.LintLevel{
+			config.LintRuleNoRoute:              config.LintOff,
+			config.LintRuleRandomRoute:          config.LintError,
+			config.LintRuleUnderscoreInHostname: config.LintOff,
+		}}
+
+		assert.Equal(t, []LintResult{{
+			Rule:    config.LintRuleRandomRoute,
+			Level:   config.LintError,
+			Message: "'random-route: true' conflicts with the CANDIDATE test route and is left behind on every deploy, use 'routes'",
+			DocsURL: "https://docs.cloudfoundry.org/devguide/deploy-apps/manifest-attributes.html#random-route",
+		}}, LintManifest(man, policy, ManifestLintRules(policy)))
+	})
+}
+
+func TestLintRuleMaxMemory(t *testing.T) {
+	assert.Equal(t, []string{"memory of process 'worker' is 2048MB, must be at most 1024MB"}, checkRule(config.LintRuleMaxMemory, `applications:
+- name: myApp
+  memory: 1G
+  processes:
+  - type: worker
+    memory: 2G`))
+}
+
+func TestLintRuleNoRoute(t *testing.T) {
+	assert.Empty(t, checkRule(config.LintRuleNoRoute, `applications:
+- name: myApp
+  routes:
+  - route: myApp.domain.com`))
+
+	assert.Empty(t, checkRule(config.LintRuleNoRoute, `applications:
+- name: myWorker
+  no-route: true`))
+
+	assert.Equal(t, []string{"'no-route' is set together with 'routes', remove one of them"}, checkRule(config.LintRuleNoRoute, `applications:
+- name: myApp
+  no-route: true
+  routes:
+  - route: myApp.domain.com`))
+
+	assert.Equal(t, []string{"'no-route' is set together with 'random-route', remove one of them"}, checkRule(config.LintRuleNoRoute, `applications:
+- name: myApp
+  no-route: true
+  random-route: true`))
+
+	assert.Equal(t, []string{"there are no 'routes', set 'no-route: true' if the app must not be reachable"}, checkRule(config.LintRuleNoRoute, `applications:
+- name: myApp`))
+}
+
+func TestLintRuleHealthCheckProcessOnWeb(t *testing.T) {
+	expected := []string{"'health-check-type: process' is set on an app with routes, use 'http' together with 'health-check-http-endpoint'"}
+
+	assert.Equal(t, expected, checkRule(config.LintRuleHealthCheckProcessOnWeb, `applications:
+- name: myApp
+  health-check-type: process
+  routes:
+  - route: myApp.domain.com`))
+
+	assert.Equal(t, expected, checkRule(config.LintRuleHealthCheckProcessOnWeb, `applications:
+- name: myApp
+  routes:
+  - route: myApp.domain.com
+  processes:
+  - type: web
+    health-check-type: process`))
+
+	assert.Empty(t, checkRule(config.LintRuleHealthCheckProcessOnWeb, `applications:
+- name: myWorker
+  health-check-type: process
+  no-route: true`))
+
+	assert.Empty(t, checkRule(config.LintRuleHealthCheckProcessOnWeb, `applications:
+- name: myApp
+  health-check-type: http
+  routes:
+  - route: myApp.domain.com`))
+}
+
+func TestLintRuleMissingTimeout(t *testing.T) {
+	assert.Equal(t, []string{"'timeout' is not set, CF gives the app 60 seconds to start before it is restarted"}, checkRule(config.LintRuleMissingTimeout, `applications:
+- name: myApp`))
+
+	assert.Empty(t, checkRule(config.LintRuleMissingTimeout, `applications:
+- name: myApp
+  timeout: 180`))
+
+	assert.Empty(t, checkRule(config.LintRuleMissingTimeout, `applications:
+- name: myApp
+  processes:
+  - type: web
+    timeout: 180`))
+}
+
+func TestLintRuleUnpinnedBuildpack(t *testing.T) {
+	assert.Equal(t, []string{
+		"buildpack 'java_buildpack' is installed on the platform and changes when the platform updates it, use the url of a released version",
+		"buildpack 'https://github.com/cloudfoundry/nodejs-buildpack' is not pinned to a version, add '#<tag>' to the url",
+	}, checkRule(config.LintRuleUnpinnedBuildpack, `applications:
+- name: myApp
+  buildpacks:
+  - java_buildpack
+  - https://github.com/cloudfoundry/nodejs-buildpack
+  - https://github.com/cloudfoundry/go-buildpack#v1.10.0
+  - https://github.com/cloudfoundry/go-buildpack/releases/download/v1.10.0/go-buildpack-cflinuxfs4-v1.10.0.zip`))
+
+	assert.Equal(t, []string{"buildpack 'https://github.com/cloudfoundry/nodejs-buildpack' is not pinned to a version, add '#<tag>' to the url"}, checkRule(config.LintRuleUnpinnedBuildpack, `applications:
+- name: myApp
+  buildpack: https://github.com/cloudfoundry/nodejs-buildpack`))
+
+	assert.Empty(t, checkRule(config.LintRuleUnpinnedBuildpack, `applications:
+- name: myApp
+  docker:
+    image: nginx`))
+}
+
+func TestLintRuleRandomRoute(t *testing.T) {
+	assert.Len(t, checkRule(config.LintRuleRandomRoute, `applications:
+- name: myApp
+  random-route: true`), 1)
+
+	assert.Empty(t, checkRule(config.LintRuleRandomRoute, `applications:
+- name: myApp`))
+}
+
+func TestLintRuleUnderscoreInHostname(t *testing.T) {
+	assert.Equal(t, []string{
+		"app name 'my_app' contains '_', the hostname of the candidate route uses 'my-app' instead",
+		"hostname 'my_app' of route 'my_app.domain.com/path' contains '_', which is not valid in DNS",
+	}, checkRule(config.LintRuleUnderscoreInHostname, `applications:
+- name: my_app
+  routes:
+  - route: my-app.domain.com
+  - route: my_app.domain.com/path`))
+
+	assert.Empty(t, checkRule(config.LintRuleUnderscoreInHostname, `applications:
+- name: my_worker
+  no-route: true`))
+}
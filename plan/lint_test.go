@@ -0,0 +1,43 @@
+package plan
+
+import (
+	halfpipe_deploy_resource "github.com/springernature/halfpipe-deploy-resource"
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLintPlan(t *testing.T) {
+	man := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp
+  random-route: true
+  health-check-type: http
+  timeout: 120
+  metadata:
+    labels:
+      environment: prod`).Applications[0]
+
+	t.Run("does not need a cf client", func(t *testing.T) {
+		request := validRequest
+		request.Params.LintPolicy = config.LintPolicy{
+			Rules:              map[string]config.LintLevel{config.LintRuleRandomRoute: config.LintError},
+			AllowedLabelValues: map[string][]string{"environment": {"dev", "live"}},
+		}
+
+		p := NewLintPlan().Plan(man, request)
+		assert.Len(t, p, 1)
+		assert.Equal(t, "Linting 'myApp' without CF", p[0].String())
+
+		err := p[0].(clientCommand).CallWithCfClient(nil, &discardLogger)
+		assert.EqualError(t, err, "linting failed with 1 error(s), see the lint policy for the rules that fail the deploy")
+	})
+
+	t.Run("does not check required labels as the labels of the space are not known", func(t *testing.T) {
+		request := validRequest
+		request.Params.LintPolicy = config.LintPolicy{Rules: map[string]config.LintLevel{config.LintRuleRequiredLabels: config.LintError}}
+
+		policy := lintPlan{}.policy(request)
+		assert.Equal(t, config.LintOff, policy.Level(config.LintRuleRequiredLabels, config.LintWarn))
+		assert.NoError(t, lintPlan{}.createFunc(man, request)(nil, &discardLogger))
+	})
+}
@@ -0,0 +1,278 @@
+package plan
+
+import (
+	"bytes"
+	"code.cloudfoundry.org/cli/util/manifestparser"
+	"context"
+	"encoding/json"
+	"fmt"
+	cfclient "github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/springernature/halfpipe-deploy-resource/logger"
+	"net/http"
+	"strings"
+)
+
+// networkPolicy is a policy as the policy server API, /networking/v1/external/policies, returns and accepts it.
+// go-cfclient doesn't have a client for it.
+type networkPolicy struct {
+	Source      networkPolicySource      `json:"source"`
+	Destination networkPolicyDestination `json:"destination"`
+}
+
+type networkPolicySource struct {
+	ID string `json:"id"`
+}
+
+type networkPolicyDestination struct {
+	ID       string             `json:"id"`
+	Protocol string             `json:"protocol"`
+	Ports    networkPolicyPorts `json:"ports"`
+}
+
+type networkPolicyPorts struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+type networkPolicies struct {
+	Policies []networkPolicy `json:"policies"`
+}
+
+type NetworkPoliciesPlan interface {
+	Plan(manifest manifestparser.Application, request config.Request) (pl Plan)
+}
+
+type networkPoliciesPlan struct{}
+
+func (p networkPoliciesPlan) Plan(manifest manifestparser.Application, request config.Request) (pl Plan) {
+	candidate := createCandidateAppName(manifest.Name)
+
+	desc := fmt.Sprintf("Copying network policies of '%s' to '%s'", manifest.Name, candidate)
+	pl = append(pl, NewClientCommand(p.copyFunc(manifest, request), desc))
+
+	for _, policy := range request.Params.NetworkPolicies {
+		source, destination := candidate, policy.Destination
+		if policy.Source != "" {
+			source, destination = policy.Source, candidate
+		}
+		desc := fmt.Sprintf("Ensuring network policy from '%s' to '%s' with protocol '%s' and ports '%s'", source, destination, policy.ProtocolOrDefault(), p.ports(policy))
+		pl = append(pl, NewClientCommand(p.ensureFunc(manifest, policy, request), desc))
+	}
+	return
+}
+
+func (p networkPoliciesPlan) ports(policy config.NetworkPolicy) string {
+	if policy.Ports == "" {
+		return config.DefaultNetworkPolicyPorts
+	}
+	return policy.Ports
+}
+
+func (p networkPoliciesPlan) copyFunc(manifest manifestparser.Application, request config.Request) func(*cfclient.Client, *logger.CapturingWriter) error {
+	return func(cfClient *cfclient.Client, logger *logger.CapturingWriter) error {
+		ctx := context.Background()
+		apps, err := getAppsInOrgSpace(ctx, cfClient, request.Source.Org, request.Source.Space)
+		if err != nil {
+			return err
+		}
+
+		live := findApp(manifest.Name, apps)
+		if live == nil {
+			logger.Println(fmt.Sprintf("No live app '%s', nothing to copy", manifest.Name))
+			return nil
+		}
+		candidate := findApp(createCandidateAppName(manifest.Name), apps)
+		if candidate == nil {
+			return fmt.Errorf("candidate app '%s' not found", createCandidateAppName(manifest.Name))
+		}
+
+		policiesURL, found, err := p.policiesURL(ctx, cfClient)
+		if err != nil {
+			return err
+		}
+		if !found {
+			logger.Println("The CF API does not have the network policy API, nothing to copy")
+			return nil
+		}
+
+		existing, err := p.listPolicies(ctx, cfClient, policiesURL, live.GUID)
+		if err != nil {
+			return err
+		}
+
+		copied := p.copyPolicies(existing, live.GUID, candidate.GUID)
+		if len(copied) == 0 {
+			logger.Println(fmt.Sprintf("'%s' has no network policies", manifest.Name))
+			return nil
+		}
+
+		for _, policy := range copied {
+			logger.Println(fmt.Sprintf("Adding network policy from '%s' to '%s' with protocol '%s' and ports '%d-%d'",
+				p.appName(policy.Source.ID, apps), p.appName(policy.Destination.ID, apps), policy.Destination.Protocol, policy.Destination.Ports.Start, policy.Destination.Ports.End))
+		}
+		if err := p.createPolicies(ctx, cfClient, policiesURL, copied); err != nil {
+			return err
+		}
+
+		logger.Println("OK")
+		return nil
+	}
+}
+
+func (p networkPoliciesPlan) ensureFunc(manifest manifestparser.Application, policy config.NetworkPolicy, request config.Request) func(*cfclient.Client, *logger.CapturingWriter) error {
+	return func(cfClient *cfclient.Client, logger *logger.CapturingWriter) error {
+		ctx := context.Background()
+		apps, err := getAppsInOrgSpace(ctx, cfClient, request.Source.Org, request.Source.Space)
+		if err != nil {
+			return err
+		}
+
+		candidate := findApp(createCandidateAppName(manifest.Name), apps)
+		if candidate == nil {
+			return fmt.Errorf("candidate app '%s' not found", createCandidateAppName(manifest.Name))
+		}
+
+		otherName := policy.Destination
+		if policy.Source != "" {
+			otherName = policy.Source
+		}
+		other := findApp(otherName, apps)
+		if other == nil {
+			return fmt.Errorf("app '%s' not found in space '%s'", otherName, request.Source.Space)
+		}
+
+		start, end, err := policy.PortRange()
+		if err != nil {
+			return err
+		}
+
+		newPolicy := networkPolicy{
+			Source:      networkPolicySource{ID: candidate.GUID},
+			Destination: networkPolicyDestination{ID: other.GUID, Protocol: policy.ProtocolOrDefault(), Ports: networkPolicyPorts{Start: start, End: end}},
+		}
+		if policy.Source != "" {
+			newPolicy.Source.ID = other.GUID
+			newPolicy.Destination.ID = candidate.GUID
+		}
+
+		policiesURL, found, err := p.policiesURL(ctx, cfClient)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("the CF API does not have a network policy endpoint")
+		}
+
+		// Creating a policy that already exists is a no-op in the policy server.
+		if err := p.createPolicies(ctx, cfClient, policiesURL, []networkPolicy{newPolicy}); err != nil {
+			return err
+		}
+
+		logger.Println("OK")
+		return nil
+	}
+}
+
+// copyPolicies gives the candidate the same ingress and egress policies as the live app.
+// A policy from the live app to itself becomes a policy from the candidate to itself.
+func (p networkPoliciesPlan) copyPolicies(policies []networkPolicy, liveGUID, candidateGUID string) (copied []networkPolicy) {
+	for _, policy := range policies {
+		if policy.Source.ID != liveGUID && policy.Destination.ID != liveGUID {
+			continue
+		}
+		if policy.Source.ID == liveGUID {
+			policy.Source.ID = candidateGUID
+		}
+		if policy.Destination.ID == liveGUID {
+			policy.Destination.ID = candidateGUID
+		}
+		copied = append(copied, policy)
+	}
+	return
+}
+
+func (p networkPoliciesPlan) appName(guid string, apps []*resource.App) string {
+	for _, app := range apps {
+		if app.GUID == guid {
+			return app.Name
+		}
+	}
+	return guid
+}
+
+// policiesURL is the url of the policies of the network policy API, found is false on foundations without it.
+func (p networkPoliciesPlan) policiesURL(ctx context.Context, cfClient *cfclient.Client) (url string, found bool, err error) {
+	root, err := cfClient.Root.Get(ctx)
+	if err != nil || root.Links.NetworkPolicyV1.Href == "" {
+		return
+	}
+	return strings.TrimSuffix(root.Links.NetworkPolicyV1.Href, "/") + "/policies", true, nil
+}
+
+func (p networkPoliciesPlan) listPolicies(ctx context.Context, cfClient *cfclient.Client, policiesURL string, appGUID string) ([]networkPolicy, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, policiesURL+"?id="+appGUID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := cfClient.ExecuteAuthRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list network policies: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var policies networkPolicies
+	if err := json.NewDecoder(resp.Body).Decode(&policies); err != nil {
+		return nil, err
+	}
+	return policies.Policies, nil
+}
+
+func (p networkPoliciesPlan) createPolicies(ctx context.Context, cfClient *cfclient.Client, policiesURL string, policies []networkPolicy) error {
+	body, err := json.Marshal(networkPolicies{Policies: policies})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, policiesURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := cfClient.ExecuteAuthRequest(req)
+	if err != nil {
+		return fmt.Errorf("failed to create network policies: %w", err)
+	}
+	return resp.Body.Close()
+}
+
+// LiveHasNetworkPolicies is looked up before planning, so that pushes of apps without network policies don't have
+// a step to copy them. The apps of a foundation without the network policy API never have any.
+func LiveHasNetworkPolicies(cfClient *cfclient.Client, request config.Request, apps []*resource.App) (bool, error) {
+	if cfClient == nil || (request.Params.Command != config.PUSH && request.Params.Command != config.ALL) {
+		return false, nil
+	}
+	live := findApp(request.Metadata.AppName, apps)
+	if live == nil {
+		return false, nil
+	}
+
+	ctx := context.Background()
+	p := networkPoliciesPlan{}
+	policiesURL, found, err := p.policiesURL(ctx, cfClient)
+	if err != nil || !found {
+		return false, err
+	}
+	policies, err := p.listPolicies(ctx, cfClient, policiesURL, live.GUID)
+	if err != nil {
+		return false, err
+	}
+	return len(p.copyPolicies(policies, live.GUID, live.GUID)) > 0, nil
+}
+
+func NewNetworkPoliciesPlan() NetworkPoliciesPlan {
+	return networkPoliciesPlan{}
+}
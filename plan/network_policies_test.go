@@ -0,0 +1,58 @@
+package plan
+
+import (
+	halfpipe_deploy_resource "github.com/springernature/halfpipe-deploy-resource"
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNetworkPoliciesPlan(t *testing.T) {
+	man := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp`).Applications[0]
+
+	t.Run("copies the policies of the live app", func(t *testing.T) {
+		p := NewNetworkPoliciesPlan().Plan(man, validRequest)
+
+		assert.Len(t, p, 1)
+		assert.Equal(t, "Copying network policies of 'myApp' to 'myApp-CANDIDATE'", p[0].String())
+	})
+
+	t.Run("ensures declared policies", func(t *testing.T) {
+		r := validRequest
+		r.Params.NetworkPolicies = []config.NetworkPolicy{
+			{Destination: "backend"},
+			{Source: "frontend", Protocol: "udp", Ports: "9000-9010"},
+		}
+
+		p := NewNetworkPoliciesPlan().Plan(man, r)
+
+		assert.Len(t, p, 3)
+		assert.Equal(t, "Copying network policies of 'myApp' to 'myApp-CANDIDATE'", p[0].String())
+		assert.Equal(t, "Ensuring network policy from 'myApp-CANDIDATE' to 'backend' with protocol 'tcp' and ports '8080'", p[1].String())
+		assert.Equal(t, "Ensuring network policy from 'frontend' to 'myApp-CANDIDATE' with protocol 'udp' and ports '9000-9010'", p[2].String())
+	})
+}
+
+func TestCopyNetworkPolicies(t *testing.T) {
+	ports := networkPolicyPorts{Start: 8080, End: 8080}
+	policy := func(source, destination string) networkPolicy {
+		return networkPolicy{
+			Source:      networkPolicySource{ID: source},
+			Destination: networkPolicyDestination{ID: destination, Protocol: "tcp", Ports: ports},
+		}
+	}
+
+	policies := []networkPolicy{
+		policy("live", "backend"),
+		policy("frontend", "live"),
+		policy("live", "live"),
+		policy("frontend", "backend"),
+	}
+
+	assert.Equal(t, []networkPolicy{
+		policy("candidate", "backend"),
+		policy("frontend", "candidate"),
+		policy("candidate", "candidate"),
+	}, networkPoliciesPlan{}.copyPolicies(policies, "live", "candidate"))
+}
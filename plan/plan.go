@@ -26,12 +26,22 @@ func (p Plan) String() (s string) {
 }
 
 func (p Plan) Execute(executor Executor, cfClient *client.Client, logger *logger.CapturingWriter, timeout time.Duration, isActions bool) (err error) {
-	for _, c := range p {
+	_, err = p.ExecuteFrom(0, executor, cfClient, logger, timeout, isActions)
+	return
+}
+
+// ExecuteFrom executes the commands of the plan from the command at index start.
+// executed is the number of commands that have succeeded, including the ones before start,
+// so when a command fails it is the index of the command that failed.
+func (p Plan) ExecuteFrom(start int, executor Executor, cfClient *client.Client, logger *logger.CapturingWriter, timeout time.Duration, isActions bool) (executed int, err error) {
+	executed = start
+	for _, c := range p[start:] {
 		prefix := ""
 		if isActions {
 			prefix = "::group::"
 		}
 		logger.Println(fmt.Sprintf("%s%s", prefix, color.New(color.FgGreen).Sprintf("$ %s", c.String())))
+		logger.StartWindow()
 
 		errChan := make(chan error, 1)
 
@@ -40,9 +50,19 @@ func (p Plan) Execute(executor Executor, cfClient *client.Client, logger *logger
 			case clientCommand:
 				errChan <- cmd.CallWithCfClient(cfClient, logger)
 
+			case dynamicCommand:
+				resolved, err := cmd.resolve(cfClient, logger)
+				if err == nil && resolved != nil {
+					if resolved.String() != cmd.String() {
+						logger.Println(fmt.Sprintf("$ %s", resolved))
+					}
+					_, err = executor.CliCommand(resolved)
+				}
+				errChan <- err
+
 			case compoundCommand:
 				_, err = executor.CliCommand(cmd.left)
-				if cmd.shouldExecute(logger.BytesWritten) {
+				if cmd.shouldExecute(logger.Window()) {
 					if cmd.shouldErrorOnRight {
 						logger.Println("")
 						logger.Println("Failed to push/start application")
@@ -58,7 +78,7 @@ func (p Plan) Execute(executor Executor, cfClient *client.Client, logger *logger
 							errChan <- err
 						} else {
 							// This is due to insufficient resources. Maybe?
-							if strings.Contains(string(logger.BytesWritten), `insufficient resources: memory`) {
+							if strings.Contains(string(logger.Window()), `insufficient resources: memory`) {
 								logger.Println(`insufficient resources means that CF is not scaled properly, please contact us in #ee`)
 								errChan <- errors.New("failed to push/start application")
 							}
@@ -84,8 +104,9 @@ func (p Plan) Execute(executor Executor, cfClient *client.Client, logger *logger
 				return
 			}
 		case <-time.After(timeout):
-			return errors.New(fmt.Sprintf("command time out after %s", timeout.String()))
+			return executed, errors.New(fmt.Sprintf("command time out after %s", timeout.String()))
 		}
+		executed++
 		logger.Println()
 		if isActions {
 			logger.Println("::endgroup::")
@@ -10,6 +10,7 @@ import (
 	"errors"
 	"github.com/stretchr/testify/assert"
 	"io/ioutil"
+	"strings"
 )
 
 var discardLogger = logger.NewLogger(ioutil.Discard)
@@ -152,6 +153,65 @@ func TestPlan_Execute(t *testing.T) {
 	assert.Equal(t, 4, numberOfCalls)
 }
 
+func TestPlan_ExecuteFrom(t *testing.T) {
+	expectedError := errors.New("expected error")
+	var called []string
+
+	p := Plan{
+		NewCfCommand("first"),
+		NewCfCommand("second"),
+		NewCfCommand("error"),
+		NewCfCommand("fourth"),
+	}
+	executor := newMockExecutorWithFunction(func(command Command) ([]string, error) {
+		called = append(called, command.Args()[0])
+		if command.Args()[0] == "error" {
+			return []string{}, expectedError
+		}
+		return []string{}, nil
+	})
+
+	executed, err := p.ExecuteFrom(1, executor, &cfclient.Client{}, &discardLogger, 1*time.Minute, false)
+	assert.Equal(t, expectedError, err)
+	assert.Equal(t, 2, executed)
+	assert.Equal(t, []string{"second", "error"}, called)
+
+	called = nil
+	executed, err = p[:2].ExecuteFrom(0, executor, &cfclient.Client{}, &discardLogger, 1*time.Minute, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, executed)
+	assert.Equal(t, []string{"first", "second"}, called)
+}
+
+func TestPlan_Dynamic(t *testing.T) {
+	expectedError := errors.New("expected error")
+	var called []string
+	executor := newMockExecutorWithFunction(func(command Command) ([]string, error) {
+		called = append(called, command.String())
+		return []string{}, nil
+	})
+
+	p := Plan{
+		NewDynamicCommand(func(client *cfclient.Client, logger *logger.CapturingWriter) (Command, error) {
+			return NewCfCommand("resolved"), nil
+		}, "resolved when executed"),
+		NewDynamicCommand(func(client *cfclient.Client, logger *logger.CapturingWriter) (Command, error) {
+			return nil, nil
+		}, "nothing to do"),
+	}
+	assert.NoError(t, p.Execute(executor, &cfclient.Client{}, &discardLogger, 1*time.Minute, false))
+	assert.Equal(t, []string{"cf resolved"}, called)
+
+	p = Plan{
+		NewDynamicCommand(func(client *cfclient.Client, logger *logger.CapturingWriter) (Command, error) {
+			return nil, expectedError
+		}, "fails"),
+		NewCfCommand("never"),
+	}
+	assert.Equal(t, expectedError, p.Execute(executor, &cfclient.Client{}, &discardLogger, 1*time.Minute, false))
+	assert.Equal(t, []string{"cf resolved"}, called)
+}
+
 func TestPlan_Compound(t *testing.T) {
 	t.Run("Calls right, and then errors", func(t *testing.T) {
 		expectedError := errors.New("meehp")
@@ -229,4 +289,26 @@ func TestPlan_Compound(t *testing.T) {
 		assert.Error(t, err)
 		assert.Equal(t, []string{"1", "2", "3"}, called)
 	})
+
+	t.Run("Only matches on the output of the left command", func(t *testing.T) {
+		var called []string
+		p := Plan{
+			NewCfCommand("1"),
+			NewCompoundCommand(NewCfCommand("2"), NewCfCommand("3"), func(log []byte) bool {
+				return strings.Contains(string(log), "trigger")
+			}, false),
+		}
+
+		l := logger.NewLogger(ioutil.Discard)
+		err := p.Execute(newMockExecutorWithFunction(func(command Command) ([]string, error) {
+			called = append(called, command.Args()[0])
+			if command.Args()[0] == "1" {
+				l.Println("trigger")
+			}
+			return []string{}, nil
+		}), &cfclient.Client{}, &l, 1*time.Minute, false)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"1", "2"}, called)
+	})
 }
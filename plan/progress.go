@@ -0,0 +1,341 @@
+package plan
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	cfclient "github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/springernature/halfpipe-deploy-resource/logger"
+)
+
+// appSnapshot is what the plans of the resumable commands use of an app.
+type appSnapshot struct {
+	Name      string
+	GUID      string
+	State     string
+	UpdatedAt time.Time
+}
+
+// MarshalJSON writes the snapshot as an array rather than an object, to keep the progress within the size of an annotation.
+func (a appSnapshot) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]string{a.Name, a.GUID, a.State, a.UpdatedAt.UTC().Format(time.RFC3339Nano)})
+}
+
+func (a *appSnapshot) UnmarshalJSON(data []byte) (err error) {
+	var fields []string
+	if err = json.Unmarshal(data, &fields); err != nil {
+		return
+	}
+	if len(fields) != 4 {
+		return fmt.Errorf("an app must have 4 fields, not %d", len(fields))
+	}
+	a.Name, a.GUID, a.State = fields[0], fields[1], fields[2]
+	a.UpdatedAt, err = time.Parse(time.RFC3339Nano, fields[3])
+	return
+}
+
+// progress is how far the execution of a resumable command got before it failed, recorded for the build that failed.
+// It is recorded in an annotation of the candidate, or of the live app when there is no candidate, as the apps
+// outlive the build and, unlike the space, can be updated by a SpaceDeveloper.
+type progress struct {
+	Command string `json:"command"`
+	Version string `json:"version"`
+	GitRef  string `json:"gitRef"`
+	Build   string `json:"build"`
+	// Plan is a fingerprint of the steps of the plan.
+	Plan string `json:"plan"`
+	// Step is the index of the step that failed, the steps before it succeeded.
+	Step       int    `json:"step"`
+	FailedStep string `json:"failedStep"`
+	FailedAt   string `json:"failedAt"`
+	// Planned are the apps of the family the plan was planned with, Failed are the apps when the step failed.
+	Planned []appSnapshot `json:"planned"`
+	Failed  []appSnapshot `json:"failed"`
+	// RecordedOn is the GUID of the app the progress is recorded on. Recording it updates the app, so the
+	// time the app was updated is not compared for it.
+	RecordedOn string `json:"recordedOn"`
+}
+
+// progressAnnotation is the name of the annotation with the progress.
+const progressAnnotation = "progress"
+
+// maxAnnotationLength is the number of characters CF allows in the value of an annotation.
+const maxAnnotationLength = 5000
+
+// fingerprintPlan identifies the steps of a plan. The descriptions of the client commands are left out
+// as some of them, like the age of the releases that cleanup keeps, change between executions.
+func fingerprintPlan(p Plan) string {
+	hash := sha256.New()
+	for _, c := range p {
+		if _, ok := c.(clientCommand); ok {
+			fmt.Fprintln(hash, "client command")
+			continue
+		}
+		fmt.Fprintln(hash, c.String())
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil))[:16]
+}
+
+// setupSteps are the steps that prepare the cf CLI for the other steps.
+func setupSteps(p Plan) (setup Plan) {
+	for _, c := range p {
+		if cmd, ok := c.(command); ok && len(cmd.args) > 0 && (cmd.args[0] == "--version" || cmd.args[0] == "login") {
+			setup = append(setup, c)
+		}
+	}
+	return
+}
+
+func inFamily(appName string, name string) bool {
+	return name == appName ||
+		name == createCandidateAppName(appName) ||
+		name == createOldAppName(appName) ||
+		strings.HasPrefix(name, createDeleteName(appName, 0))
+}
+
+func snapshotFamily(appName string, apps []*resource.App) (snapshot []appSnapshot) {
+	snapshot = []appSnapshot{}
+	for _, app := range apps {
+		if inFamily(appName, app.Name) {
+			snapshot = append(snapshot, appSnapshot{Name: app.Name, GUID: app.GUID, State: app.State, UpdatedAt: app.UpdatedAt})
+		}
+	}
+	return
+}
+
+func (p progress) plannedApps() (apps []*resource.App) {
+	for _, app := range p.Planned {
+		apps = append(apps, &resource.App{Name: app.Name, State: app.State, Resource: resource.Resource{GUID: app.GUID, UpdatedAt: app.UpdatedAt}})
+	}
+	return
+}
+
+// changesSinceFailure describes how the apps of the family have changed since the step failed.
+func (p progress) changesSinceFailure(current []appSnapshot) (changes []string) {
+	byGUID := map[string]appSnapshot{}
+	for _, app := range current {
+		byGUID[app.GUID] = app
+	}
+
+	known := map[string]bool{}
+	for _, failed := range p.Failed {
+		known[failed.GUID] = true
+		now, found := byGUID[failed.GUID]
+		switch {
+		case !found:
+			changes = append(changes, fmt.Sprintf("'%s' has been deleted", failed.Name))
+			continue
+		case now.Name != failed.Name:
+			changes = append(changes, fmt.Sprintf("'%s' has been renamed to '%s'", failed.Name, now.Name))
+		case now.State != failed.State:
+			changes = append(changes, fmt.Sprintf("'%s' was %s and is now %s", failed.Name, failed.State, now.State))
+		case !now.UpdatedAt.Equal(failed.UpdatedAt) && failed.GUID != p.RecordedOn:
+			changes = append(changes, fmt.Sprintf("'%s' has been updated at %s", failed.Name, now.UpdatedAt.UTC().Format(time.RFC3339)))
+		}
+	}
+
+	for _, app := range current {
+		if !known[app.GUID] {
+			changes = append(changes, fmt.Sprintf("'%s' has been created", app.Name))
+		}
+	}
+	return
+}
+
+func recordedProgress(app *resource.App) *string {
+	if app.Metadata == nil {
+		return nil
+	}
+	return app.Metadata.Annotations[fmt.Sprintf("%s/%s", annotationPrefix, progressAnnotation)]
+}
+
+// progressCarrier is the app the progress is recorded on, the candidate or else the live app.
+func progressCarrier(appName string, apps []*resource.App) *resource.App {
+	if candidate := findApp(createCandidateAppName(appName), apps); candidate != nil {
+		return candidate
+	}
+	return findApp(appName, apps)
+}
+
+// loadProgress reads the progress from the apps of the family, preferring the one on the app it is recorded on.
+func loadProgress(request config.Request, apps []*resource.App) (p *progress, err error) {
+	var value *string
+	for _, app := range apps {
+		if inFamily(request.Metadata.AppName, app.Name) && recordedProgress(app) != nil {
+			value = recordedProgress(app)
+		}
+	}
+	if carrier := progressCarrier(request.Metadata.AppName, apps); carrier != nil && recordedProgress(carrier) != nil {
+		value = recordedProgress(carrier)
+	}
+	if value == nil {
+		return
+	}
+
+	p = &progress{}
+	if err = json.Unmarshal([]byte(*value), p); err != nil {
+		err = fmt.Errorf("failed to read the progress of '%s': %w", request.Metadata.AppName, err)
+	}
+	return
+}
+
+// saveProgress records the progress on the candidate, or else the live app, a nil progress removes it from all the apps of the family.
+// The progress of an earlier failure on another app of the family is left, as removing it would update that app, which
+// resuming would then refuse as a change.
+func saveProgress(ctx context.Context, cfClient *cfclient.Client, request config.Request, apps []*resource.App, p *progress) error {
+	if p == nil {
+		for _, app := range apps {
+			if !inFamily(request.Metadata.AppName, app.Name) || recordedProgress(app) == nil {
+				continue
+			}
+			metadata := resource.NewMetadata()
+			metadata.RemoveAnnotation(annotationPrefix, progressAnnotation)
+			if _, err := cfClient.Applications.Update(ctx, app.GUID, &resource.AppUpdate{Metadata: metadata}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	carrier := progressCarrier(request.Metadata.AppName, apps)
+	if carrier == nil {
+		return fmt.Errorf("there is neither '%s' nor '%s' to record the progress on", createCandidateAppName(request.Metadata.AppName), request.Metadata.AppName)
+	}
+	p.RecordedOn = carrier.GUID
+	value, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	if len(value) > maxAnnotationLength {
+		return fmt.Errorf("the progress is %d characters, more than the %d characters of an annotation", len(value), maxAnnotationLength)
+	}
+
+	metadata := resource.NewMetadata()
+	metadata.SetAnnotation(annotationPrefix, progressAnnotation, string(value))
+	_, err = cfClient.Applications.Update(ctx, carrier.GUID, &resource.AppUpdate{Metadata: metadata})
+	return err
+}
+
+// Resumer executes the plans of the resumable commands, see config.ResumableCommands, so that a failed execution
+// can be resumed from the step that failed with params.resume.
+//
+// When a step fails, the index of the step and the apps of the family, both the ones the plan was planned with and
+// the ones when the step failed, are recorded in an annotation of the candidate, or of the live app. Resuming plans with the recorded apps,
+// so the plan is the same as the one that failed, and starts at the step that failed. It refuses when the apps have
+// changed since the step failed, or the plan is not the same anymore, as the remaining steps might then do the wrong thing.
+type Resumer struct {
+	request  config.Request
+	cfClient *cfclient.Client
+	resuming *progress
+}
+
+func NewResumer(request config.Request, cfClient *cfclient.Client) *Resumer {
+	return &Resumer{request: request, cfClient: cfClient}
+}
+
+func (r *Resumer) isResumable() bool {
+	return r.cfClient != nil && config.IsResumable(r.request.Params.Command)
+}
+
+// AppsToPlanWith returns the apps the plan must be planned with, the current apps unless resuming.
+// When resuming they are the apps the failed execution was planned with, after checking that the apps
+// have not changed since the step failed.
+func (r *Resumer) AppsToPlanWith(currentApps []*resource.App) (apps []*resource.App, err error) {
+	if !r.request.Params.Resume || !r.isResumable() {
+		return currentApps, nil
+	}
+
+	request := r.request
+	p, err := loadProgress(request, currentApps)
+	if err != nil {
+		return
+	}
+
+	switch {
+	case p == nil:
+		err = fmt.Errorf("there is no failed %s of '%s' to resume in space '%s', it has either succeeded or not run yet. Run it without resume", request.Params.Command, request.Metadata.AppName, request.Source.Space)
+	case p.Command != request.Params.Command:
+		err = fmt.Errorf("refusing to resume: the execution that failed was %s, not %s", p.Command, request.Params.Command)
+	case p.Version != request.Metadata.Version || p.GitRef != request.Metadata.GitRef:
+		err = fmt.Errorf("refusing to resume: the execution that failed deployed version '%s' of git ref '%s', not version '%s' of git ref '%s'", p.Version, p.GitRef, request.Metadata.Version, request.Metadata.GitRef)
+	default:
+		if changes := p.changesSinceFailure(snapshotFamily(request.Metadata.AppName, currentApps)); len(changes) > 0 {
+			err = fmt.Errorf("refusing to resume: the apps have changed since step %d, '%s', failed at %s:\n  * %s\nRun %s without resume to plan it from the current state", p.Step+1, p.FailedStep, p.FailedAt, strings.Join(changes, "\n  * "), request.Params.Command)
+		}
+	}
+	if err != nil {
+		return
+	}
+
+	r.resuming = p
+	return p.plannedApps(), nil
+}
+
+// Execute executes the plan, from the step that failed when resuming, and records the progress when a step fails.
+// The apps are only updated to record the progress of a failed step and to remove it once the resumed execution succeeds.
+func (r *Resumer) Execute(p Plan, plannedWith []*resource.App, executor Executor, logger *logger.CapturingWriter, timeout time.Duration, isActions bool) error {
+	if !r.isResumable() {
+		return p.Execute(executor, r.cfClient, logger, timeout, isActions)
+	}
+
+	ctx := context.Background()
+	start := 0
+	if r.resuming != nil {
+		if r.resuming.Plan != fingerprintPlan(p) || r.resuming.Step >= len(p) {
+			return errors.New("refusing to resume: the plan is not the same as the plan that failed, as the manifest or the params have changed. Run it without resume to plan it from the current state")
+		}
+		start = r.resuming.Step
+		logger.Println(fmt.Sprintf("Resuming at step %d of %d, '%s', which failed at %s", start+1, len(p), p[start], r.resuming.FailedAt))
+		logger.Println()
+
+		// The cf CLI of this build is not logged in yet, so the login is repeated.
+		if err := setupSteps(p[:start]).Execute(executor, r.cfClient, logger, timeout, isActions); err != nil {
+			return err
+		}
+	}
+
+	executed, err := p.ExecuteFrom(start, executor, r.cfClient, logger, timeout, isActions)
+	if err != nil {
+		current, e := getAppsInOrgSpace(ctx, r.cfClient, r.request.Source.Org, r.request.Source.Space)
+		if e == nil {
+			e = saveProgress(ctx, r.cfClient, r.request, current, &progress{
+				Command:    r.request.Params.Command,
+				Version:    r.request.Metadata.Version,
+				GitRef:     r.request.Metadata.GitRef,
+				Build:      r.request.Metadata.DeployedBy,
+				Plan:       fingerprintPlan(p),
+				Step:       executed,
+				FailedStep: p[executed].String(),
+				FailedAt:   time.Now().UTC().Format(time.RFC3339),
+				Planned:    snapshotFamily(r.request.Metadata.AppName, plannedWith),
+				Failed:     snapshotFamily(r.request.Metadata.AppName, current),
+			})
+		}
+		if e != nil {
+			logger.Println(fmt.Sprintf("Failed to record the progress, so this execution cannot be resumed: %s", e))
+		} else {
+			logger.Println(fmt.Sprintf("Step %d of %d failed, fix the cause and rerun with resume to continue from it", executed+1, len(p)))
+		}
+		return err
+	}
+
+	// Only a resumed execution removes the progress, so that a successful execution does not update the apps.
+	if r.resuming == nil {
+		return nil
+	}
+	current, err := getAppsInOrgSpace(ctx, r.cfClient, r.request.Source.Org, r.request.Source.Space)
+	if err == nil {
+		err = saveProgress(ctx, r.cfClient, r.request, current, nil)
+	}
+	if err != nil {
+		logger.Println(fmt.Sprintf("Failed to remove the progress of the execution that failed: %s", err))
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package plan
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	cfclient "github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/google/uuid"
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/springernature/halfpipe-deploy-resource/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadProgress(t *testing.T) {
+	request := validRequest
+	request.Metadata.AppName = "my-app"
+	annotated := func(name, value string) *resource.App {
+		metadata := resource.NewMetadata()
+		metadata.SetAnnotation(annotationPrefix, progressAnnotation, value)
+		return &resource.App{Name: name, Metadata: metadata}
+	}
+
+	t.Run("nothing recorded", func(t *testing.T) {
+		p, err := loadProgress(request, []*resource.App{{Name: "my-app", Metadata: resource.NewMetadata()}})
+		assert.NoError(t, err)
+		assert.Nil(t, p)
+	})
+
+	t.Run("prefers the candidate", func(t *testing.T) {
+		p, err := loadProgress(request, []*resource.App{
+			annotated("my-app", `{"step": 1}`),
+			annotated("my-app-CANDIDATE", `{"step": 2}`),
+			annotated("other-app", `{"step": 3}`),
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, p.Step)
+	})
+
+	t.Run("only from the family", func(t *testing.T) {
+		p, err := loadProgress(request, []*resource.App{annotated("other-app", `{"step": 3}`)})
+		assert.NoError(t, err)
+		assert.Nil(t, p)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := loadProgress(request, []*resource.App{annotated("my-app", `{"planned": [["my-app"]]}`)})
+		assert.ErrorContains(t, err, "failed to read the progress of 'my-app'")
+	})
+}
+
+func TestProgressFitsInAnAnnotation(t *testing.T) {
+	updated := time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	var apps []appSnapshot
+	for i := 0; i < 12; i++ {
+		apps = append(apps, appSnapshot{Name: createDeleteName("my-rather-long-application-name", i), GUID: uuid.NewString(), State: "STOPPED", UpdatedAt: updated})
+	}
+	p := progress{
+		Command:    config.PROMOTE,
+		Version:    "1234",
+		GitRef:     "0123456789abcdef0123456789abcdef01234567",
+		Build:      "https://concourse.example.com/teams/my-team/pipelines/my-pipeline/jobs/deploy/builds/1234",
+		Step:       9,
+		FailedStep: "cf rename my-rather-long-application-name-CANDIDATE my-rather-long-application-name",
+		Planned:    apps,
+		Failed:     apps,
+	}
+
+	value, err := json.Marshal(p)
+	assert.NoError(t, err)
+	assert.Less(t, len(value), maxAnnotationLength)
+
+	var read progress
+	assert.NoError(t, json.Unmarshal(value, &read))
+	assert.Equal(t, p, read)
+}
+
+func TestFingerprintPlan(t *testing.T) {
+	client := func(description string) Command {
+		return NewClientCommand(func(*cfclient.Client, *logger.CapturingWriter) error { return nil }, description)
+	}
+	p := Plan{NewCfCommand("rename", "my-app", "my-app-OLD"), client("Keeping 'my-app-DELETE', deployed 5m ago")}
+
+	assert.Equal(t, fingerprintPlan(p), fingerprintPlan(Plan{NewCfCommand("rename", "my-app", "my-app-OLD"), client("Keeping 'my-app-DELETE', deployed 6m ago")}))
+	assert.NotEqual(t, fingerprintPlan(p), fingerprintPlan(Plan{NewCfCommand("rename", "my-app", "my-app-DELETE"), client("Keeping 'my-app-DELETE', deployed 5m ago")}))
+	assert.NotEqual(t, fingerprintPlan(p), fingerprintPlan(p[:1]))
+}
+
+func TestSetupSteps(t *testing.T) {
+	p := Plan{
+		NewCfCommand("--version"),
+		NewCfCommand("login", "-a", "api", "-u", "user", "-p", "secret"),
+		NewClientCommand(nil, "Checking the routes in the manifest"),
+		NewCfCommand("rename", "my-app", "my-app-OLD"),
+	}
+	assert.Equal(t, p[:2], setupSteps(p))
+}
+
+func TestSnapshotFamily(t *testing.T) {
+	apps := []*resource.App{
+		{Name: "my-app", State: "STARTED", Resource: resource.Resource{GUID: "1"}},
+		{Name: "my-app-CANDIDATE", State: "STARTED", Resource: resource.Resource{GUID: "2"}},
+		{Name: "my-app-OLD", State: "STOPPED", Resource: resource.Resource{GUID: "3"}},
+		{Name: "my-app-DELETE-1", State: "STOPPED", Resource: resource.Resource{GUID: "4"}},
+		{Name: "my-app-worker", State: "STARTED", Resource: resource.Resource{GUID: "5"}},
+		{Name: "another-app", State: "STARTED", Resource: resource.Resource{GUID: "6"}},
+	}
+
+	var names []string
+	for _, app := range snapshotFamily("my-app", apps) {
+		names = append(names, app.Name)
+	}
+	assert.Equal(t, []string{"my-app", "my-app-CANDIDATE", "my-app-OLD", "my-app-DELETE-1"}, names)
+	assert.Equal(t, apps[:4], progress{Planned: snapshotFamily("my-app", apps)}.plannedApps())
+}
+
+func TestChangesSinceFailure(t *testing.T) {
+	updated := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	p := progress{Failed: []appSnapshot{
+		{Name: "my-app-CANDIDATE", GUID: "1", State: "STARTED", UpdatedAt: updated},
+		{Name: "my-app-OLD", GUID: "2", State: "STOPPED", UpdatedAt: updated},
+	}}
+
+	assert.Empty(t, p.changesSinceFailure(p.Failed))
+
+	assert.Equal(t, []string{
+		"'my-app-CANDIDATE' has been renamed to 'my-app'",
+		"'my-app-OLD' has been deleted",
+		"'my-app-DELETE' has been created",
+	}, p.changesSinceFailure([]appSnapshot{
+		{Name: "my-app", GUID: "1", State: "STARTED", UpdatedAt: updated},
+		{Name: "my-app-DELETE", GUID: "3", State: "STOPPED", UpdatedAt: updated},
+	}))
+
+	p.RecordedOn = "2"
+	assert.Empty(t, p.changesSinceFailure([]appSnapshot{
+		{Name: "my-app-CANDIDATE", GUID: "1", State: "STARTED", UpdatedAt: updated},
+		{Name: "my-app-OLD", GUID: "2", State: "STOPPED", UpdatedAt: updated.Add(time.Minute)},
+	}), "recording the progress updates the app it is recorded on")
+
+	assert.Equal(t, []string{
+		"'my-app-CANDIDATE' has been updated at 2024-01-02T04:04:05Z",
+		"'my-app-OLD' was STOPPED and is now STARTED",
+	}, p.changesSinceFailure([]appSnapshot{
+		{Name: "my-app-CANDIDATE", GUID: "1", State: "STARTED", UpdatedAt: updated.Add(time.Hour)},
+		{Name: "my-app-OLD", GUID: "2", State: "STARTED", UpdatedAt: updated},
+	}))
+}
@@ -2,65 +2,182 @@ package plan
 
 import (
 	"code.cloudfoundry.org/cli/util/manifestparser"
+	"context"
 	"fmt"
+	cfclient "github.com/cloudfoundry/go-cfclient/v3/client"
 	"github.com/cloudfoundry/go-cfclient/v3/resource"
 	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/springernature/halfpipe-deploy-resource/logger"
 	"strings"
 )
 
 type PromotePlan interface {
-	Plan(manifest manifestparser.Application, request config.Request, summary []*resource.App) (pl Plan)
+	Plan(manifest manifestparser.Application, request config.Request) (pl Plan)
 }
 
 type promotePlan struct {
 	privateDomainsInOrg []*resource.Domain
 }
 
-func (p promotePlan) Plan(manifest manifestparser.Application, request config.Request, summary []*resource.App) (pl Plan) {
-	currentLive, currentOld, currentDeletes := p.getPreviousAppState(manifest.Name, summary)
+// promoteApps are the apps of the family when a step of the promote is executed.
+type promoteApps struct {
+	candidate *resource.App
+	live      *resource.App
+	old       *resource.App
+	deletes   []*resource.App
+}
 
-	pl = append(pl, p.addManifestRoutes(manifest)...)
-	pl = append(pl, p.unmapTestRoute(manifest, request)...)
-	pl = append(pl, p.renameOldApp(manifest, currentOld, currentDeletes)...)
-	pl = append(pl, p.renameAndStopCurrentApp(manifest, currentLive)...)
-	pl = append(pl, p.renameCandidateToLive(manifest))
+// promoteStep decides what a step of the promote does from the apps when it is executed, nil when there is nothing to do.
+type promoteStep struct {
+	description string
+	decide      func(apps promoteApps, logger *logger.CapturingWriter) Command
+}
 
+// Plan promotes the candidate to the live app. Each step is decided when it is executed, from the apps at that point,
+// so that promote can be planned before the candidate is pushed, as in halfpipe-all, and a promote that is run again
+// after it failed halfway continues where it stopped instead of failing with "app not found".
+func (p promotePlan) Plan(manifest manifestparser.Application, request config.Request) (pl Plan) {
+	for _, step := range p.steps(manifest, request) {
+		pl = append(pl, NewDynamicCommand(p.resolve(manifest.Name, request, step), step.description))
+	}
 	return
 }
 
-func (p promotePlan) renameOldApp(manifest manifestparser.Application, oldApp *resource.App, currentDeletes []*resource.App) (cmds []Command) {
-	if oldApp != nil {
-		nextI := 0
-		for i := 1; i <= len(currentDeletes); i++ {
-			found := false
-			for _, currentDelete := range currentDeletes {
-				if strings.HasSuffix(currentDelete.Name, fmt.Sprintf("-%d", i)) {
-					found = true
-				}
-			}
-			if !found {
-				nextI = i
-				break
+func (p promotePlan) steps(manifest manifestparser.Application, request config.Request) (steps []promoteStep) {
+	steps = append(steps, p.addManifestRoutes(manifest, request)...)
+	steps = append(steps, p.unmapTestRoute(manifest, request)...)
+	steps = append(steps, p.renameOldApp(manifest))
+	steps = append(steps, p.renameCurrentApp(manifest))
+	steps = append(steps, p.stopOldApp(manifest))
+	steps = append(steps, p.renameCandidateToLive(manifest))
+	return
+}
+
+func (p promotePlan) resolve(appName string, request config.Request, step promoteStep) ResolveCommand {
+	return func(cfClient *cfclient.Client, logger *logger.CapturingWriter) (Command, error) {
+		ctx := context.Background()
+		apps, err := getAppsInOrgSpace(ctx, cfClient, request.Source.Org, request.Source.Space)
+		if err != nil {
+			return nil, err
+		}
+		gitRevision := func(app *resource.App) (string, error) {
+			env, err := cfClient.Applications.GetEnvironmentVariables(ctx, app.GUID)
+			if err != nil || env["GIT_REVISION"] == nil {
+				return "", err
 			}
+			return *env["GIT_REVISION"], nil
 		}
-		cmds = append(cmds, NewCfCommand("rename", createOldAppName(manifest.Name), createDeleteName(manifest.Name, nextI)))
+		return p.decide(appName, request, step, apps, gitRevision, logger)
 	}
-
-	return
 }
 
-func (p promotePlan) renameAndStopCurrentApp(manifest manifestparser.Application, currentLive *resource.App) (cmds []Command) {
-	if currentLive != nil {
-		cmds = append(cmds, NewCfCommand("rename", manifest.Name, createOldAppName(manifest.Name)))
-		if currentLive.State == "STARTED" {
-			cmds = append(cmds, NewCfCommand("stop", createOldAppName(manifest.Name)))
+// decide decides the step from the apps, once the candidate has been renamed to the live app all steps have been done.
+// Without a candidate, the live app must be the version of the request, otherwise the candidate was never pushed or
+// has been deleted, and nothing has been promoted.
+func (p promotePlan) decide(appName string, request config.Request, step promoteStep, apps []*resource.App, gitRevision func(app *resource.App) (string, error), logger *logger.CapturingWriter) (Command, error) {
+	current, err := p.currentApps(appName, apps)
+	if err != nil {
+		return nil, err
+	}
+	if current.candidate == nil {
+		promoted, err := p.isPromoted(current.live, request, gitRevision)
+		if err != nil {
+			return nil, err
+		}
+		if !promoted {
+			return nil, fmt.Errorf("there is no '%s' to promote and '%s' is not version '%s' of git ref '%s', push the candidate first", createCandidateAppName(appName), appName, request.Metadata.Version, request.Metadata.GitRef)
 		}
+		logger.Println(fmt.Sprintf("'%s' has already been promoted to '%s'", createCandidateAppName(appName), appName))
+		return nil, nil
+	}
+	return step.decide(current, logger), nil
+}
+
+// isPromoted is whether the live app is the version of the request, from its provenance or else its GIT_REVISION.
+func (p promotePlan) isPromoted(live *resource.App, request config.Request, gitRevision func(app *resource.App) (string, error)) (bool, error) {
+	gitRef, version := request.Metadata.GitRef, request.Metadata.Version
+	if gitRef == "" && version == "" {
+		return false, nil
+	}
+	if provenance := readProvenance(live.Metadata); provenance.GitRef == gitRef && provenance.Version == version {
+		return true, nil
+	}
+	if gitRef == "" {
+		return false, nil
+	}
+	revision, err := gitRevision(live)
+	return revision == gitRef, err
+}
+
+func (p promotePlan) currentApps(appName string, apps []*resource.App) (current promoteApps, err error) {
+	current.live, current.old, current.deletes = p.getPreviousAppState(appName, apps)
+	current.candidate = findApp(createCandidateAppName(appName), apps)
+	if current.candidate == nil && current.live == nil {
+		err = fmt.Errorf("neither '%s' nor '%s' exist, there is nothing to promote", createCandidateAppName(appName), appName)
 	}
 	return
 }
 
-func (p promotePlan) renameCandidateToLive(manifest manifestparser.Application) Command {
-	return NewCfCommand("rename", createCandidateAppName(manifest.Name), manifest.Name)
+func (p promotePlan) renameOldApp(manifest manifestparser.Application) promoteStep {
+	return promoteStep{
+		description: fmt.Sprintf("Renaming '%s' to the next free '%s' name, if '%s' is replaced", createOldAppName(manifest.Name), createDeleteName(manifest.Name, 0), manifest.Name),
+		decide: func(apps promoteApps, logger *logger.CapturingWriter) Command {
+			if apps.old == nil {
+				logger.Println(fmt.Sprintf("There is no '%s'", createOldAppName(manifest.Name)))
+				return nil
+			}
+			if apps.live == nil {
+				// Either there was no live app, or it has already been renamed to the old app by an earlier promote that failed.
+				logger.Println(fmt.Sprintf("There is no '%s', so '%s' is kept", manifest.Name, createOldAppName(manifest.Name)))
+				return nil
+			}
+
+			nextI := 0
+			for i := 1; i <= len(apps.deletes); i++ {
+				found := false
+				for _, currentDelete := range apps.deletes {
+					if strings.HasSuffix(currentDelete.Name, fmt.Sprintf("-%d", i)) {
+						found = true
+					}
+				}
+				if !found {
+					nextI = i
+					break
+				}
+			}
+			return NewCfCommand("rename", createOldAppName(manifest.Name), createDeleteName(manifest.Name, nextI))
+		},
+	}
+}
+
+func (p promotePlan) renameCurrentApp(manifest manifestparser.Application) promoteStep {
+	return promoteStep{
+		description: fmt.Sprintf("Renaming '%s' to '%s', if it exists", manifest.Name, createOldAppName(manifest.Name)),
+		decide: func(apps promoteApps, logger *logger.CapturingWriter) Command {
+			if apps.live == nil {
+				logger.Println(fmt.Sprintf("There is no '%s'", manifest.Name))
+				return nil
+			}
+			return NewCfCommand("rename", manifest.Name, createOldAppName(manifest.Name))
+		},
+	}
+}
+
+func (p promotePlan) stopOldApp(manifest manifestparser.Application) promoteStep {
+	return promoteStep{
+		description: fmt.Sprintf("Stopping '%s', if it is started", createOldAppName(manifest.Name)),
+		decide: func(apps promoteApps, logger *logger.CapturingWriter) Command {
+			if apps.old == nil || apps.old.State != "STARTED" {
+				logger.Println(fmt.Sprintf("There is no started '%s'", createOldAppName(manifest.Name)))
+				return nil
+			}
+			return NewCfCommand("stop", createOldAppName(manifest.Name))
+		},
+	}
+}
+
+func (p promotePlan) renameCandidateToLive(manifest manifestparser.Application) promoteStep {
+	return candidateStep(NewCfCommand("rename", createCandidateAppName(manifest.Name), manifest.Name))
 }
 
 func (p promotePlan) getPreviousAppState(manifestAppName string, summary []*resource.App) (currentLive, currentOld *resource.App, currentDeletes []*resource.App) {
@@ -88,68 +205,34 @@ func (p promotePlan) getPreviousAppState(manifestAppName string, summary []*reso
 	return
 }
 
-func (p promotePlan) routes(man manifestparser.Application) (rs []string) {
-	rawRoutes := []any{}
-
-	if man.RemainingManifestFields["routes"] != nil {
-		rawRoutes = man.RemainingManifestFields["routes"].([]any)
-	}
-
-	for _, r := range rawRoutes {
-		route := r.(map[any]any)["route"].(string)
-		rs = append(rs, route)
-	}
-	return rs
-}
-
-func (p promotePlan) addManifestRoutes(man manifestparser.Application) (cmds []Command) {
-	isPrivateDomain := func(r string) bool {
-		for _, domain := range p.privateDomainsInOrg {
-			if r == domain.Name {
-				return true
-			}
-		}
-		return false
-	}
-
-	for _, route := range p.routes(man) {
-		splitOnPath := strings.Split(route, "/")
-		if isPrivateDomain(splitOnPath[0]) {
-			var mapRoute Command
-			if strings.Contains(route, "/") {
-				mapRoute = NewCfCommand("map-route", createCandidateAppName(man.Name), splitOnPath[0], "--path", splitOnPath[1])
-			} else {
-				mapRoute = NewCfCommand("map-route", createCandidateAppName(man.Name), route)
-			}
-
-			cmds = append(cmds, mapRoute)
-		} else {
-			parts := strings.Split(route, ".")
-			hostname := parts[0]
-			domain := strings.Join(parts[1:], ".")
-			if strings.Contains(domain, "/") {
-				partsWithPath := strings.Split(domain, "/")
-				domain = partsWithPath[0]
-				path := strings.Join(partsWithPath[1:], "/")
-				mapRoute := NewCfCommand("map-route", createCandidateAppName(man.Name), domain, "--hostname", hostname, "--path", path)
-				cmds = append(cmds, mapRoute)
-			} else {
-				mapRoute := NewCfCommand("map-route", createCandidateAppName(man.Name), domain, "--hostname", hostname)
-				cmds = append(cmds, mapRoute)
-			}
-		}
+func (p promotePlan) addManifestRoutes(man manifestparser.Application, request config.Request) (steps []promoteStep) {
+	// Problems parsing the routes are reported by the route check that runs before promote,
+	// here we map them as far as they could be parsed.
+	routes, _ := parseManifestRoutes(man, p.privateDomainsInOrg)
+	for _, route := range routes {
+		steps = append(steps, candidateStep(mapRouteCommand(createCandidateAppName(man.Name), route, request.Params.CliVersion)))
 	}
 	return
 }
 
-func (p promotePlan) unmapTestRoute(man manifestparser.Application, request config.Request) (cmds []Command) {
+func (p promotePlan) unmapTestRoute(man manifestparser.Application, request config.Request) (steps []promoteStep) {
 	if !man.NoRoute {
 		unmapRoute := NewCfCommand("unmap-route", createCandidateAppName(man.Name), request.Params.TestDomain, "--hostname", createCandidateHostname(man, request))
-		cmds = append(cmds, unmapRoute)
+		steps = append(steps, candidateStep(unmapRoute))
 	}
 	return
 }
 
+// candidateStep is a step that is always executed while there is a candidate.
+func candidateStep(command Command) promoteStep {
+	return promoteStep{
+		description: command.String(),
+		decide: func(promoteApps, *logger.CapturingWriter) Command {
+			return command
+		},
+	}
+}
+
 func NewPromotePlan(privateDomainsInOrg []*resource.Domain) PromotePlan {
 	return promotePlan{
 		privateDomainsInOrg: privateDomainsInOrg,
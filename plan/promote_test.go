@@ -1,12 +1,45 @@
 package plan
 
 import (
+	"code.cloudfoundry.org/cli/util/manifestparser"
 	"github.com/cloudfoundry/go-cfclient/v3/resource"
 	"github.com/springernature/halfpipe-deploy-resource"
+	"github.com/springernature/halfpipe-deploy-resource/config"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"testing"
 )
 
+// executePromote decides the steps of the promote one after the other, like they are when the plan is executed,
+// and renames and stops the apps like CF does. It returns the commands that are executed.
+func executePromote(t *testing.T, promote PromotePlan, man manifestparser.Application, apps []*resource.App) (executed Plan) {
+	return executePromoteRequest(t, promote, man, validRequest, apps)
+}
+
+func executePromoteRequest(t *testing.T, promote PromotePlan, man manifestparser.Application, request config.Request, apps []*resource.App) (executed Plan) {
+	p := promote.(promotePlan)
+	for _, step := range p.steps(man, request) {
+		command, err := p.decide(man.Name, request, step, apps, noGitRevision, &discardLogger)
+		require.NoError(t, err)
+		if command == nil {
+			continue
+		}
+
+		executed = append(executed, command)
+		switch args := command.Args(); args[0] {
+		case "rename":
+			findApp(args[1], apps).Name = args[2]
+		case "stop":
+			findApp(args[1], apps).State = "STOPPED"
+		}
+	}
+	return
+}
+
+func noGitRevision(*resource.App) (string, error) {
+	return "", nil
+}
+
 func TestPromoteWorkerApp(t *testing.T) {
 	t.Run("No previously deployed version", func(t *testing.T) {
 		summary := []*resource.App{
@@ -26,7 +59,7 @@ func TestPromoteWorkerApp(t *testing.T) {
 			NewCfCommand("rename", createCandidateAppName(man.Name), man.Name),
 		}
 
-		plan := NewPromotePlan([]*resource.Domain{}).Plan(man, validRequest, summary)
+		plan := executePromote(t, NewPromotePlan([]*resource.Domain{}), man, summary)
 		assert.Equal(t, expectedPlan, plan)
 	})
 
@@ -53,8 +86,7 @@ func TestPromoteWorkerApp(t *testing.T) {
 			NewCfCommand("rename", createCandidateAppName(man.Name), man.Name),
 		}
 
-		plan := NewPromotePlan([]*resource.Domain{}).Plan(man, validRequest, summary)
-		//assert.Nil(t, err)
+		plan := executePromote(t, NewPromotePlan([]*resource.Domain{}), man, summary)
 		assert.Equal(t, expectedPlan, plan)
 	})
 
@@ -82,7 +114,7 @@ func TestPromoteWorkerApp(t *testing.T) {
 			NewCfCommand("rename", createCandidateAppName(man.Name), man.Name),
 		}
 
-		plan := NewPromotePlan([]*resource.Domain{}).Plan(man, validRequest, summary)
+		plan := executePromote(t, NewPromotePlan([]*resource.Domain{}), man, summary)
 		assert.Equal(t, expectedPlan, plan)
 	})
 
@@ -115,7 +147,7 @@ func TestPromoteWorkerApp(t *testing.T) {
 			NewCfCommand("rename", createCandidateAppName(man.Name), man.Name),
 		}
 
-		plan := NewPromotePlan([]*resource.Domain{}).Plan(man, validRequest, summary)
+		plan := executePromote(t, NewPromotePlan([]*resource.Domain{}), man, summary)
 		assert.Equal(t, expectedPlan, plan)
 	})
 
@@ -152,7 +184,7 @@ func TestPromoteWorkerApp(t *testing.T) {
 			NewCfCommand("rename", createCandidateAppName(man.Name), man.Name),
 		}
 
-		plan := NewPromotePlan([]*resource.Domain{}).Plan(man, validRequest, summary)
+		plan := executePromote(t, NewPromotePlan([]*resource.Domain{}), man, summary)
 		assert.Equal(t, expectedPlan, plan)
 	})
 
@@ -197,7 +229,7 @@ func TestPromoteWorkerApp(t *testing.T) {
 			NewCfCommand("rename", createCandidateAppName(man.Name), man.Name),
 		}
 
-		plan := NewPromotePlan([]*resource.Domain{}).Plan(man, validRequest, summary)
+		plan := executePromote(t, NewPromotePlan([]*resource.Domain{}), man, summary)
 		assert.Equal(t, expectedPlan, plan)
 	})
 }
@@ -226,7 +258,7 @@ func TestPromoteNormalApp(t *testing.T) {
 			NewCfCommand("rename", createCandidateAppName(man.Name), man.Name),
 		}
 
-		plan := NewPromotePlan([]*resource.Domain{}).Plan(man, validRequest, summary)
+		plan := executePromote(t, NewPromotePlan([]*resource.Domain{}), man, summary)
 		assert.Equal(t, expectedPlan, plan)
 	})
 
@@ -253,7 +285,7 @@ func TestPromoteNormalApp(t *testing.T) {
 			NewCfCommand("rename", createCandidateAppName(man.Name), man.Name),
 		}
 
-		plan := NewPromotePlan([]*resource.Domain{}).Plan(man, validRequest, summary)
+		plan := executePromote(t, NewPromotePlan([]*resource.Domain{}), man, summary)
 		assert.Equal(t, expectedPlan, plan)
 	})
 
@@ -286,7 +318,7 @@ func TestPromoteNormalApp(t *testing.T) {
 			NewCfCommand("rename", createCandidateAppName(man.Name), man.Name),
 		}
 
-		plan := NewPromotePlan(privateRoutesInOrg).Plan(man, validRequest, summary)
+		plan := executePromote(t, NewPromotePlan(privateRoutesInOrg), man, summary)
 		assert.Equal(t, expectedPlan, plan)
 	})
 
@@ -319,7 +351,7 @@ func TestPromoteNormalApp(t *testing.T) {
 			NewCfCommand("rename", createCandidateAppName(man.Name), man.Name),
 		}
 
-		plan := NewPromotePlan(privateRoutesInOrg).Plan(man, validRequest, summary)
+		plan := executePromote(t, NewPromotePlan(privateRoutesInOrg), man, summary)
 		assert.Equal(t, expectedPlan, plan)
 	})
 
@@ -352,7 +384,7 @@ func TestPromoteNormalApp(t *testing.T) {
 			NewCfCommand("rename", createCandidateAppName(man.Name), man.Name),
 		}
 
-		plan := NewPromotePlan(privateRoutesInOrg).Plan(man, validRequest, summary)
+		plan := executePromote(t, NewPromotePlan(privateRoutesInOrg), man, summary)
 		assert.Equal(t, expectedPlan, plan)
 	})
 
@@ -399,7 +431,94 @@ func TestPromoteNormalApp(t *testing.T) {
 			NewCfCommand("rename", "myApp-CANDIDATE", "myApp"),
 		}
 
-		plan := NewPromotePlan(privateRoutesInOrg).Plan(man, validRequest, summary)
+		plan := executePromote(t, NewPromotePlan(privateRoutesInOrg), man, summary)
 		assert.Equal(t, expectedPlan, plan)
 	})
 }
+
+func TestPromotePlanIsDecidedWhenExecuted(t *testing.T) {
+	man := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp
+  routes:
+  - route: myroute.domain1.com
+`).Applications[0]
+
+	var descriptions []string
+	for _, command := range NewPromotePlan([]*resource.Domain{}).Plan(man, validRequest) {
+		assert.IsType(t, dynamicCommand{}, command)
+		descriptions = append(descriptions, command.String())
+	}
+	assert.Equal(t, []string{
+		"cf map-route myApp-CANDIDATE domain1.com --hostname myroute",
+		"cf unmap-route myApp-CANDIDATE " + validRequest.Params.TestDomain + " --hostname " + createCandidateHostname(man, validRequest),
+		"Renaming 'myApp-OLD' to the next free 'myApp-DELETE' name, if 'myApp' is replaced",
+		"Renaming 'myApp' to 'myApp-OLD', if it exists",
+		"Stopping 'myApp-OLD', if it is started",
+		"cf rename myApp-CANDIDATE myApp",
+	}, descriptions)
+}
+
+func TestPromoteConverges(t *testing.T) {
+	man := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp
+  no-route: true
+`).Applications[0]
+
+	t.Run("After the live app has been renamed to the old app", func(t *testing.T) {
+		apps := []*resource.App{
+			{Name: "myApp-CANDIDATE", State: "STARTED"},
+			{Name: "myApp-OLD", State: "STARTED"},
+			{Name: "myApp-DELETE", State: "STOPPED"},
+		}
+
+		assert.Equal(t, Plan{
+			NewCfCommand("stop", "myApp-OLD"),
+			NewCfCommand("rename", "myApp-CANDIDATE", "myApp"),
+		}, executePromote(t, NewPromotePlan([]*resource.Domain{}), man, apps))
+	})
+
+	t.Run("After the candidate has been promoted", func(t *testing.T) {
+		request := validRequest
+		request.Metadata.GitRef, request.Metadata.Version = "abc", "2"
+		metadata := resource.NewMetadata()
+		metadata.SetAnnotation(annotationPrefix, "git-ref", "abc")
+		metadata.SetAnnotation(annotationPrefix, "version", "2")
+		apps := []*resource.App{
+			{Name: "myApp", State: "STARTED", Metadata: metadata},
+			{Name: "myApp-OLD", State: "STOPPED"},
+		}
+
+		assert.Empty(t, executePromoteRequest(t, NewPromotePlan([]*resource.Domain{}), man, request, apps))
+		assert.Equal(t, []string{"myApp", "myApp-OLD"}, []string{apps[0].Name, apps[1].Name})
+	})
+
+	t.Run("After the candidate has been promoted, from the GIT_REVISION", func(t *testing.T) {
+		request := validRequest
+		request.Metadata.GitRef = "abc"
+		p := NewPromotePlan([]*resource.Domain{}).(promotePlan)
+		gitRevision := func(*resource.App) (string, error) { return "abc", nil }
+
+		command, err := p.decide(man.Name, request, p.renameCandidateToLive(man), []*resource.App{{Name: "myApp", State: "STARTED"}}, gitRevision, &discardLogger)
+		assert.NoError(t, err)
+		assert.Nil(t, command)
+	})
+
+	t.Run("Without a candidate, when the live app is another version", func(t *testing.T) {
+		request := validRequest
+		request.Metadata.GitRef, request.Metadata.Version = "abc", "2"
+		metadata := resource.NewMetadata()
+		metadata.SetAnnotation(annotationPrefix, "git-ref", "abc")
+		metadata.SetAnnotation(annotationPrefix, "version", "1")
+		p := NewPromotePlan([]*resource.Domain{}).(promotePlan)
+		gitRevision := func(*resource.App) (string, error) { return "def", nil }
+
+		_, err := p.decide(man.Name, request, p.renameCandidateToLive(man), []*resource.App{{Name: "myApp", State: "STARTED", Metadata: metadata}}, gitRevision, &discardLogger)
+		assert.EqualError(t, err, "there is no 'myApp-CANDIDATE' to promote and 'myApp' is not version '2' of git ref 'abc', push the candidate first")
+	})
+
+	t.Run("Without a candidate or live app", func(t *testing.T) {
+		p := NewPromotePlan([]*resource.Domain{}).(promotePlan)
+		_, err := p.decide(man.Name, validRequest, p.renameCandidateToLive(man), []*resource.App{{Name: "myApp-OLD"}}, noGitRevision, &discardLogger)
+		assert.EqualError(t, err, "neither 'myApp-CANDIDATE' nor 'myApp' exist, there is nothing to promote")
+	})
+}
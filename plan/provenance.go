@@ -0,0 +1,137 @@
+package plan
+
+import (
+	"context"
+	"fmt"
+	cfclient "github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/springernature/halfpipe-deploy-resource/logger"
+	"time"
+)
+
+// provenance is where a deployed app comes from. It is written as annotations on the app and its droplet
+// next to the GIT_REVISION, BUILD_VERSION, EE_DEPLOYED_BY and EE_PIPELINE env vars, as annotations can be
+// read without access to the env of the app and can be changed without a restage.
+type provenance struct {
+	GitRepo    string `json:"gitRepo,omitempty"`
+	GitRef     string `json:"gitRef,omitempty"`
+	Version    string `json:"version,omitempty"`
+	DockerTag  string `json:"dockerTag,omitempty"`
+	Pipeline   string `json:"pipeline,omitempty"`
+	Build      string `json:"build,omitempty"`
+	DeployedAt string `json:"deployedAt,omitempty"`
+}
+
+func (p provenance) fields() []struct{ key, value string } {
+	return []struct{ key, value string }{
+		{"git-repo", p.GitRepo},
+		{"git-ref", p.GitRef},
+		{"version", p.Version},
+		{"docker-tag", p.DockerTag},
+		{"pipeline", p.Pipeline},
+		{"build", p.Build},
+		{"deployed-at", p.DeployedAt},
+	}
+}
+
+func newProvenance(request config.Request, now time.Time) provenance {
+	return provenance{
+		GitRepo:    request.Metadata.GitRepo,
+		GitRef:     request.Metadata.GitRef,
+		Version:    request.Metadata.Version,
+		DockerTag:  request.Metadata.DockerTag,
+		Pipeline:   request.Metadata.Pipeline,
+		Build:      request.Metadata.DeployedBy,
+		DeployedAt: now.UTC().Format(time.RFC3339),
+	}
+}
+
+// metadata returns the annotations of the provenance, values that are not known are removed from earlier deploys.
+func (p provenance) metadata() *resource.Metadata {
+	metadata := resource.NewMetadata()
+	for _, field := range p.fields() {
+		if field.value == "" {
+			metadata.RemoveAnnotation(annotationPrefix, field.key)
+			continue
+		}
+		metadata.SetAnnotation(annotationPrefix, field.key, field.value)
+	}
+	return metadata
+}
+
+// readProvenance reads the provenance back from the annotations of an app or droplet.
+func readProvenance(metadata *resource.Metadata) (p provenance) {
+	if metadata == nil {
+		return
+	}
+	value := func(key string) string {
+		if v := metadata.Annotations[fmt.Sprintf("%s/%s", annotationPrefix, key)]; v != nil {
+			return *v
+		}
+		return ""
+	}
+	return provenance{
+		GitRepo:    value("git-repo"),
+		GitRef:     value("git-ref"),
+		Version:    value("version"),
+		DockerTag:  value("docker-tag"),
+		Pipeline:   value("pipeline"),
+		Build:      value("build"),
+		DeployedAt: value("deployed-at"),
+	}
+}
+
+type ProvenancePlan interface {
+	Plan(appName string, request config.Request) (pl Plan)
+}
+
+type provenancePlan struct{}
+
+func (p provenancePlan) Plan(appName string, request config.Request) (pl Plan) {
+	desc := fmt.Sprintf("Recording where '%s' comes from in the annotations of the app and its droplet", appName)
+	pl = append(pl, NewClientCommand(p.createFunc(appName, request), desc))
+	return
+}
+
+func (p provenancePlan) createFunc(appName string, request config.Request) func(*cfclient.Client, *logger.CapturingWriter) error {
+	return func(cfClient *cfclient.Client, logger *logger.CapturingWriter) error {
+		ctx := context.Background()
+		apps, err := getAppsInOrgSpace(ctx, cfClient, request.Source.Org, request.Source.Space)
+		if err != nil {
+			return err
+		}
+		app := findApp(appName, apps)
+		if app == nil {
+			return fmt.Errorf("app '%s' not found", appName)
+		}
+
+		prov := newProvenance(request, time.Now())
+		for _, field := range prov.fields() {
+			if field.value != "" {
+				logger.Println(fmt.Sprintf("%s/%s: %s", annotationPrefix, field.key, field.value))
+			}
+		}
+
+		if _, err := cfClient.Applications.Update(ctx, app.GUID, &resource.AppUpdate{Name: app.Name, Metadata: prov.metadata()}); err != nil {
+			return fmt.Errorf("failed to annotate '%s': %w", app.Name, err)
+		}
+
+		droplet, err := cfClient.Droplets.GetCurrentAssociationForApp(ctx, app.GUID)
+		if err != nil {
+			return err
+		}
+		if droplet.Data.GUID == "" {
+			logger.Println(fmt.Sprintf("'%s' has no droplet to annotate", app.Name))
+		} else if _, err := cfClient.Droplets.Update(ctx, droplet.Data.GUID, &resource.DropletUpdate{Metadata: prov.metadata()}); err != nil {
+			return fmt.Errorf("failed to annotate droplet '%s' of '%s': %w", droplet.Data.GUID, app.Name, err)
+		}
+
+		logger.Println("OK")
+		return nil
+	}
+}
+
+func NewProvenancePlan() ProvenancePlan {
+	return provenancePlan{}
+}
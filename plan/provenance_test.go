@@ -0,0 +1,52 @@
+package plan
+
+import (
+	halfpipe_deploy_resource "github.com/springernature/halfpipe-deploy-resource"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestProvenancePlan(t *testing.T) {
+	p := NewProvenancePlan().Plan("myApp-CANDIDATE", validRequest)
+
+	assert.Len(t, p, 1)
+	assert.Equal(t, "Recording where 'myApp-CANDIDATE' comes from in the annotations of the app and its droplet", p[0].String())
+}
+
+func TestProvenanceAnnotations(t *testing.T) {
+	r := validRequest
+	r.Metadata.GitRepo = "git@github.com:springernature/myApp.git"
+	r.Metadata.GitRef = "abc123"
+	r.Metadata.Version = "42"
+	r.Metadata.Pipeline = "https://concourse/teams/t/pipelines/p"
+	r.Metadata.DeployedBy = "https://concourse/teams/t/pipelines/p/jobs/deploy/builds/7"
+
+	prov := newProvenance(r, time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600)))
+	metadata := prov.metadata()
+
+	value := func(key string) *string {
+		return metadata.Annotations["halfpipe.io/"+key]
+	}
+	assert.Len(t, metadata.Annotations, 7)
+	assert.Equal(t, "abc123", *value("git-ref"))
+	assert.Equal(t, "42", *value("version"))
+	assert.Equal(t, "2024-01-02T02:04:05Z", *value("deployed-at"))
+	assert.Equal(t, "https://concourse/teams/t/pipelines/p/jobs/deploy/builds/7", *value("build"))
+	assert.Nil(t, value("docker-tag"), "unknown values are removed")
+
+	t.Run("reads back what it writes", func(t *testing.T) {
+		expected := prov
+		assert.Equal(t, expected, readProvenance(metadata))
+		assert.Equal(t, provenance{}, readProvenance(nil))
+	})
+}
+
+func TestProvenanceInStatus(t *testing.T) {
+	man := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp`).Applications[0]
+
+	live := appStatus{Name: "myApp", Role: roleLive, State: "STARTED", Provenance: provenance{GitRef: "abc"}}
+
+	assert.Empty(t, statusPlan{}.problems(man, []appStatus{live}))
+}
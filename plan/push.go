@@ -9,12 +9,20 @@ import (
 )
 
 type PushPlan interface {
-	Plan(manifest manifestparser.Application, request config.Request) (pl Plan)
+	Plan(manifest manifestparser.Application, request config.Request) (pl Plan, err error)
 }
 
-type pushPlan struct{}
+type pushPlan struct {
+	liveHasNetworkPolicies bool
+	inherited              Inherited
+}
+
+func (p pushPlan) Plan(manifest manifestparser.Application, request config.Request) (pl Plan, err error) {
+	preStartCommands, err := p.preStartCommands(manifest, request)
+	if err != nil {
+		return nil, err
+	}
 
-func (p pushPlan) Plan(manifest manifestparser.Application, request config.Request) (pl Plan) {
 	pl = append(pl, p.pushCommand(manifest, request))
 
 	if !manifest.NoRoute {
@@ -24,12 +32,11 @@ func (p pushPlan) Plan(manifest manifestparser.Application, request config.Reque
 			AddToArgs("-n", createCandidateHostname(manifest, request)))
 	}
 
-	if preStartArs := strings.Split(request.Params.PreStartCommand, "; "); request.Params.PreStartCommand != "" && len(preStartArs) > 0 {
-		for _, prestartArg := range preStartArs {
-			args := strings.Split(prestartArg, " ")[1:]
-			pl = append(pl, NewCfCommand(args...))
-		}
+	if p.liveHasNetworkPolicies || len(request.Params.NetworkPolicies) > 0 {
+		pl = append(pl, NewNetworkPoliciesPlan().Plan(manifest, request)...)
 	}
+	pl = append(pl, NewInheritPlan(p.inherited).Plan(manifest, request)...)
+	pl = append(pl, preStartCommands...)
 
 	pl = append(pl, NewCompoundCommand(
 		NewCfCommand("start").
@@ -46,6 +53,21 @@ func (p pushPlan) Plan(manifest manifestparser.Application, request config.Reque
 	return
 }
 
+func (p pushPlan) preStartCommands(manifest manifestparser.Application, request config.Request) (cmds []Command, err error) {
+	preStartCommands, err := config.ParsePreStartCommand(request.Params.PreStartCommand)
+	if err != nil {
+		return nil, err
+	}
+	for _, preStartCommand := range preStartCommands {
+		var args []string
+		for _, arg := range preStartCommand[1:] {
+			args = append(args, strings.Replace(arg, config.CandidateAppNamePlaceholder, createCandidateAppName(manifest.Name), -1))
+		}
+		cmds = append(cmds, NewCfCommand(args...))
+	}
+	return
+}
+
 func (p pushPlan) pushCommand(manifest manifestparser.Application, request config.Request) Command {
 	pushCommand := NewCfCommand("push").
 		AddToArgs(createCandidateAppName(manifest.Name)).
@@ -81,6 +103,8 @@ func (p pushPlan) formatDockerImage(man manifestparser.Application, dockerTag st
 	return image
 }
 
-func NewPushPlan() PushPlan {
-	return pushPlan{}
+// NewPushPlan plans the push of the candidate, liveHasNetworkPolicies is whether the live app has network policies
+// to copy to the candidate, see LiveHasNetworkPolicies, and inherited is what it inherits, see LookupInherited.
+func NewPushPlan(liveHasNetworkPolicies bool, inherited Inherited) PushPlan {
+	return pushPlan{liveHasNetworkPolicies: liveHasNetworkPolicies, inherited: inherited}
 }
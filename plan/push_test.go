@@ -2,10 +2,12 @@ package plan
 
 import (
 	"fmt"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
 	"github.com/google/uuid"
 	halfpipe_deploy_resource "github.com/springernature/halfpipe-deploy-resource"
 	"github.com/springernature/halfpipe-deploy-resource/config"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"testing"
 )
 
@@ -54,7 +56,8 @@ func TestNormalApp(t *testing.T) {
 			applicationManifest := halfpipe_deploy_resource.ParseManifest(`applications:
 - name: My_App`).Applications[0]
 
-			p := NewPushPlan().Plan(applicationManifest, requestWithUnderscore)
+			p, err := NewPushPlan(false, Inherited{}).Plan(applicationManifest, requestWithUnderscore)
+			require.NoError(t, err)
 			assert.Len(t, p, 3)
 			assert.Equal(t, "cf push My_App-CANDIDATE -f path/to/manifest.yml -p path/to/app --no-route --no-start", p[0].String())
 			assert.Equal(t, "cf map-route My_App-CANDIDATE kehe.com -n My-App-this-is-a-space-CANDIDATE", p[1].String())
@@ -67,7 +70,8 @@ func TestNormalApp(t *testing.T) {
 
 			r := request
 			r.Params.Instances = 1
-			p := NewPushPlan().Plan(applicationManifest, r)
+			p, err := NewPushPlan(false, Inherited{}).Plan(applicationManifest, r)
+			require.NoError(t, err)
 			assert.Len(t, p, 3)
 			assert.Equal(t, "cf push MyApp-CANDIDATE -f path/to/manifest.yml -i 1 -p path/to/app --no-route --no-start", p[0].String())
 			assert.Equal(t, "cf map-route MyApp-CANDIDATE kehe.com -n MyApp-c-CANDIDATE", p[1].String())
@@ -79,7 +83,8 @@ func TestNormalApp(t *testing.T) {
 
 			r := request
 			r.Params.PreStartCommand = "cf something; cf somethingElse"
-			p := NewPushPlan().Plan(applicationManifest, r)
+			p, err := NewPushPlan(false, Inherited{}).Plan(applicationManifest, r)
+			require.NoError(t, err)
 
 			assert.Len(t, p, 5)
 			assert.Equal(t, "cf push MyApp-CANDIDATE -f path/to/manifest.yml -p path/to/app --no-route --no-start", p[0].String())
@@ -88,6 +93,72 @@ func TestNormalApp(t *testing.T) {
 			assert.Equal(t, "cf somethingElse", p[3].String())
 			assert.Equal(t, "cf start MyApp-CANDIDATE || cf logs MyApp-CANDIDATE --recent", p[4].String())
 		})
+		t.Run("With a pre start that does not parse", func(t *testing.T) {
+			applicationManifest := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: MyApp`).Applications[0]
+
+			r := request
+			r.Params.PreStartCommand = `cf set-env $CANDIDATE_APP_NAME KEY "some value`
+			_, err := NewPushPlan(false, Inherited{}).Plan(applicationManifest, r)
+
+			assert.Equal(t, config.PreStartCommandParseError(r.Params.PreStartCommand, "unterminated double quote"), err)
+		})
+		t.Run("With quoted pre start using the candidate placeholder", func(t *testing.T) {
+			applicationManifest := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: MyApp`).Applications[0]
+
+			r := request
+			r.Params.PreStartCommand = `cf set-env $CANDIDATE_APP_NAME KEY "some value";cf bind-service $CANDIDATE_APP_NAME my-db`
+			p, err := NewPushPlan(false, Inherited{}).Plan(applicationManifest, r)
+			require.NoError(t, err)
+
+			assert.Len(t, p, 5)
+			assert.Equal(t, []string{"set-env", "MyApp-CANDIDATE", "KEY", "some value"}, p[2].Args())
+			assert.Equal(t, []string{"bind-service", "MyApp-CANDIDATE", "my-db"}, p[3].Args())
+		})
+	})
+
+	t.Run("Inherits from the live app before start", func(t *testing.T) {
+		applicationManifest := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: MyApp`).Applications[0]
+
+		r := request
+		r.Params.Inherit = config.Inherit{Scale: true}
+		r.Params.PreStartCommand = "cf something"
+		inherited := Inherited{Live: true, Processes: []*resource.Process{{Type: "web", Instances: 3, MemoryInMB: 1024, DiskInMB: 2048}}}
+		p, err := NewPushPlan(false, inherited).Plan(applicationManifest, r)
+		require.NoError(t, err)
+
+		assert.Len(t, p, 5)
+		assert.Equal(t, "Inheriting instances, memory and disk of 'MyApp' for 'MyApp-CANDIDATE': 'web' with 3 instances, 1024MB memory and 2048MB disk", p[2].String())
+		assert.Equal(t, "cf something", p[3].String())
+		assert.Equal(t, "cf start MyApp-CANDIDATE || cf logs MyApp-CANDIDATE --recent", p[4].String())
+	})
+
+	t.Run("Network policies", func(t *testing.T) {
+		applicationManifest := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: MyApp`).Applications[0]
+
+		t.Run("of the live app", func(t *testing.T) {
+			p, err := NewPushPlan(true, Inherited{}).Plan(applicationManifest, request)
+			require.NoError(t, err)
+
+			assert.Len(t, p, 4)
+			assert.Equal(t, "Copying network policies of 'MyApp' to 'MyApp-CANDIDATE'", p[2].String())
+			assert.Equal(t, "cf start MyApp-CANDIDATE || cf logs MyApp-CANDIDATE --recent", p[3].String())
+		})
+
+		t.Run("declared", func(t *testing.T) {
+			r := request
+			r.Params.NetworkPolicies = []config.NetworkPolicy{{Destination: "backend"}}
+			p, err := NewPushPlan(false, Inherited{}).Plan(applicationManifest, r)
+			require.NoError(t, err)
+
+			assert.Len(t, p, 5)
+			assert.Equal(t, "Copying network policies of 'MyApp' to 'MyApp-CANDIDATE'", p[2].String())
+			assert.Equal(t, "Ensuring network policy from 'MyApp-CANDIDATE' to 'backend' with protocol 'tcp' and ports '8080'", p[3].String())
+			assert.Equal(t, "cf start MyApp-CANDIDATE || cf logs MyApp-CANDIDATE --recent", p[4].String())
+		})
 	})
 
 	t.Run("Worker app", func(t *testing.T) {
@@ -95,7 +166,8 @@ func TestNormalApp(t *testing.T) {
 - name: MyApp
   no-route: True`).Applications[0]
 
-		p := NewPushPlan().Plan(applicationManifest, request)
+		p, err := NewPushPlan(false, Inherited{}).Plan(applicationManifest, request)
+		require.NoError(t, err)
 		assert.Len(t, p, 2)
 		assert.Equal(t, "cf push MyApp-CANDIDATE -f path/to/manifest.yml -p path/to/app --no-route --no-start", p[0].String())
 		assert.Equal(t, "cf start MyApp-CANDIDATE || cf logs MyApp-CANDIDATE --recent", p[1].String())
@@ -112,7 +184,8 @@ func TestDocker(t *testing.T) {
 		r := request
 		r.Params.DockerUsername = "asd"
 
-		p := NewPushPlan().Plan(applicationManifest, r)
+		p, err := NewPushPlan(false, Inherited{}).Plan(applicationManifest, r)
+		require.NoError(t, err)
 		assert.Len(t, p, 3)
 		assert.Equal(t, "CF_DOCKER_PASSWORD=... cf push MyApp-CANDIDATE -f path/to/manifest.yml --docker-image wheep/whuup --docker-username asd --no-route --no-start", p[0].String())
 		assert.Equal(t, "cf map-route MyApp-CANDIDATE kehe.com -n MyApp-c-CANDIDATE", p[1].String())
@@ -129,7 +202,8 @@ func TestDocker(t *testing.T) {
 		r := request
 		r.Params.DockerUsername = "kehe"
 
-		p := NewPushPlan().Plan(applicationManifest, r)
+		p, err := NewPushPlan(false, Inherited{}).Plan(applicationManifest, r)
+		require.NoError(t, err)
 		assert.Len(t, p, 2)
 		assert.Equal(t, "CF_DOCKER_PASSWORD=... cf push MyApp-CANDIDATE -f path/to/manifest.yml --docker-image wheep/whuup --docker-username kehe --no-route --no-start", p[0].String())
 		assert.Equal(t, "cf start MyApp-CANDIDATE || cf logs MyApp-CANDIDATE --recent", p[1].String())
@@ -145,7 +219,8 @@ func TestDocker(t *testing.T) {
 			r := request
 			r.Params.DockerUsername = "asd"
 
-			p := NewPushPlan().Plan(applicationManifest, r)
+			p, err := NewPushPlan(false, Inherited{}).Plan(applicationManifest, r)
+			require.NoError(t, err)
 			assert.Len(t, p, 3)
 			assert.Equal(t, "CF_DOCKER_PASSWORD=... cf push MyApp-CANDIDATE -f path/to/manifest.yml --docker-image wheep/whuup --docker-username asd --no-route --no-start", p[0].String())
 			assert.Equal(t, "cf map-route MyApp-CANDIDATE kehe.com -n MyApp-c-CANDIDATE", p[1].String())
@@ -163,7 +238,8 @@ func TestDocker(t *testing.T) {
 			r := request
 			r.Params.DockerUsername = "asd"
 
-			p := NewPushPlan().Plan(applicationManifest, r)
+			p, err := NewPushPlan(false, Inherited{}).Plan(applicationManifest, r)
+			require.NoError(t, err)
 			assert.Len(t, p, 3)
 			assert.Equal(t, fmt.Sprintf("CF_DOCKER_PASSWORD=... cf push MyApp-CANDIDATE -f path/to/manifest.yml --docker-image wheep/whuup:%s --docker-username asd --no-route --no-start", dockerTag), p[0].String())
 			assert.Equal(t, "cf map-route MyApp-CANDIDATE kehe.com -n MyApp-c-CANDIDATE", p[1].String())
@@ -182,7 +258,8 @@ func TestDocker(t *testing.T) {
 			r.Params.DockerUsername = "asd"
 			r.Metadata.DockerTag = dockerTag
 
-			p := NewPushPlan().Plan(applicationManifest, r)
+			p, err := NewPushPlan(false, Inherited{}).Plan(applicationManifest, r)
+			require.NoError(t, err)
 			assert.Len(t, p, 3)
 			assert.Equal(t, fmt.Sprintf("CF_DOCKER_PASSWORD=... cf push MyApp-CANDIDATE -f path/to/manifest.yml --docker-image wheep/whuup:%s --docker-username asd --no-route --no-start", dockerTag), p[0].String())
 			assert.Equal(t, "cf map-route MyApp-CANDIDATE kehe.com -n MyApp-c-CANDIDATE", p[1].String())
@@ -201,7 +278,8 @@ func TestDocker(t *testing.T) {
 			r.Params.DockerUsername = "asd"
 			r.Metadata.DockerTag = dockerTag
 
-			p := NewPushPlan().Plan(applicationManifest, r)
+			p, err := NewPushPlan(false, Inherited{}).Plan(applicationManifest, r)
+			require.NoError(t, err)
 			assert.Len(t, p, 3)
 			assert.Equal(t, fmt.Sprintf("CF_DOCKER_PASSWORD=... cf push MyApp-CANDIDATE -f path/to/manifest.yml --docker-image wheep/whuup:%s --docker-username asd --no-route --no-start", dockerTag), p[0].String())
 			assert.Equal(t, "cf map-route MyApp-CANDIDATE kehe.com -n MyApp-c-CANDIDATE", p[1].String())
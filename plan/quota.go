@@ -0,0 +1,314 @@
+package plan
+
+import (
+	"code.cloudfoundry.org/cli/util/manifestparser"
+	"context"
+	"errors"
+	"fmt"
+	cfclient "github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/springernature/halfpipe-deploy-resource/logger"
+	"strings"
+)
+
+// defaultMemoryInMB is what CF gives a process that doesn't specify memory in the manifest.
+const defaultMemoryInMB = 1024
+
+type QuotaPlan interface {
+	Plan(manifest manifestparser.Application, request config.Request) (pl Plan)
+}
+
+type quotaPlan struct {
+	inherited Inherited
+}
+
+// quotaUsage is the part of the usage summary that a push of the candidate affects.
+type quotaUsage struct {
+	MemoryInMB       int
+	Instances        int
+	Routes           int
+	ServiceInstances int
+}
+
+func (u quotaUsage) add(other quotaUsage) quotaUsage {
+	return quotaUsage{
+		MemoryInMB:       u.MemoryInMB + other.MemoryInMB,
+		Instances:        u.Instances + other.Instances,
+		Routes:           u.Routes + other.Routes,
+		ServiceInstances: u.ServiceInstances + other.ServiceInstances,
+	}
+}
+
+func (u quotaUsage) subtract(other quotaUsage) quotaUsage {
+	return quotaUsage{
+		MemoryInMB:       u.MemoryInMB - other.MemoryInMB,
+		Instances:        u.Instances - other.Instances,
+		Routes:           u.Routes - other.Routes,
+		ServiceInstances: u.ServiceInstances - other.ServiceInstances,
+	}
+}
+
+// quotaLimits is an org or space quota, nil limits are unlimited.
+type quotaLimits struct {
+	kind     string
+	name     string
+	apps     resource.AppsQuota
+	routes   resource.RoutesQuota
+	services resource.ServicesQuota
+	// consumer is what the needed usage is for in the report, the candidate unless set.
+	consumer string
+}
+
+func (p quotaPlan) Plan(manifest manifestparser.Application, request config.Request) (pl Plan) {
+	desc := fmt.Sprintf("Checking that '%s' fits in the org and space quota", createCandidateAppName(manifest.Name))
+	pl = append(pl, NewClientCommand(p.createFunc(manifest, request), desc))
+	return
+}
+
+func (p quotaPlan) createFunc(manifest manifestparser.Application, request config.Request) func(*cfclient.Client, *logger.CapturingWriter) error {
+	return func(cfClient *cfclient.Client, logger *logger.CapturingWriter) error {
+		ctx := context.Background()
+		org, space, err := getOrgAndSpace(ctx, cfClient, request.Source.Org, request.Source.Space)
+		if err != nil {
+			return err
+		}
+
+		needed, largestProcess, err := p.candidateNeeds(manifest, request)
+		if err != nil {
+			return err
+		}
+
+		extra, err := p.newRoutesAndServices(ctx, cfClient, manifest, request, space.GUID)
+		if err != nil {
+			return err
+		}
+		needed = needed.add(extra)
+
+		freed, err := p.freedByExistingCandidate(ctx, cfClient, manifest, space.GUID)
+		if err != nil {
+			return err
+		}
+
+		logger.Println(fmt.Sprintf("Candidate needs %dMB memory over %d instances, %d new routes and %d new service instances", needed.MemoryInMB, needed.Instances, needed.Routes, needed.ServiceInstances))
+		logger.Println(fmt.Sprintf("The live app '%s' keeps running while the candidate is started", manifest.Name))
+
+		var exceeded []string
+
+		orgQuota, err := p.orgQuota(ctx, cfClient, org)
+		if err != nil {
+			return err
+		}
+		orgSummary, err := cfClient.Organizations.GetUsageSummary(ctx, org.GUID)
+		if err != nil {
+			return err
+		}
+		orgLines, orgExceeded := orgQuota.check(toQuotaUsage(orgSummary.UsageSummary).subtract(freed), needed, largestProcess)
+		logger.Println(strings.Join(orgLines, "\n"))
+		exceeded = append(exceeded, orgExceeded...)
+
+		spaceQuota, err := p.spaceQuota(ctx, cfClient, space)
+		if err != nil {
+			return err
+		}
+		if spaceQuota != nil {
+			spaceSummary, err := cfClient.Spaces.GetUsageSummary(ctx, space.GUID)
+			if err != nil {
+				return err
+			}
+			spaceLines, spaceExceeded := spaceQuota.check(toQuotaUsage(spaceSummary.UsageSummary).subtract(freed), needed, largestProcess)
+			logger.Println(strings.Join(spaceLines, "\n"))
+			exceeded = append(exceeded, spaceExceeded...)
+		}
+
+		if len(exceeded) > 0 {
+			return errors.New(fmt.Sprintf("'%s' does not fit in the quota:\n%s", createCandidateAppName(manifest.Name), strings.Join(exceeded, "\n")))
+		}
+
+		logger.Println("OK")
+		return nil
+	}
+}
+
+// candidateNeeds sums up the memory and instances of all the processes in the manifest.
+// The top level memory and instances belong to the web process, just like in cf push.
+// When the scale is inherited the processes that also exist in the live app get its instances and memory instead.
+func (p quotaPlan) candidateNeeds(manifest manifestparser.Application, request config.Request) (needed quotaUsage, largestProcess int, err error) {
+	web := manifestparser.Process{Type: "web", Memory: manifest.Memory, Instances: manifest.Instances}
+	processes := []manifestparser.Process{web}
+	for _, process := range manifest.Processes {
+		if process.Type == "web" {
+			if process.Memory != "" {
+				processes[0].Memory = process.Memory
+			}
+			if process.Instances != nil {
+				processes[0].Instances = process.Instances
+			}
+			continue
+		}
+		processes = append(processes, process)
+	}
+
+	for _, process := range processes {
+		instances := 1
+		if process.Instances != nil {
+			instances = *process.Instances
+		}
+		if process.Type == "web" && request.Params.Instances != 0 {
+			instances = request.Params.Instances
+		}
+
+		memory := defaultMemoryInMB
+		if process.Memory != "" {
+			if memory, err = config.ParseMegabytes(process.Memory); err != nil {
+				return
+			}
+		}
+
+		if request.Params.Inherit.Scale && p.inherited.Live {
+			for _, liveProcess := range p.inherited.Processes {
+				if liveProcess.Type == process.Type {
+					instances, memory = liveProcess.Instances, liveProcess.MemoryInMB
+				}
+			}
+		}
+
+		needed.Instances += instances
+		needed.MemoryInMB += instances * memory
+		if memory > largestProcess {
+			largestProcess = memory
+		}
+	}
+	return
+}
+
+// newRoutesAndServices counts the candidate route and the managed service instances from the request
+// that do not exist yet. User-provided service instances do not count towards the quota.
+func (p quotaPlan) newRoutesAndServices(ctx context.Context, cfClient *cfclient.Client, manifest manifestparser.Application, request config.Request, spaceGUID string) (needed quotaUsage, err error) {
+	if !manifest.NoRoute {
+		domainOpts := cfclient.NewDomainListOptions()
+		domainOpts.Names = cfclient.Filter{Values: []string{request.Params.TestDomain}}
+		domains, err := cfClient.Domains.ListAll(ctx, domainOpts)
+		if err != nil {
+			return needed, err
+		}
+
+		needed.Routes = 1
+		if len(domains) > 0 {
+			routeOpts := cfclient.NewRouteListOptions()
+			routeOpts.SpaceGUIDs = cfclient.Filter{Values: []string{spaceGUID}}
+			routeOpts.DomainGUIDs = cfclient.Filter{Values: []string{domains[0].GUID}}
+			routeOpts.Hosts = cfclient.Filter{Values: []string{createCandidateHostname(manifest, request)}}
+			routes, err := cfClient.Routes.ListAll(ctx, routeOpts)
+			if err != nil {
+				return needed, err
+			}
+			if len(routes) > 0 {
+				needed.Routes = 0
+			}
+		}
+	}
+
+	for _, service := range request.Params.Services {
+		if service.IsUserProvided() {
+			continue
+		}
+		opts := cfclient.NewServiceInstanceListOptions()
+		opts.Names = cfclient.Filter{Values: []string{service.Name}}
+		opts.SpaceGUIDs = cfclient.Filter{Values: []string{spaceGUID}}
+		existing, err := cfClient.ServiceInstances.ListAll(ctx, opts)
+		if err != nil {
+			return needed, err
+		}
+		if len(existing) == 0 {
+			needed.ServiceInstances++
+		}
+	}
+	return
+}
+
+// freedByExistingCandidate is the usage of a running candidate from an earlier push, as pushing stops it.
+func (p quotaPlan) freedByExistingCandidate(ctx context.Context, cfClient *cfclient.Client, manifest manifestparser.Application, spaceGUID string) (freed quotaUsage, err error) {
+	appOpts := cfclient.NewAppListOptions()
+	appOpts.Names = cfclient.Filter{Values: []string{createCandidateAppName(manifest.Name)}}
+	appOpts.SpaceGUIDs = cfclient.Filter{Values: []string{spaceGUID}}
+	apps, err := cfClient.Applications.ListAll(ctx, appOpts)
+	if err != nil || len(apps) == 0 || apps[0].State != "STARTED" {
+		return
+	}
+
+	processes, err := cfClient.Processes.ListForAppAll(ctx, apps[0].GUID, nil)
+	if err != nil {
+		return
+	}
+	for _, process := range processes {
+		freed.Instances += process.Instances
+		freed.MemoryInMB += process.Instances * process.MemoryInMB
+	}
+	return
+}
+
+func (p quotaPlan) orgQuota(ctx context.Context, cfClient *cfclient.Client, org *resource.Organization) (quotaLimits, error) {
+	if org.Relationships.Quota.Data == nil {
+		return quotaLimits{kind: "org", name: org.Name}, nil
+	}
+	quota, err := cfClient.OrganizationQuotas.Get(ctx, org.Relationships.Quota.Data.GUID)
+	if err != nil {
+		return quotaLimits{}, err
+	}
+	return quotaLimits{kind: "org", name: quota.Name, apps: quota.Apps, routes: quota.Routes, services: quota.Services}, nil
+}
+
+func (p quotaPlan) spaceQuota(ctx context.Context, cfClient *cfclient.Client, space *resource.Space) (*quotaLimits, error) {
+	if space.Relationships == nil || space.Relationships.Quota == nil || space.Relationships.Quota.Data == nil {
+		return nil, nil
+	}
+	quota, err := cfClient.SpaceQuotas.Get(ctx, space.Relationships.Quota.Data.GUID)
+	if err != nil {
+		return nil, err
+	}
+	return &quotaLimits{kind: "space", name: quota.Name, apps: quota.Apps, routes: quota.Routes, services: quota.Services}, nil
+}
+
+func toQuotaUsage(summary resource.UsageSummary) quotaUsage {
+	return quotaUsage{
+		MemoryInMB:       summary.MemoryInMb,
+		Instances:        summary.StartedInstances,
+		Routes:           summary.Routes,
+		ServiceInstances: summary.ServiceInstances,
+	}
+}
+
+// check returns a line per limit in the quota, and the lines for the limits the candidate would exceed.
+func (q quotaLimits) check(inUse quotaUsage, needed quotaUsage, largestProcess int) (lines []string, exceeded []string) {
+	consumer := q.consumer
+	if consumer == "" {
+		consumer = "candidate"
+	}
+
+	checkLimit := func(what string, limit *int, used int, need int, unit string) {
+		if limit == nil || *limit < 0 {
+			lines = append(lines, fmt.Sprintf("%s quota '%s' %s: %d%s in use, %s needs %d%s, unlimited", q.kind, q.name, what, used, unit, consumer, need, unit))
+			return
+		}
+		line := fmt.Sprintf("%s quota '%s' %s: %d%s in use, %s needs %d%s, limit is %d%s", q.kind, q.name, what, used, unit, consumer, need, unit, *limit, unit)
+		lines = append(lines, line)
+		if need > 0 && used+need > *limit {
+			exceeded = append(exceeded, line)
+		}
+	}
+
+	checkLimit("memory", q.apps.TotalMemoryInMB, inUse.MemoryInMB, needed.MemoryInMB, "MB")
+	checkLimit("instances", q.apps.TotalInstances, inUse.Instances, needed.Instances, "")
+	checkLimit("routes", q.routes.TotalRoutes, inUse.Routes, needed.Routes, "")
+	checkLimit("service instances", q.services.TotalServiceInstances, inUse.ServiceInstances, needed.ServiceInstances, "")
+
+	if limit := q.apps.PerProcessMemoryInMB; limit != nil && *limit >= 0 && largestProcess > *limit {
+		exceeded = append(exceeded, fmt.Sprintf("%s quota '%s' memory per process: %s needs %dMB, limit is %dMB", q.kind, q.name, consumer, largestProcess, *limit))
+	}
+	return
+}
+
+func NewQuotaPlan(inherited Inherited) QuotaPlan {
+	return quotaPlan{inherited: inherited}
+}
@@ -0,0 +1,160 @@
+package plan
+
+import (
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	halfpipe_deploy_resource "github.com/springernature/halfpipe-deploy-resource"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestQuotaPlan(t *testing.T) {
+	man := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp`).Applications[0]
+
+	p := NewQuotaPlan(Inherited{}).Plan(man, validRequest)
+
+	assert.Len(t, p, 1)
+	assert.Equal(t, "Checking that 'myApp-CANDIDATE' fits in the org and space quota", p[0].String())
+}
+
+func TestQuotaCandidateNeeds(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		man := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp`).Applications[0]
+
+		needed, largest, err := quotaPlan{}.candidateNeeds(man, validRequest)
+
+		assert.NoError(t, err)
+		assert.Equal(t, quotaUsage{MemoryInMB: 1024, Instances: 1}, needed)
+		assert.Equal(t, 1024, largest)
+	})
+
+	t.Run("web and worker processes", func(t *testing.T) {
+		man := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp
+  memory: 512M
+  instances: 3
+  processes:
+  - type: worker
+    memory: 2G
+    instances: 2`).Applications[0]
+
+		needed, largest, err := quotaPlan{}.candidateNeeds(man, validRequest)
+
+		assert.NoError(t, err)
+		assert.Equal(t, quotaUsage{MemoryInMB: 3*512 + 2*2048, Instances: 5}, needed)
+		assert.Equal(t, 2048, largest)
+	})
+
+	t.Run("instances from params override the web process", func(t *testing.T) {
+		man := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp
+  memory: 256MB
+  instances: 3`).Applications[0]
+
+		r := validRequest
+		r.Params.Instances = 5
+
+		needed, _, err := quotaPlan{}.candidateNeeds(man, r)
+
+		assert.NoError(t, err)
+		assert.Equal(t, quotaUsage{MemoryInMB: 5 * 256, Instances: 5}, needed)
+	})
+
+	t.Run("inherited scale replaces the processes that exist in the live app", func(t *testing.T) {
+		man := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp
+  memory: 512M
+  instances: 1
+  processes:
+  - type: worker
+    memory: 256M
+    instances: 1
+  - type: scheduler
+    memory: 128M
+    instances: 1`).Applications[0]
+
+		r := validRequest
+		r.Params.Inherit.Scale = true
+
+		p := quotaPlan{inherited: Inherited{Live: true, Processes: []*resource.Process{
+			{Type: "web", Instances: 4, MemoryInMB: 2048},
+			{Type: "worker", Instances: 2, MemoryInMB: 1024},
+		}}}
+		needed, largest, err := p.candidateNeeds(man, r)
+
+		assert.NoError(t, err)
+		assert.Equal(t, quotaUsage{MemoryInMB: 4*2048 + 2*1024 + 128, Instances: 7}, needed)
+		assert.Equal(t, 2048, largest)
+	})
+
+	t.Run("inherited scale without a live app uses the manifest", func(t *testing.T) {
+		man := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp
+  memory: 512M
+  instances: 2`).Applications[0]
+
+		r := validRequest
+		r.Params.Inherit.Scale = true
+
+		needed, _, err := quotaPlan{inherited: Inherited{}}.candidateNeeds(man, r)
+
+		assert.NoError(t, err)
+		assert.Equal(t, quotaUsage{MemoryInMB: 2 * 512, Instances: 2}, needed)
+	})
+
+	t.Run("invalid memory", func(t *testing.T) {
+		man := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp
+  memory: lots`).Applications[0]
+
+		_, _, err := quotaPlan{}.candidateNeeds(man, validRequest)
+
+		assert.Error(t, err)
+	})
+}
+
+func TestQuotaCheck(t *testing.T) {
+	quota := quotaLimits{
+		kind: "org",
+		name: "small",
+		apps: resource.AppsQuota{
+			TotalMemoryInMB:      intPtr(4096),
+			PerProcessMemoryInMB: intPtr(1024),
+			TotalInstances:       intPtr(10),
+		},
+		routes: resource.RoutesQuota{TotalRoutes: intPtr(5)},
+	}
+
+	t.Run("fits", func(t *testing.T) {
+		lines, exceeded := quota.check(quotaUsage{MemoryInMB: 2048, Instances: 2, Routes: 4}, quotaUsage{MemoryInMB: 2048, Instances: 2, Routes: 1}, 1024)
+
+		assert.Empty(t, exceeded)
+		assert.Equal(t, []string{
+			"org quota 'small' memory: 2048MB in use, candidate needs 2048MB, limit is 4096MB",
+			"org quota 'small' instances: 2 in use, candidate needs 2, limit is 10",
+			"org quota 'small' routes: 4 in use, candidate needs 1, limit is 5",
+			"org quota 'small' service instances: 0 in use, candidate needs 0, unlimited",
+		}, lines)
+	})
+
+	t.Run("does not fit", func(t *testing.T) {
+		_, exceeded := quota.check(quotaUsage{MemoryInMB: 3072, Instances: 2, Routes: 5}, quotaUsage{MemoryInMB: 2048, Instances: 1, Routes: 1}, 2048)
+
+		assert.Equal(t, []string{
+			"org quota 'small' memory: 3072MB in use, candidate needs 2048MB, limit is 4096MB",
+			"org quota 'small' routes: 5 in use, candidate needs 1, limit is 5",
+			"org quota 'small' memory per process: candidate needs 2048MB, limit is 1024MB",
+		}, exceeded)
+	})
+
+	t.Run("already over a limit the candidate does not need", func(t *testing.T) {
+		_, exceeded := quota.check(quotaUsage{MemoryInMB: 1024, Routes: 6}, quotaUsage{MemoryInMB: 1024, Instances: 1}, 1024)
+
+		assert.Empty(t, exceeded)
+	})
+}
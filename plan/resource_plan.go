@@ -26,9 +26,22 @@ type planner struct {
 	logsPlan            LogsPlan
 	appLintPlan         AppLintPlan
 	ssoPlan             SSOPlan
+	routeCheckPlan      RouteCheckPlan
+	quotaPlan           QuotaPlan
+	servicesPlan        ServicesPlan
+	provenancePlan      ProvenancePlan
+	runTaskPlan         RunTaskPlan
+	dynamicCleanupPlan  DynamicCleanupPlan
+	routeCleanupPlan    RouteCleanupPlan
+	statusPlan          StatusPlan
+	restartPlan         RestartPlan
+	restagePlan         RestartPlan
+	scalePlan           ScalePlan
+	setEnvPlan          SetEnvPlan
+	lintPlan            LintPlan
 }
 
-func NewPlanner(manifestReaderWrite manifest.ReaderWriter, pushPlan PushPlan, checkPlan CheckPlan, promotePlan PromotePlan, cleanupPlan CleanupPlan, rollingDeployPlan RollingDeployPlan, deleteCandidatePlan DeleteCandidatePlan, stopCandidatePlan StopCandidatePlan, logsPlan LogsPlan, appLintPlan AppLintPlan, ssoPlan SSOPlan) ResourcePlan {
+func NewPlanner(manifestReaderWrite manifest.ReaderWriter, pushPlan PushPlan, checkPlan CheckPlan, promotePlan PromotePlan, cleanupPlan CleanupPlan, rollingDeployPlan RollingDeployPlan, deleteCandidatePlan DeleteCandidatePlan, stopCandidatePlan StopCandidatePlan, logsPlan LogsPlan, appLintPlan AppLintPlan, ssoPlan SSOPlan, routeCheckPlan RouteCheckPlan, quotaPlan QuotaPlan, servicesPlan ServicesPlan, provenancePlan ProvenancePlan, runTaskPlan RunTaskPlan, dynamicCleanupPlan DynamicCleanupPlan, routeCleanupPlan RouteCleanupPlan, statusPlan StatusPlan, restartPlan RestartPlan, restagePlan RestartPlan, scalePlan ScalePlan, setEnvPlan SetEnvPlan, lintPlan LintPlan) ResourcePlan {
 	return planner{
 		manifestReaderWrite: manifestReaderWrite,
 		pushPlan:            pushPlan,
@@ -41,6 +54,19 @@ func NewPlanner(manifestReaderWrite manifest.ReaderWriter, pushPlan PushPlan, ch
 		logsPlan:            logsPlan,
 		appLintPlan:         appLintPlan,
 		ssoPlan:             ssoPlan,
+		routeCheckPlan:      routeCheckPlan,
+		quotaPlan:           quotaPlan,
+		servicesPlan:        servicesPlan,
+		provenancePlan:      provenancePlan,
+		runTaskPlan:         runTaskPlan,
+		dynamicCleanupPlan:  dynamicCleanupPlan,
+		routeCleanupPlan:    routeCleanupPlan,
+		statusPlan:          statusPlan,
+		restartPlan:         restartPlan,
+		restagePlan:         restagePlan,
+		scalePlan:           scalePlan,
+		setEnvPlan:          setEnvPlan,
+		lintPlan:            lintPlan,
 	}
 }
 
@@ -66,33 +92,85 @@ func (p planner) Plan(request config.Request, appsSummary []*resource.App) (pl P
 
 	switch request.Params.Command {
 	case config.PUSH, config.ROLLING_DEPLOY:
+		if err = verifyRouteProtocols(appUnderDeployment, request.Params.CliVersion); err != nil {
+			return
+		}
 		if err = p.updateManifestWithVarsAndLabels(request); err != nil {
 			return
 		}
-		pl = append(pl, p.appLintPlan.Plan(appUnderDeployment, request.Source.Org, request.Source.Space)...)
+		pl = append(pl, p.appLintPlan.Plan(appUnderDeployment, request)...)
+		pl = append(pl, p.routeCheckPlan.Plan(appUnderDeployment, request)...)
+		if request.Params.Command == config.PUSH {
+			pl = append(pl, p.quotaPlan.Plan(appUnderDeployment, request)...)
+		}
+		pl = append(pl, p.servicesPlan.Plan(request.Params.Services, request.Source.Org, request.Source.Space)...)
 
 		switch request.Params.Command {
 		case config.PUSH:
-			pl = append(pl, p.pushPlan.Plan(appUnderDeployment, request)...)
+			var push Plan
+			if push, err = p.pushPlan.Plan(appUnderDeployment, request); err != nil {
+				return
+			}
+			pl = append(pl, push...)
+			pl = append(pl, p.provenancePlan.Plan(createCandidateAppName(appUnderDeployment.Name), request)...)
 		case config.ROLLING_DEPLOY:
 			pl = append(pl, p.rollingDeployPlan.Plan(appUnderDeployment, request)...)
+			pl = append(pl, p.provenancePlan.Plan(appUnderDeployment.Name, request)...)
 		}
 	case config.ALL:
+		if err = verifyRouteProtocols(appUnderDeployment, request.Params.CliVersion); err != nil {
+			return
+		}
 		if err = p.updateManifestWithVarsAndLabels(request); err != nil {
 			return
 		}
-		pl = append(pl, p.appLintPlan.Plan(appUnderDeployment, request.Source.Org, request.Source.Space)...)
-		pl = append(pl, p.pushPlan.Plan(appUnderDeployment, request)...)
+		pl = append(pl, p.appLintPlan.Plan(appUnderDeployment, request)...)
+		pl = append(pl, p.routeCheckPlan.Plan(appUnderDeployment, request)...)
+		pl = append(pl, p.quotaPlan.Plan(appUnderDeployment, request)...)
+		pl = append(pl, p.servicesPlan.Plan(request.Params.Services, request.Source.Org, request.Source.Space)...)
+		var push Plan
+		if push, err = p.pushPlan.Plan(appUnderDeployment, request); err != nil {
+			return
+		}
+		pl = append(pl, push...)
+		pl = append(pl, p.provenancePlan.Plan(createCandidateAppName(appUnderDeployment.Name), request)...)
 		pl = append(pl, p.checkPlan.Plan(appUnderDeployment, request.Source.Org, request.Source.Space)...)
-		pl = append(pl, p.promotePlan.Plan(appUnderDeployment, request, appsSummary)...)
-		pl = append(pl, NewDynamicCleanupPlan().Plan(appUnderDeployment, request.Source.Org, request.Source.Space)...)
+		if !request.Params.Task.IsEmpty() {
+			pl = append(pl, p.runTaskPlan.Plan(appUnderDeployment, request)...)
+		}
+		pl = append(pl, p.promotePlan.Plan(appUnderDeployment, request)...)
+		pl = append(pl, p.dynamicCleanupPlan.Plan(appUnderDeployment, request.Params.Retention(), request.Source.Org, request.Source.Space)...)
+		pl = append(pl, p.routeCleanupPlan.Plan(appUnderDeployment, request)...)
 	case config.CHECK:
 		// We dont actually need to login for this as we are using a cf client for this specific task..
 		pl = p.checkPlan.Plan(appUnderDeployment, request.Source.Org, request.Source.Space)
+	case config.RUN_TASK:
+		pl = append(pl, p.runTaskPlan.Plan(appUnderDeployment, request)...)
+	case config.STATUS:
+		// Like check, status and the commands that change the live app in place only use the cf client.
+		pl = p.statusPlan.Plan(appUnderDeployment, request)
+	case config.RESTART:
+		pl = p.restartPlan.Plan(appUnderDeployment, request)
+	case config.RESTAGE:
+		pl = p.restagePlan.Plan(appUnderDeployment, request)
+	case config.SCALE:
+		pl = p.scalePlan.Plan(appUnderDeployment, request)
+	case config.SET_ENV:
+		pl = p.setEnvPlan.Plan(appUnderDeployment, request)
+	case config.LINT:
+		// Lint runs without CF, so there is nothing to login to.
+		pl = p.lintPlan.Plan(appUnderDeployment, request)
 	case config.PROMOTE:
-		pl = append(pl, p.promotePlan.Plan(appUnderDeployment, request, appsSummary)...)
+		if err = verifyRouteProtocols(appUnderDeployment, request.Params.CliVersion); err != nil {
+			return
+		}
+		pl = append(pl, p.routeCheckPlan.Plan(appUnderDeployment, request)...)
+		pl = append(pl, p.promotePlan.Plan(appUnderDeployment, request)...)
 	case config.CLEANUP, config.DELETE:
-		pl = append(pl, p.cleanupPlan.Plan(appUnderDeployment, appsSummary)...)
+		pl = append(pl, p.cleanupPlan.Plan(appUnderDeployment, request.Params.Retention(), appsSummary)...)
+		if request.Params.Command == config.CLEANUP {
+			pl = append(pl, p.routeCleanupPlan.Plan(appUnderDeployment, request)...)
+		}
 	case config.DELETE_CANDIDATE:
 		pl = append(pl, p.deleteCandidatePlan.Plan(appUnderDeployment, appsSummary)...)
 	case config.STOP_CANDIDATE:
@@ -100,7 +178,7 @@ func (p planner) Plan(request config.Request, appsSummary []*resource.App) (pl P
 	case config.LOGS:
 		pl = append(pl, p.logsPlan.Plan(appUnderDeployment)...)
 	case config.SSO:
-		pl = append(pl, p.ssoPlan.Plan(request.Params.SSOHost)...)
+		pl = append(pl, p.ssoPlan.Plan(request)...)
 	}
 
 	return
@@ -11,6 +11,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/springernature/halfpipe-deploy-resource/manifest"
 )
 
 var validRequest = config.Request{
@@ -71,7 +72,7 @@ func TestErrorsReadingAppManifest(t *testing.T) {
 	expectedErr := errors.New("blurgh")
 	manifestReader := ManifestReadWriteStub{manifestReadError: expectedErr}
 
-	planner := NewPlanner(&manifestReader, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	planner := newPlanner(&manifestReader, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 
 	_, err := planner.Plan(validRequest, nil)
 	assert.Equal(t, expectedErr, err)
@@ -90,7 +91,7 @@ func TestErrorsWhenSavingManifestWithUpdatedVars(t *testing.T) {
 	fs.WriteFile(validRequest.Params.GitRefPath, []byte(""), 0777)
 	fs.WriteFile(validRequest.Params.BuildVersionPath, []byte(""), 0777)
 
-	planner := NewPlanner(&manifestReader, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	planner := newPlanner(&manifestReader, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 
 	_, err := planner.Plan(validRequest, nil)
 	assert.Equal(t, expectedErr, err)
@@ -98,6 +99,13 @@ func TestErrorsWhenSavingManifestWithUpdatedVars(t *testing.T) {
 	assert.Equal(t, validRequest.Params.ManifestPath, manifestReader.writePath)
 }
 
+// newPlanner is NewPlanner with the real plans of the commands that the tests don't fake.
+func newPlanner(manifestReaderWrite manifest.ReaderWriter, pushPlan PushPlan, checkPlan CheckPlan, promotePlan PromotePlan, cleanupPlan CleanupPlan, rollingDeployPlan RollingDeployPlan, deleteCandidatePlan DeleteCandidatePlan, stopCandidatePlan StopCandidatePlan, logsPlan LogsPlan, appLintPlan AppLintPlan, ssoPlan SSOPlan) ResourcePlan {
+	return NewPlanner(manifestReaderWrite, pushPlan, checkPlan, promotePlan, cleanupPlan, rollingDeployPlan, deleteCandidatePlan, stopCandidatePlan, logsPlan, appLintPlan, ssoPlan,
+		NewRouteCheckPlan(), NewQuotaPlan(Inherited{}), NewServicesPlan(), NewProvenancePlan(), NewRunTaskPlan(), NewDynamicCleanupPlan(), NewRouteCleanupPlan(),
+		NewStatusPlan(), NewRestartPlan(), NewRestagePlan(), NewScalePlan(), NewSetEnvPlan(), NewLintPlan())
+}
+
 type fakePushPlanner struct {
 	plan      Plan
 	dockerTag string
@@ -128,15 +136,23 @@ type fakeStopCandidatePlanner struct {
 	plan Plan
 }
 
+type fakeStatusPlanner struct {
+	plan Plan
+}
+
+func (f fakeStatusPlanner) Plan(manifest manifestparser.Application, request config.Request) (pl Plan) {
+	return f.plan
+}
+
 func (f fakeCheckPlanner) Plan(manifest manifestparser.Application, org, space string) (pl Plan) {
 	return f.plan
 }
 
-func (f fakePromotePlanner) Plan(manifest manifestparser.Application, request config.Request, summary []*resource.App) (pl Plan) {
+func (f fakePromotePlanner) Plan(manifest manifestparser.Application, request config.Request) (pl Plan) {
 	return f.plan
 }
 
-func (f fakeCleanupPlanner) Plan(manifest manifestparser.Application, summary []*resource.App) (pl Plan) {
+func (f fakeCleanupPlanner) Plan(manifest manifestparser.Application, retention config.Retention, summary []*resource.App) (pl Plan) {
 	return f.plan
 }
 
@@ -148,14 +164,35 @@ func (f fakeStopCandidatePlanner) Plan(manifest manifestparser.Application, summ
 	return f.plan
 }
 
-func (f *fakePushPlanner) Plan(manifest manifestparser.Application, request config.Request) (pl Plan) {
-	return f.plan
+func (f *fakePushPlanner) Plan(manifest manifestparser.Application, request config.Request) (pl Plan, err error) {
+	return f.plan, nil
 }
 
 func (f *fakeRollingDeployPlanner) Plan(manifest manifestparser.Application, request config.Request) (pl Plan) {
 	return f.plan
 }
 
+func TestErrorsMappingRoutesWithAProtocolWithCf6(t *testing.T) {
+	manifestReader := ManifestReadWriteStub{
+		manifest: halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp
+  routes:
+  - route: myApp.example.com
+    protocol: http2`),
+	}
+
+	planner := newPlanner(&manifestReader, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	for _, command := range []string{config.PUSH, config.ROLLING_DEPLOY, config.PROMOTE, config.ALL} {
+		r := validRequest
+		r.Params.Command = command
+		r.Params.CliVersion = "cf6"
+
+		_, err := planner.Plan(r, nil)
+		assert.Equal(t, config.ParamsInvalidError("cliVersion", "must be 'cf7' or 'cf8' as route 'myApp.example.com' has protocol 'http2', which cf6 cannot map"), err, command)
+	}
+}
+
 func TestCallsOutToCorrectPlanner(t *testing.T) {
 	t.Run("Push planner", func(tt *testing.T) {
 		tt.Run("Pushes", func(ttt *testing.T) {
@@ -188,7 +225,7 @@ func TestCallsOutToCorrectPlanner(t *testing.T) {
 - name: myApp`),
 			}
 
-			planner := NewPlanner(&manifestReader, &fakePushPlanner{
+			planner := newPlanner(&manifestReader, &fakePushPlanner{
 				plan: Plan{
 					NewCfCommand("yay"),
 				},
@@ -201,16 +238,42 @@ func TestCallsOutToCorrectPlanner(t *testing.T) {
 
 			assert.NoError(ttt, err)
 
-			assert.Len(ttt, p, 4)
+			assert.Len(ttt, p, 6)
 			assert.Equal(ttt, "cf --version", p[0].String())
 			assert.Equal(ttt, "cf login -a a -u d -p ******** -o b -s c", p[1].String())
 			assert.Equal(ttt, "Linting application", p[2].String())
-			assert.Equal(ttt, "cf yay", p[3].String())
+			assert.Equal(ttt, "Checking that 'myApp-CANDIDATE' fits in the org and space quota", p[3].String())
+			assert.Equal(ttt, "cf yay", p[4].String())
+			assert.Equal(ttt, "Recording where 'myApp-CANDIDATE' comes from in the annotations of the app and its droplet", p[5].String())
 			assert.Equal(ttt, validRequest.Params.ManifestPath, manifestReader.readPath)
 			assert.Equal(ttt, validRequest.Params.ManifestPath, manifestReader.writePath)
 			assert.Equal(ttt, expectedManifest, manifestReader.savedManifest)
 		})
 
+		tt.Run("Ensures services before pushing", func(ttt *testing.T) {
+			manifestReader := ManifestReadWriteStub{
+				manifest: halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp`),
+			}
+
+			planner := newPlanner(&manifestReader, &fakePushPlanner{
+				plan: Plan{
+					NewCfCommand("yay"),
+				},
+			}, nil, nil, nil, nil, nil, nil, nil, NewCheckLabelsPlan(), nil)
+
+			r := validRequest
+			r.Params.Services = []config.Service{{Name: "my-db", Offering: "postgres", Plan: "small"}}
+			p, err := planner.Plan(r, nil)
+
+			assert.NoError(ttt, err)
+			assert.Len(ttt, p, 7)
+			assert.Equal(ttt, "Linting application", p[2].String())
+			assert.Equal(ttt, "Checking that 'myApp-CANDIDATE' fits in the org and space quota", p[3].String())
+			assert.Equal(ttt, "Ensuring managed service instance 'my-db' with offering 'postgres' and plan 'small'", p[4].String())
+			assert.Equal(ttt, "cf yay", p[5].String())
+		})
+
 		tt.Run("Changes team label if present", func(ttt *testing.T) {
 			expectedManifest := halfpipe_deploy_resource.ParseManifest(`applications:
 - name: myApp
@@ -252,7 +315,7 @@ func TestCallsOutToCorrectPlanner(t *testing.T) {
 `),
 			}
 
-			planner := NewPlanner(&manifestReader, &fakePushPlanner{
+			planner := newPlanner(&manifestReader, &fakePushPlanner{
 				plan: Plan{
 					NewCfCommand("yay"),
 				},
@@ -289,7 +352,7 @@ func TestCallsOutToCorrectPlanner(t *testing.T) {
 `),
 			}
 
-			planner := NewPlanner(&manifestReader, &fakePushPlanner{
+			planner := newPlanner(&manifestReader, &fakePushPlanner{
 				plan: Plan{
 					NewCfCommand("yay"),
 				},
@@ -337,7 +400,7 @@ func TestCallsOutToCorrectPlanner(t *testing.T) {
 `),
 			}
 
-			planner := NewPlanner(&manifestReader, &fakePushPlanner{
+			planner := newPlanner(&manifestReader, &fakePushPlanner{
 				plan: Plan{
 					NewCfCommand("yay"),
 				},
@@ -387,7 +450,7 @@ func TestCallsOutToCorrectPlanner(t *testing.T) {
 `),
 			}
 
-			planner := NewPlanner(&manifestReader, &fakePushPlanner{
+			planner := newPlanner(&manifestReader, &fakePushPlanner{
 				plan: Plan{
 					NewCfCommand("yay"),
 				},
@@ -440,7 +503,7 @@ func TestCallsOutToCorrectPlanner(t *testing.T) {
 - name: myApp`),
 		}
 
-		planner := NewPlanner(&manifestReader, nil, nil, nil, nil, &fakeRollingDeployPlanner{
+		planner := newPlanner(&manifestReader, nil, nil, nil, nil, &fakeRollingDeployPlanner{
 			plan: Plan{
 				NewCfCommand("yay"),
 			},
@@ -454,11 +517,12 @@ func TestCallsOutToCorrectPlanner(t *testing.T) {
 
 		assert.NoError(tt, err)
 
-		assert.Len(tt, p, 4)
+		assert.Len(tt, p, 5)
 		assert.Equal(tt, "cf --version", p[0].String())
 		assert.Equal(tt, "cf login -a a -u d -p ******** -o b -s c", p[1].String())
 		assert.Equal(tt, "Linting application", p[2].String())
 		assert.Equal(tt, "cf yay", p[3].String())
+		assert.Equal(tt, "Recording where 'myApp' comes from in the annotations of the app and its droplet", p[4].String())
 		assert.Equal(tt, expectedPath, manifestReader.readPath)
 		assert.Equal(tt, expectedPath, manifestReader.writePath)
 		assert.Equal(tt, expectedManifest, manifestReader.savedManifest)
@@ -470,7 +534,7 @@ func TestCallsOutToCorrectPlanner(t *testing.T) {
 - name: myApp`),
 		}
 
-		planner := NewPlanner(&manifestReader, nil, fakeCheckPlanner{
+		planner := newPlanner(&manifestReader, nil, fakeCheckPlanner{
 			plan: Plan{
 				NewCfCommand("yay"),
 			},
@@ -493,7 +557,7 @@ func TestCallsOutToCorrectPlanner(t *testing.T) {
 - name: myApp`),
 		}
 
-		planner := NewPlanner(&manifestReader, nil, nil, fakePromotePlanner{
+		planner := newPlanner(&manifestReader, nil, nil, fakePromotePlanner{
 			plan: Plan{
 				NewCfCommand("yay"),
 			},
@@ -518,7 +582,7 @@ func TestCallsOutToCorrectPlanner(t *testing.T) {
 - name: myApp`),
 		}
 
-		planner := NewPlanner(&manifestReader, nil, nil, nil, fakeCleanupPlanner{
+		planner := newPlanner(&manifestReader, nil, nil, nil, fakeCleanupPlanner{
 			plan: Plan{
 				NewCfCommand("yay"),
 			},
@@ -532,10 +596,11 @@ func TestCallsOutToCorrectPlanner(t *testing.T) {
 
 			assert.NoError(t, err)
 
-			assert.Len(t, p, 3)
+			assert.Len(t, p, 4)
 			assert.Equal(t, "cf --version", p[0].String())
 			assert.Equal(t, "cf login -a a -u d -p ******** -o b -s c", p[1].String())
 			assert.Equal(t, "cf yay", p[2].String())
+			assert.Equal(t, "Finding unused routes to delete", p[3].String())
 		})
 
 		t.Run("Works with a delete command", func(t *testing.T) {
@@ -559,7 +624,7 @@ func TestCallsOutToCorrectPlanner(t *testing.T) {
 - name: myApp`),
 		}
 
-		planner := NewPlanner(&manifestReader, nil, nil, nil, nil, nil, &fakeDeleteCandidatePlanner{
+		planner := newPlanner(&manifestReader, nil, nil, nil, nil, nil, &fakeDeleteCandidatePlanner{
 			plan: Plan{
 				NewCfCommand("yay"),
 			},
@@ -584,7 +649,7 @@ func TestCallsOutToCorrectPlanner(t *testing.T) {
 - name: myApp`),
 		}
 
-		planner := NewPlanner(&manifestReader, nil, nil, nil, nil, nil, nil, &fakeStopCandidatePlanner{
+		planner := newPlanner(&manifestReader, nil, nil, nil, nil, nil, nil, &fakeStopCandidatePlanner{
 			plan: Plan{
 				NewCfCommand("yay"),
 			},
@@ -609,7 +674,7 @@ func TestCallsOutToCorrectPlanner(t *testing.T) {
 - name: myApp`),
 		}
 
-		planner := NewPlanner(&manifestReader, nil, nil, nil, nil, nil, nil, nil, NewLogsPlan(), nil, nil)
+		planner := newPlanner(&manifestReader, nil, nil, nil, nil, nil, nil, nil, NewLogsPlan(), nil, nil)
 
 		r := validRequest
 		r.Params.Command = config.LOGS
@@ -630,7 +695,7 @@ func TestCallsOutToCorrectPlanner(t *testing.T) {
 - name: myApp`),
 		}
 
-		planner := NewPlanner(&manifestReader, nil, nil, nil, nil, nil, nil, nil, nil, nil, NewSSOPlan())
+		planner := newPlanner(&manifestReader, nil, nil, nil, nil, nil, nil, nil, nil, nil, NewSSOPlan())
 
 		r := validRequest
 		r.Params.Command = config.SSO
@@ -640,11 +705,134 @@ func TestCallsOutToCorrectPlanner(t *testing.T) {
 
 		assert.NoError(t, err)
 
-		assert.Len(t, p, 5)
+		assert.Len(t, p, 4)
 		assert.Equal(t, "cf --version", p[0].String())
 		assert.Equal(t, "cf login -a a -u d -p ******** -o b -s c", p[1].String())
-		assert.Equal(t, "cf service sso || cf create-user-provided-service sso -r https://ee-sso.public.springernature.app", p[2].String())
-		assert.Equal(t, "cf route public.springernature.app -n myHost || cf create-route public.springernature.app -n myHost", p[3].String())
-		assert.Equal(t, "cf bind-route-service public.springernature.app -n myHost sso", p[4].String())
+		assert.Equal(t, "Ensuring route service 'sso' with url 'https://ee-sso.public.springernature.app'", p[2].String())
+		assert.Equal(t, "Binding route service 'sso' to route 'myHost.public.springernature.app'", p[3].String())
+	})
+
+	t.Run("Run task planner", func(t *testing.T) {
+		manifestReader := ManifestReadWriteStub{
+			manifest: halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp`),
+		}
+
+		planner := newPlanner(&manifestReader, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+		r := validRequest
+		r.Params.Command = config.RUN_TASK
+		r.Params.Task = config.Task{Command: "bin/migrate"}
+
+		p, err := planner.Plan(r, nil)
+
+		assert.NoError(t, err)
+
+		assert.Len(t, p, 3)
+		assert.Equal(t, "cf --version", p[0].String())
+		assert.Equal(t, "cf login -a a -u d -p ******** -o b -s c", p[1].String())
+		assert.Equal(t, "Running task 'halfpipe-task' with command 'bin/migrate' on 'myApp-CANDIDATE'", p[2].String())
+	})
+
+	t.Run("Status planner", func(t *testing.T) {
+		manifestReader := ManifestReadWriteStub{
+			manifest: halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp`),
+		}
+
+		expectedPlan := Plan{NewCfCommand("status")}
+		planner := NewPlanner(&manifestReader, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil,
+			nil, nil, nil, nil, nil, nil, nil, fakeStatusPlanner{plan: expectedPlan}, nil, nil, nil, nil, nil)
+
+		r := validRequest
+		r.Params.Command = config.STATUS
+
+		p, err := planner.Plan(r, nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, expectedPlan, p, "status only uses the cf client, so there is no login")
+	})
+
+	t.Run("Lint planner", func(t *testing.T) {
+		manifestReader := ManifestReadWriteStub{
+			manifest: halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp`),
+		}
+
+		planner := newPlanner(&manifestReader, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+		r := validRequest
+		r.Params.Command = config.LINT
+
+		p, err := planner.Plan(r, nil)
+
+		assert.NoError(t, err)
+
+		assert.Len(t, p, 1)
+		assert.Equal(t, "Linting 'myApp' without CF", p[0].String())
+	})
+
+	t.Run("Restart and restage planner", func(t *testing.T) {
+		manifestReader := ManifestReadWriteStub{
+			manifest: halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp`),
+		}
+
+		planner := newPlanner(&manifestReader, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+		r := validRequest
+		r.Params.Command = config.RESTART
+
+		p, err := planner.Plan(r, nil)
+
+		assert.NoError(t, err)
+		assert.Len(t, p, 2)
+		assert.Equal(t, "Restarting 'myApp' with a rolling deployment", p[0].String())
+
+		r.Params.Command = config.RESTAGE
+
+		p, err = planner.Plan(r, nil)
+
+		assert.NoError(t, err)
+		assert.Len(t, p, 2)
+		assert.Equal(t, "Restaging 'myApp' and deploying the new droplet with a rolling deployment", p[0].String())
+	})
+
+	t.Run("Scale planner", func(t *testing.T) {
+		manifestReader := ManifestReadWriteStub{
+			manifest: halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp`),
+		}
+
+		planner := newPlanner(&manifestReader, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+		r := validRequest
+		r.Params.Command = config.SCALE
+		r.Params.Scale = []config.ProcessScale{{Memory: "1G"}}
+
+		p, err := planner.Plan(r, nil)
+
+		assert.NoError(t, err)
+		assert.Len(t, p, 2)
+		assert.Equal(t, "Scaling 'myApp': web with 1024MB memory", p[0].String())
+	})
+
+	t.Run("Set env planner", func(t *testing.T) {
+		manifestReader := ManifestReadWriteStub{
+			manifest: halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp`),
+		}
+
+		planner := newPlanner(&manifestReader, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+		r := validRequest
+		r.Params.Command = config.SET_ENV
+		r.Params.Vars = map[string]string{"A": "a"}
+
+		p, err := planner.Plan(r, nil)
+
+		assert.NoError(t, err)
+		assert.Len(t, p, 2)
+		assert.Equal(t, "Updating the env of 'myApp': setting [A]", p[0].String())
 	})
 }
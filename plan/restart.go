@@ -0,0 +1,164 @@
+package plan
+
+import (
+	"code.cloudfoundry.org/cli/util/manifestparser"
+	"context"
+	"fmt"
+	cfclient "github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/springernature/halfpipe-deploy-resource/logger"
+	"time"
+)
+
+type RestartPlan interface {
+	Plan(manifest manifestparser.Application, request config.Request) (pl Plan)
+}
+
+// restartPlan replaces the instances of the live app one by one with a rolling deployment.
+// When restaging, the current package of the app is staged again first and the new droplet is deployed.
+type restartPlan struct {
+	restage        bool
+	pollingOptions *cfclient.PollingOptions
+	checkInterval  time.Duration
+}
+
+func (p restartPlan) Plan(manifest manifestparser.Application, request config.Request) (pl Plan) {
+	desc := fmt.Sprintf("Restarting '%s' with a rolling deployment", manifest.Name)
+	if p.restage {
+		desc = fmt.Sprintf("Restaging '%s' and deploying the new droplet with a rolling deployment", manifest.Name)
+	}
+	pl = append(pl, NewClientCommand(p.createFunc(manifest, request), desc))
+
+	checkDesc := fmt.Sprintf("Checking that all instances of '%s' are running", manifest.Name)
+	pl = append(pl, NewClientCommand(checkPlan{}.createFunc(manifest.Name, request.Source.Org, request.Source.Space), checkDesc))
+	return
+}
+
+func (p restartPlan) createFunc(manifest manifestparser.Application, request config.Request) func(*cfclient.Client, *logger.CapturingWriter) error {
+	return func(cfClient *cfclient.Client, logger *logger.CapturingWriter) error {
+		ctx := context.Background()
+		apps, err := getAppsInOrgSpace(ctx, cfClient, request.Source.Org, request.Source.Space)
+		if err != nil {
+			return err
+		}
+
+		live := findApp(manifest.Name, apps)
+		if live == nil {
+			return fmt.Errorf("app '%s' not found", manifest.Name)
+		}
+		if live.State != "STARTED" {
+			return fmt.Errorf("app '%s' is %s, a rolling deployment needs a started app", live.Name, live.State)
+		}
+
+		dropletGUID := ""
+		if p.restage {
+			if dropletGUID, err = p.stage(ctx, cfClient, logger, live); err != nil {
+				return err
+			}
+		}
+		return p.deploy(ctx, cfClient, logger, live, dropletGUID)
+	}
+}
+
+// deploy replaces the instances of the app with a rolling deployment of the droplet, or of the current droplet if empty.
+func (p restartPlan) deploy(ctx context.Context, cfClient *cfclient.Client, logger *logger.CapturingWriter, app *resource.App, dropletGUID string) error {
+	deployment := resource.NewDeploymentCreate(app.GUID)
+	deployment.Strategy = "rolling"
+	if dropletGUID != "" {
+		deployment.Droplet = &resource.Relationship{GUID: dropletGUID}
+	}
+
+	created, err := cfClient.Deployments.Create(ctx, deployment)
+	if err != nil {
+		return fmt.Errorf("failed to create a deployment for '%s': %w", app.Name, err)
+	}
+	logger.Println(fmt.Sprintf("Created deployment '%s' of droplet '%s'", created.GUID, created.Droplet.GUID))
+
+	return p.waitForDeployment(ctx, cfClient, logger, app.Name, created.GUID)
+}
+
+// stage builds a new droplet from the most recent package of the app, like 'cf restage' does.
+func (p restartPlan) stage(ctx context.Context, cfClient *cfclient.Client, logger *logger.CapturingWriter, app *resource.App) (string, error) {
+	opts := cfclient.NewPackageListOptions()
+	opts.States = cfclient.Filter{Values: []string{string(resource.PackageStateReady)}}
+	opts.OrderBy = "-created_at"
+	pkg, err := cfClient.Packages.FirstForApp(ctx, app.GUID, opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to find a package to stage for '%s': %w", app.Name, err)
+	}
+
+	build, err := cfClient.Builds.Create(ctx, resource.NewBuildCreate(pkg.GUID))
+	if err != nil {
+		return "", fmt.Errorf("failed to stage '%s': %w", app.Name, err)
+	}
+	logger.Println(fmt.Sprintf("Staging package '%s' in build '%s'", pkg.GUID, build.GUID))
+
+	if err := cfClient.Builds.PollStaged(ctx, build.GUID, p.pollingOptions); err != nil {
+		return "", fmt.Errorf("failed to stage '%s': %w", app.Name, err)
+	}
+
+	staged, err := cfClient.Builds.Get(ctx, build.GUID)
+	if err != nil {
+		return "", err
+	}
+	if staged.Droplet == nil {
+		return "", fmt.Errorf("build '%s' of '%s' did not create a droplet", build.GUID, app.Name)
+	}
+	logger.Println(fmt.Sprintf("Staged droplet '%s'", staged.Droplet.GUID))
+	return staged.Droplet.GUID, nil
+}
+
+// waitForDeployment polls the deployment until it is finalized, like checkPlan it relies on the timeout of the plan.
+func (p restartPlan) waitForDeployment(ctx context.Context, cfClient *cfclient.Client, logger *logger.CapturingWriter, appName string, guid string) error {
+	lastStatus := ""
+	for {
+		deployment, err := cfClient.Deployments.Get(ctx, guid)
+		if err != nil {
+			return err
+		}
+
+		status := fmt.Sprintf("Deployment is %s (%s)", deployment.Status.Value, deployment.Status.Reason)
+		if status != lastStatus {
+			logger.Println(status)
+			lastStatus = status
+		}
+
+		if deployment.Status.Value == "FINALIZED" {
+			return p.deploymentResult(appName, deployment.Status)
+		}
+		time.Sleep(p.checkInterval)
+	}
+}
+
+func (p restartPlan) deploymentResult(appName string, status resource.DeploymentStatus) error {
+	if status.Reason == "DEPLOYED" {
+		return nil
+	}
+
+	err := fmt.Errorf("deployment of '%s' finished with reason %s", appName, status.Reason)
+	if status.Details != nil && status.Details.Error != "" {
+		err = fmt.Errorf("%w: %s", err, status.Details.Error)
+	}
+	return err
+}
+
+func newRestartPlan(restage bool) restartPlan {
+	return restartPlan{
+		restage: restage,
+		pollingOptions: &cfclient.PollingOptions{
+			FailedState:   string(resource.BuildStateFailed),
+			Timeout:       10 * time.Minute,
+			CheckInterval: 2 * time.Second,
+		},
+		checkInterval: 5 * time.Second,
+	}
+}
+
+func NewRestartPlan() RestartPlan {
+	return newRestartPlan(false)
+}
+
+func NewRestagePlan() RestartPlan {
+	return newRestartPlan(true)
+}
@@ -0,0 +1,41 @@
+package plan
+
+import (
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	halfpipe_deploy_resource "github.com/springernature/halfpipe-deploy-resource"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRestartPlan(t *testing.T) {
+	man := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp`).Applications[0]
+
+	t.Run("restart", func(t *testing.T) {
+		p := NewRestartPlan().Plan(man, validRequest)
+
+		assert.Len(t, p, 2)
+		assert.Equal(t, "Restarting 'myApp' with a rolling deployment", p[0].String())
+		assert.Equal(t, "Checking that all instances of 'myApp' are running", p[1].String())
+	})
+
+	t.Run("restage", func(t *testing.T) {
+		p := NewRestagePlan().Plan(man, validRequest)
+
+		assert.Len(t, p, 2)
+		assert.Equal(t, "Restaging 'myApp' and deploying the new droplet with a rolling deployment", p[0].String())
+		assert.Equal(t, "Checking that all instances of 'myApp' are running", p[1].String())
+	})
+}
+
+func TestDeploymentResult(t *testing.T) {
+	p := restartPlan{}
+
+	assert.NoError(t, p.deploymentResult("myApp", resource.DeploymentStatus{Value: "FINALIZED", Reason: "DEPLOYED"}))
+	assert.EqualError(t, p.deploymentResult("myApp", resource.DeploymentStatus{Value: "FINALIZED", Reason: "CANCELED"}), "deployment of 'myApp' finished with reason CANCELED")
+	assert.EqualError(t, p.deploymentResult("myApp", resource.DeploymentStatus{
+		Value:   "FINALIZED",
+		Reason:  "SUPERSEDED",
+		Details: &resource.DeploymentStatusDetails{Error: "newer deployment"},
+	}), "deployment of 'myApp' finished with reason SUPERSEDED: newer deployment")
+}
@@ -0,0 +1,207 @@
+package plan
+
+import (
+	"code.cloudfoundry.org/cli/util/manifestparser"
+	"context"
+	"errors"
+	"fmt"
+	cfclient "github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/springernature/halfpipe-deploy-resource/logger"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var validHostname = regexp.MustCompile(`^([\w-]{1,63}|\*)$`)
+var validPath = regexp.MustCompile(`^(/[^/?#\s]+)+$`)
+
+type RouteCheckPlan interface {
+	Plan(manifest manifestparser.Application, request config.Request) (pl Plan)
+}
+
+type routeCheckPlan struct{}
+
+func (p routeCheckPlan) Plan(manifest manifestparser.Application, request config.Request) (pl Plan) {
+	if len(manifestRoutes(manifest)) == 0 {
+		return
+	}
+	pl = append(pl, NewClientCommand(p.createFunc(manifest, request), "Checking the routes in the manifest"))
+	return
+}
+
+func (p routeCheckPlan) createFunc(manifest manifestparser.Application, request config.Request) func(*cfclient.Client, *logger.CapturingWriter) error {
+	return func(cfClient *cfclient.Client, logger *logger.CapturingWriter) error {
+		ctx := context.Background()
+		org, space, err := getOrgAndSpace(ctx, cfClient, request.Source.Org, request.Source.Space)
+		if err != nil {
+			return err
+		}
+
+		// Same lookup as the privateDomainsInOrg the promote plan gets, shared and private domains of the org.
+		domains, err := cfClient.Domains.ListForOrganizationAll(ctx, org.GUID, cfclient.NewDomainListOptions())
+		if err != nil {
+			return err
+		}
+
+		apps, err := getAppsInOrgSpace(ctx, cfClient, request.Source.Org, request.Source.Space)
+		if err != nil {
+			return err
+		}
+
+		var conflicts []string
+		routes, parseErrors := parseManifestRoutes(manifest, domains)
+		for _, err := range parseErrors {
+			conflicts = append(conflicts, err.Error())
+		}
+		for _, route := range routes {
+			routeConflicts, err := p.checkRoute(ctx, cfClient, logger, route, manifest, apps, request.Source.Org, space.GUID)
+			if err != nil {
+				return err
+			}
+			conflicts = append(conflicts, routeConflicts...)
+		}
+
+		if len(conflicts) > 0 {
+			return errors.New(fmt.Sprintf("found %d problems with the routes in the manifest:\n* %s", len(conflicts), strings.Join(conflicts, "\n* ")))
+		}
+
+		logger.Println("OK")
+		return nil
+	}
+}
+
+func (p routeCheckPlan) checkRoute(ctx context.Context, cfClient *cfclient.Client, logger *logger.CapturingWriter, route parsedRoute, manifest manifestparser.Application, apps []*resource.App, org string, spaceGUID string) (conflicts []string, err error) {
+	conflicts = p.validateRoute(route, org)
+	if len(conflicts) > 0 {
+		return
+	}
+
+	domain := route.KnownDomain
+	switch {
+	case route.IsInternal():
+		logger.Println(fmt.Sprintf("Route '%s' is on internal domain '%s'", route.URL, domain.Name))
+	case route.IsTCP():
+		logger.Println(fmt.Sprintf("Route '%s' is on TCP domain '%s'", route.URL, domain.Name))
+	case isSharedDomain(domain):
+		logger.Println(fmt.Sprintf("Route '%s' is on shared domain '%s'", route.URL, domain.Name))
+	default:
+		logger.Println(fmt.Sprintf("Route '%s' is on private domain '%s'", route.URL, domain.Name))
+	}
+
+	opts := cfclient.NewRouteListOptions()
+	opts.DomainGUIDs = cfclient.Filter{Values: []string{domain.GUID}}
+	if route.IsTCP() {
+		opts.Ports = cfclient.Filter{Values: []string{strconv.Itoa(route.Port)}}
+	} else {
+		opts.Hosts = cfclient.Filter{Values: []string{route.Host}}
+	}
+	visible, err := cfClient.Routes.ListAll(ctx, opts)
+	if err != nil {
+		return
+	}
+
+	for _, existing := range visible {
+		if existing.Host != route.Host || existing.Path != route.Path || (existing.Port != nil && *existing.Port != route.Port) {
+			continue
+		}
+
+		if existing.Relationships.Space.Data == nil || existing.Relationships.Space.Data.GUID != spaceGUID {
+			conflicts = append(conflicts, fmt.Sprintf("route '%s' belongs to another space", route.URL))
+			return
+		}
+
+		for _, destination := range existing.Destinations {
+			if destination.App.GUID == nil {
+				continue
+			}
+			if name, inFamily := p.appInFamily(*destination.App.GUID, manifest, apps); !inFamily {
+				conflicts = append(conflicts, fmt.Sprintf("route '%s' is mapped to app %s, which is not '%s' or one of its candidate, old or deleted versions", route.URL, name, manifest.Name))
+			}
+		}
+		return
+	}
+
+	// We could not see the route, but it might be reserved in a space we don't have access to.
+	reservationOpts := cfclient.NewRouteReservationListOptions()
+	reservationOpts.Hosts = route.Host
+	reservationOpts.Paths = route.Path
+	reservationOpts.Ports = route.Port
+	reserved, err := cfClient.Routes.IsRouteReserved(ctx, domain.GUID, reservationOpts)
+	if err != nil {
+		return
+	}
+	if reserved {
+		conflicts = append(conflicts, fmt.Sprintf("route '%s' is reserved in a space you do not have access to", route.URL))
+	}
+	return
+}
+
+// validateRoute checks the parts of the route against the kind of domain it is on without calling CF.
+func (p routeCheckPlan) validateRoute(route parsedRoute, org string) (conflicts []string) {
+	domain := route.KnownDomain
+	if domain == nil {
+		return []string{fmt.Sprintf("route '%s' is not on any domain available in org '%s'", route.URL, org)}
+	}
+
+	if route.IsTCP() {
+		if route.Port == 0 {
+			conflicts = append(conflicts, fmt.Sprintf("route '%s' must have a port as '%s' is a TCP domain", route.URL, domain.Name))
+		}
+		if route.Host != "" || route.Path != "" {
+			conflicts = append(conflicts, fmt.Sprintf("route '%s' cannot have a hostname or path as '%s' is a TCP domain", route.URL, domain.Name))
+		}
+		if route.Protocol != "" && route.Protocol != "tcp" {
+			conflicts = append(conflicts, fmt.Sprintf("route '%s' has protocol '%s', only 'tcp' is allowed on a TCP domain", route.URL, route.Protocol))
+		}
+		return
+	}
+
+	if route.Port != 0 {
+		conflicts = append(conflicts, fmt.Sprintf("route '%s' cannot have a port as '%s' is not a TCP domain", route.URL, domain.Name))
+	}
+	if route.Protocol != "" && route.Protocol != "http1" && route.Protocol != "http2" {
+		conflicts = append(conflicts, fmt.Sprintf("route '%s' has protocol '%s', must be 'http1' or 'http2'", route.URL, route.Protocol))
+	}
+	if route.Host == "" && route.IsInternal() {
+		conflicts = append(conflicts, fmt.Sprintf("route '%s' must have a hostname as '%s' is an internal domain", route.URL, domain.Name))
+	} else if route.Host == "" && isSharedDomain(domain) {
+		conflicts = append(conflicts, fmt.Sprintf("route '%s' must have a hostname as '%s' is a shared domain", route.URL, domain.Name))
+	}
+	if i := strings.Index(route.Host, "."); i != -1 {
+		conflicts = append(conflicts, fmt.Sprintf("route '%s' has hostname '%s' with a '.', which CF does not allow, '%s.%s' is not a domain in the org", route.URL, route.Host, route.Host[i+1:], domain.Name))
+	} else if route.Host != "" && !validHostname.MatchString(route.Host) {
+		conflicts = append(conflicts, fmt.Sprintf("route '%s' has invalid hostname '%s', only letters, digits, '_' and '-' are allowed", route.URL, route.Host))
+	}
+	if route.Path != "" {
+		if route.IsInternal() {
+			conflicts = append(conflicts, fmt.Sprintf("route '%s' cannot have a path as '%s' is an internal domain", route.URL, domain.Name))
+		} else if !validPath.MatchString(route.Path) {
+			conflicts = append(conflicts, fmt.Sprintf("route '%s' has invalid path '%s'", route.URL, route.Path))
+		}
+	}
+	return
+}
+
+func (p routeCheckPlan) appInFamily(appGUID string, manifest manifestparser.Application, apps []*resource.App) (name string, inFamily bool) {
+	for _, app := range apps {
+		if app.GUID != appGUID {
+			continue
+		}
+		inFamily = app.Name == manifest.Name ||
+			app.Name == createCandidateAppName(manifest.Name) ||
+			app.Name == createOldAppName(manifest.Name) ||
+			strings.HasPrefix(app.Name, createDeleteName(manifest.Name, 0))
+		return fmt.Sprintf("'%s'", app.Name), inFamily
+	}
+	return fmt.Sprintf("with guid '%s' in another space", appGUID), false
+}
+
+func isSharedDomain(domain *resource.Domain) bool {
+	return domain.Relationships.Organization == nil || domain.Relationships.Organization.Data == nil
+}
+
+func NewRouteCheckPlan() RouteCheckPlan {
+	return routeCheckPlan{}
+}
@@ -0,0 +1,120 @@
+package plan
+
+import (
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	halfpipe_deploy_resource "github.com/springernature/halfpipe-deploy-resource"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRouteCheckPlan(t *testing.T) {
+	t.Run("nothing to check without routes", func(t *testing.T) {
+		man := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp`).Applications[0]
+
+		assert.Empty(t, NewRouteCheckPlan().Plan(man, validRequest))
+	})
+
+	t.Run("checks the routes", func(t *testing.T) {
+		man := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp
+  routes:
+  - route: myApp.example.com`).Applications[0]
+
+		p := NewRouteCheckPlan().Plan(man, validRequest)
+
+		assert.Len(t, p, 1)
+		assert.Equal(t, "Checking the routes in the manifest", p[0].String())
+	})
+}
+
+func TestRouteCheckValidateRoute(t *testing.T) {
+	domains := []*resource.Domain{
+		{Name: "shared.com"},
+		{Name: "private.com", Relationships: resource.DomainRelationships{Organization: &resource.ToOneRelationship{Data: &resource.Relationship{GUID: "org-guid"}}}},
+		{Name: "apps.internal", Internal: true},
+		{Name: "tcp.shared.com", RouterGroup: &resource.Relationship{GUID: "tcp-router-group"}},
+	}
+
+	tests := []struct {
+		route     string
+		protocol  string
+		conflicts []string
+	}{
+		{route: "myApp.shared.com"},
+		{route: "my_app.shared.com"},
+		{route: "myApp.shared.com/api/v1"},
+		{route: "myApp.shared.com", protocol: "http2"},
+		{route: "private.com"},
+		{route: "private.com/api"},
+		{route: "myApp.apps.internal"},
+		{route: "tcp.shared.com:1234"},
+		{route: "shared.com", conflicts: []string{
+			"route 'shared.com' must have a hostname as 'shared.com' is a shared domain",
+		}},
+		{route: "my.app.shared.com", conflicts: []string{
+			"route 'my.app.shared.com' has hostname 'my.app' with a '.', which CF does not allow, 'app.shared.com' is not a domain in the org",
+		}},
+		{route: "my+app.shared.com", conflicts: []string{
+			"route 'my+app.shared.com' has invalid hostname 'my+app', only letters, digits, '_' and '-' are allowed",
+		}},
+		{route: "myApp.shared.com/api//v1", conflicts: []string{
+			"route 'myApp.shared.com/api//v1' has invalid path '/api//v1'",
+		}},
+		{route: "myApp.shared.com/api?a=b", conflicts: []string{
+			"route 'myApp.shared.com/api?a=b' has invalid path '/api?a=b'",
+		}},
+		{route: "myApp.shared.com", protocol: "http3", conflicts: []string{
+			"route 'myApp.shared.com' has protocol 'http3', must be 'http1' or 'http2'",
+		}},
+		{route: "myApp.shared.com:8080", conflicts: []string{
+			"route 'myApp.shared.com:8080' cannot have a port as 'shared.com' is not a TCP domain",
+		}},
+		{route: "myApp.apps.internal/api", conflicts: []string{
+			"route 'myApp.apps.internal/api' cannot have a path as 'apps.internal' is an internal domain",
+		}},
+		{route: "tcp.shared.com", conflicts: []string{
+			"route 'tcp.shared.com' must have a port as 'tcp.shared.com' is a TCP domain",
+		}},
+		{route: "myApp.tcp.shared.com:1234", protocol: "http2", conflicts: []string{
+			"route 'myApp.tcp.shared.com:1234' cannot have a hostname or path as 'tcp.shared.com' is a TCP domain",
+			"route 'myApp.tcp.shared.com:1234' has protocol 'http2', only 'tcp' is allowed on a TCP domain",
+		}},
+		{route: "myApp.unknown.com", conflicts: []string{
+			"route 'myApp.unknown.com' is not on any domain available in org 'b'",
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.route, func(t *testing.T) {
+			route, _ := parseRoute(test.route, test.protocol, domains)
+			assert.Equal(t, test.conflicts, routeCheckPlan{}.validateRoute(route, "b"))
+		})
+	}
+}
+
+func TestRouteCheckAppInFamily(t *testing.T) {
+	man := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp`).Applications[0]
+
+	apps := []*resource.App{
+		{Name: "myApp", Resource: resource.Resource{GUID: "1"}},
+		{Name: "myApp-CANDIDATE", Resource: resource.Resource{GUID: "2"}},
+		{Name: "myApp-OLD", Resource: resource.Resource{GUID: "3"}},
+		{Name: "myApp-DELETE-1", Resource: resource.Resource{GUID: "4"}},
+		{Name: "someOtherApp", Resource: resource.Resource{GUID: "5"}},
+	}
+
+	for _, guid := range []string{"1", "2", "3", "4"} {
+		_, inFamily := routeCheckPlan{}.appInFamily(guid, man, apps)
+		assert.True(t, inFamily, guid)
+	}
+
+	name, inFamily := routeCheckPlan{}.appInFamily("5", man, apps)
+	assert.False(t, inFamily)
+	assert.Equal(t, "'someOtherApp'", name)
+
+	name, inFamily = routeCheckPlan{}.appInFamily("6", man, apps)
+	assert.False(t, inFamily)
+	assert.Equal(t, "with guid '6' in another space", name)
+}
@@ -0,0 +1,182 @@
+package plan
+
+import (
+	"code.cloudfoundry.org/cli/util/manifestparser"
+	"context"
+	"fmt"
+	cfclient "github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/springernature/halfpipe-deploy-resource/logger"
+	"strings"
+	"time"
+)
+
+type RouteCleanupPlan interface {
+	Plan(manifest manifestparser.Application, request config.Request) (pl Plan)
+}
+
+type routeCleanupPlan struct {
+	pollingOptions *cfclient.PollingOptions
+}
+
+func (p routeCleanupPlan) Plan(manifest manifestparser.Application, request config.Request) (pl Plan) {
+	desc := "Finding unused routes to delete"
+	if len(request.Params.ProtectedHostnames) > 0 {
+		desc = fmt.Sprintf("%s, never touching hostnames [%s]", desc, strings.Join(request.Params.ProtectedHostnames, ", "))
+	}
+	pl = append(pl, NewClientCommand(p.createFunc(manifest, request), desc))
+	return
+}
+
+func (p routeCleanupPlan) createFunc(manifest manifestparser.Application, request config.Request) func(*cfclient.Client, *logger.CapturingWriter) error {
+	return func(cfClient *cfclient.Client, logger *logger.CapturingWriter) error {
+		ctx := context.Background()
+		_, space, err := getOrgAndSpace(ctx, cfClient, request.Source.Org, request.Source.Space)
+		if err != nil {
+			return err
+		}
+
+		domains, err := cfClient.Domains.ListAll(ctx, cfclient.NewDomainListOptions())
+		if err != nil {
+			return err
+		}
+
+		var candidates []*resource.Route
+
+		if testDomain := findDomain(request.Params.TestDomain, domains); testDomain != nil {
+			opts := cfclient.NewRouteListOptions()
+			opts.SpaceGUIDs = cfclient.Filter{Values: []string{space.GUID}}
+			opts.DomainGUIDs = cfclient.Filter{Values: []string{testDomain.GUID}}
+			opts.Hosts = cfclient.Filter{Values: []string{createCandidateHostname(manifest, request)}}
+			routes, err := cfClient.Routes.ListAll(ctx, opts)
+			if err != nil {
+				return err
+			}
+			candidates = append(candidates, routes...)
+		}
+
+		var manifestDomainGUIDs []string
+		for _, route := range manifestRoutes(manifest) {
+			if domain := domainForRoute(route, domains); domain != nil {
+				manifestDomainGUIDs = append(manifestDomainGUIDs, domain.GUID)
+			}
+		}
+		if len(manifestDomainGUIDs) > 0 {
+			opts := cfclient.NewRouteListOptions()
+			opts.SpaceGUIDs = cfclient.Filter{Values: []string{space.GUID}}
+			opts.DomainGUIDs = cfclient.Filter{Values: manifestDomainGUIDs}
+			routes, err := cfClient.Routes.ListAll(ctx, opts)
+			if err != nil {
+				return err
+			}
+			candidates = append(candidates, routes...)
+		}
+
+		seen := make(map[string]bool)
+		for _, route := range candidates {
+			if seen[route.GUID] {
+				continue
+			}
+			seen[route.GUID] = true
+
+			if reason, keep := p.keepRoute(route, manifest, request); keep {
+				logger.Println(fmt.Sprintf("Keeping route %s, %s", route.URL, reason))
+				continue
+			}
+
+			bindingOpts := cfclient.NewServiceRouteBindingListOptions()
+			bindingOpts.RouteGUIDs = cfclient.Filter{Values: []string{route.GUID}}
+			bindings, err := cfClient.ServiceRouteBindings.ListAll(ctx, bindingOpts)
+			if err != nil {
+				return err
+			}
+			if len(bindings) > 0 {
+				logger.Println(fmt.Sprintf("Keeping route %s, it is bound to a route service", route.URL))
+				continue
+			}
+
+			logger.Println(fmt.Sprintf("Deleting route %s, it has no destinations", route.URL))
+			jobGUID, err := cfClient.Routes.Delete(ctx, route.GUID)
+			if err != nil {
+				return err
+			}
+			if jobGUID != "" {
+				if err := cfClient.Jobs.PollComplete(ctx, jobGUID, p.pollingOptions); err != nil {
+					return err
+				}
+			}
+		}
+		logger.Println("OK")
+		return nil
+	}
+}
+
+func (p routeCleanupPlan) keepRoute(route *resource.Route, manifest manifestparser.Application, request config.Request) (reason string, keep bool) {
+	if len(route.Destinations) > 0 {
+		return "it is mapped to an app", true
+	}
+
+	for _, hostname := range request.Params.ProtectedHostnames {
+		if route.Host == hostname {
+			return "the hostname is protected", true
+		}
+	}
+
+	for _, manifestRoute := range manifestRoutes(manifest) {
+		if route.URL == manifestRoute {
+			return "it is in the manifest", true
+		}
+	}
+
+	// Other apps in the space may have unmapped routes on the same domains, only the routes of this app are deleted.
+	for _, hostname := range familyHostnames(manifest, request) {
+		if strings.EqualFold(route.Host, hostname) {
+			return "", false
+		}
+	}
+	return fmt.Sprintf("the hostname is not one of '%s'", manifest.Name), true
+}
+
+// familyHostnames are the hostnames the routes of the app and its candidate have, unless the manifest says otherwise.
+func familyHostnames(manifest manifestparser.Application, request config.Request) []string {
+	return []string{
+		manifest.Name,
+		createCandidateAppName(manifest.Name),
+		createCandidateHostname(manifest, request),
+	}
+}
+
+func findDomain(name string, domains []*resource.Domain) *resource.Domain {
+	for _, domain := range domains {
+		if domain.Name == name {
+			return domain
+		}
+	}
+	return nil
+}
+
+// domainForRoute finds the longest domain the route is on, so that 'a.b.example.com' is on
+// 'b.example.com' rather than 'example.com' if both exist.
+func domainForRoute(route string, domains []*resource.Domain) (found *resource.Domain) {
+	hostAndDomain := strings.SplitN(route, "/", 2)[0]
+	hostAndDomain = strings.SplitN(hostAndDomain, ":", 2)[0]
+	for _, domain := range domains {
+		if hostAndDomain == domain.Name || strings.HasSuffix(hostAndDomain, "."+domain.Name) {
+			if found == nil || len(domain.Name) > len(found.Name) {
+				found = domain
+			}
+		}
+	}
+	return
+}
+
+func NewRouteCleanupPlan() RouteCleanupPlan {
+	return routeCleanupPlan{
+		pollingOptions: &cfclient.PollingOptions{
+			FailedState:   string(resource.JobStateFailed),
+			Timeout:       5 * time.Minute,
+			CheckInterval: time.Second,
+		},
+	}
+}
@@ -0,0 +1,113 @@
+package plan
+
+import (
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	halfpipe_deploy_resource "github.com/springernature/halfpipe-deploy-resource"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRouteCleanupPlan(t *testing.T) {
+	man := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp
+  routes:
+  - route: myApp.example.com`).Applications[0]
+
+	t.Run("without protected hostnames", func(t *testing.T) {
+		p := NewRouteCleanupPlan().Plan(man, validRequest)
+
+		assert.Len(t, p, 1)
+		assert.Equal(t, "Finding unused routes to delete", p[0].String())
+	})
+
+	t.Run("with protected hostnames", func(t *testing.T) {
+		r := validRequest
+		r.Params.ProtectedHostnames = []string{"www", "api"}
+
+		p := NewRouteCleanupPlan().Plan(man, r)
+
+		assert.Len(t, p, 1)
+		assert.Equal(t, "Finding unused routes to delete, never touching hostnames [www, api]", p[0].String())
+	})
+}
+
+func TestRouteCleanupKeepsRoutes(t *testing.T) {
+	man := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp
+  routes:
+  - route: myApp.example.com`).Applications[0]
+
+	r := validRequest
+	r.Params.ProtectedHostnames = []string{"www"}
+
+	p := routeCleanupPlan{}
+
+	t.Run("mapped to an app", func(t *testing.T) {
+		route := &resource.Route{Host: "other", URL: "other.example.com", Destinations: []resource.RouteDestination{{}}}
+		_, keep := p.keepRoute(route, man, r)
+		assert.True(t, keep)
+	})
+
+	t.Run("protected hostname", func(t *testing.T) {
+		route := &resource.Route{Host: "www", URL: "www.example.com"}
+		_, keep := p.keepRoute(route, man, r)
+		assert.True(t, keep)
+	})
+
+	t.Run("in the manifest", func(t *testing.T) {
+		route := &resource.Route{Host: "myApp", URL: "myApp.example.com"}
+		_, keep := p.keepRoute(route, man, r)
+		assert.True(t, keep)
+	})
+
+	t.Run("of another app", func(t *testing.T) {
+		route := &resource.Route{Host: "otherApp", URL: "otherApp.example.com"}
+		reason, keep := p.keepRoute(route, man, r)
+		assert.True(t, keep)
+		assert.Equal(t, "the hostname is not one of 'myApp'", reason)
+	})
+
+	t.Run("orphaned", func(t *testing.T) {
+		route := &resource.Route{Host: "myApp-CANDIDATE", URL: "myApp-CANDIDATE.example.com"}
+		_, keep := p.keepRoute(route, man, r)
+		assert.False(t, keep)
+	})
+
+	t.Run("orphaned with another path", func(t *testing.T) {
+		route := &resource.Route{Host: "myApp", Path: "/api", URL: "myApp.example.com/api"}
+		_, keep := p.keepRoute(route, man, r)
+		assert.False(t, keep)
+	})
+}
+
+func TestDomainForRoute(t *testing.T) {
+	domains := []*resource.Domain{
+		{Name: "example.com"},
+		{Name: "b.example.com"},
+		{Name: "tcp.example.com"},
+	}
+
+	tests := []struct {
+		route    string
+		expected string
+	}{
+		{"a.example.com", "example.com"},
+		{"a.b.example.com", "b.example.com"},
+		{"b.example.com", "b.example.com"},
+		{"a.example.com/some/path", "example.com"},
+		{"tcp.example.com:1234", "tcp.example.com"},
+		{"a.example.org", ""},
+		{"aexample.com", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.route, func(t *testing.T) {
+			domain := domainForRoute(test.route, domains)
+			if test.expected == "" {
+				assert.Nil(t, domain)
+			} else {
+				assert.Equal(t, test.expected, domain.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package plan
+
+import (
+	"code.cloudfoundry.org/cli/util/manifestparser"
+	"errors"
+	"fmt"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"strconv"
+	"strings"
+)
+
+// parsedRoute is a route from the manifest split into its parts.
+type parsedRoute struct {
+	URL      string
+	Host     string
+	Domain   string
+	Path     string
+	Port     int
+	Protocol string
+
+	// KnownDomain is the domain from the org the route is on, nil if the domain could not be found.
+	KnownDomain *resource.Domain
+}
+
+func (r parsedRoute) IsInternal() bool {
+	return r.KnownDomain != nil && r.KnownDomain.Internal
+}
+
+func (r parsedRoute) IsTCP() bool {
+	if r.KnownDomain != nil {
+		return r.KnownDomain.RouterGroup != nil
+	}
+	return r.Port != 0
+}
+
+// parseRoute splits a route into host, domain, path and port using the domains in the org.
+// The domain is the longest domain the route ends with, so multi-level domains work,
+// e.g. 'a.b.example.com' is host 'a' on 'b.example.com' if that domain exists, otherwise host 'a.b' on 'example.com'.
+// CF does not allow a '.' in a hostname, so the route check rejects such a host.
+// When the domain is not in the org we fall back to everything after the first '.' being the domain.
+// The route is returned as far as it could be parsed, even if there is an error.
+func parseRoute(url string, protocol string, domains []*resource.Domain) (route parsedRoute, err error) {
+	route = parsedRoute{URL: url, Protocol: protocol}
+
+	hostAndDomain := url
+	if i := strings.Index(url, "/"); i != -1 {
+		hostAndDomain = url[:i]
+		route.Path = url[i:]
+	}
+
+	if i := strings.LastIndex(hostAndDomain, ":"); i != -1 {
+		port := hostAndDomain[i+1:]
+		hostAndDomain = hostAndDomain[:i]
+		if route.Port, err = strconv.Atoi(port); err != nil || route.Port < 1 || route.Port > 65535 {
+			route.Port = 0
+			err = errors.New(fmt.Sprintf("route '%s' has invalid port '%s'", url, port))
+		}
+	}
+
+	route.KnownDomain = domainForRoute(hostAndDomain, domains)
+	if route.KnownDomain != nil {
+		route.Domain = route.KnownDomain.Name
+		route.Host = strings.TrimSuffix(strings.TrimSuffix(hostAndDomain, route.Domain), ".")
+		return
+	}
+
+	if parts := strings.SplitN(hostAndDomain, ".", 2); len(parts) == 2 {
+		route.Host = parts[0]
+		route.Domain = parts[1]
+	} else {
+		route.Domain = hostAndDomain
+	}
+	return
+}
+
+// parseManifestRoutes parses all the routes in the manifest together with their protocol.
+func parseManifestRoutes(man manifestparser.Application, domains []*resource.Domain) (routes []parsedRoute, errs []error) {
+	rawRoutes := []any{}
+	if man.RemainingManifestFields["routes"] != nil {
+		rawRoutes = man.RemainingManifestFields["routes"].([]any)
+	}
+
+	for _, r := range rawRoutes {
+		rawRoute := r.(map[any]any)
+		protocol, _ := rawRoute["protocol"].(string)
+		route, err := parseRoute(rawRoute["route"].(string), protocol, domains)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		routes = append(routes, route)
+	}
+	return
+}
+
+// cliSupportsAppProtocol is false for cf6, whose map-route does not have --app-protocol.
+func cliSupportsAppProtocol(cliVersion string) bool {
+	return cliVersion != "cf6"
+}
+
+// verifyRouteProtocols fails when the manifest has a route with a protocol that the cf CLI cannot map.
+func verifyRouteProtocols(man manifestparser.Application, cliVersion string) error {
+	if cliSupportsAppProtocol(cliVersion) {
+		return nil
+	}
+	routes, _ := parseManifestRoutes(man, nil)
+	for _, route := range routes {
+		if route.Protocol != "" {
+			return config.ParamsInvalidError("cliVersion", fmt.Sprintf("must be 'cf7' or 'cf8' as route '%s' has protocol '%s', which %s cannot map", route.URL, route.Protocol, cliVersion))
+		}
+	}
+	return nil
+}
+
+func mapRouteCommand(appName string, route parsedRoute, cliVersion string) Command {
+	mapRoute := NewCfCommand("map-route", appName, route.Domain)
+	if route.Host != "" {
+		mapRoute = mapRoute.AddToArgs("--hostname", route.Host)
+	}
+	if route.Path != "" {
+		mapRoute = mapRoute.AddToArgs("--path", strings.TrimPrefix(route.Path, "/"))
+	}
+	if route.Port != 0 {
+		mapRoute = mapRoute.AddToArgs("--port", strconv.Itoa(route.Port))
+	}
+	if route.Protocol != "" && cliSupportsAppProtocol(cliVersion) {
+		mapRoute = mapRoute.AddToArgs("--app-protocol", route.Protocol)
+	}
+	return mapRoute
+}
@@ -0,0 +1,192 @@
+package plan
+
+import (
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	halfpipe_deploy_resource "github.com/springernature/halfpipe-deploy-resource"
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseRoute(t *testing.T) {
+	shared := &resource.Domain{Name: "example.com"}
+	sub := &resource.Domain{Name: "sub.example.com"}
+	private := &resource.Domain{Name: "private.com", Relationships: resource.DomainRelationships{Organization: &resource.ToOneRelationship{Data: &resource.Relationship{GUID: "org-guid"}}}}
+	internal := &resource.Domain{Name: "apps.internal", Internal: true}
+	tcp := &resource.Domain{Name: "tcp.example.com", RouterGroup: &resource.Relationship{GUID: "tcp-router-group"}}
+	domains := []*resource.Domain{shared, sub, private, internal, tcp}
+
+	tests := []struct {
+		name     string
+		route    string
+		protocol string
+		domains  []*resource.Domain
+		expected parsedRoute
+		internal bool
+		tcp      bool
+		err      bool
+	}{
+		{
+			name:     "host on shared domain",
+			route:    "myApp.example.com",
+			domains:  domains,
+			expected: parsedRoute{Host: "myApp", Domain: "example.com", KnownDomain: shared},
+		},
+		{
+			name:     "host with path",
+			route:    "myApp.example.com/api/v1",
+			domains:  domains,
+			expected: parsedRoute{Host: "myApp", Domain: "example.com", Path: "/api/v1", KnownDomain: shared},
+		},
+		{
+			name:     "multi-level domain",
+			route:    "myApp.sub.example.com",
+			domains:  domains,
+			expected: parsedRoute{Host: "myApp", Domain: "sub.example.com", KnownDomain: sub},
+		},
+		{
+			name:     "multi-level host, rejected by the route check",
+			route:    "a.b.example.com",
+			domains:  domains,
+			expected: parsedRoute{Host: "a.b", Domain: "example.com", KnownDomain: shared},
+		},
+		{
+			name:     "private domain without host",
+			route:    "private.com/path",
+			domains:  domains,
+			expected: parsedRoute{Domain: "private.com", Path: "/path", KnownDomain: private},
+		},
+		{
+			name:     "internal route",
+			route:    "myApp.apps.internal",
+			domains:  domains,
+			expected: parsedRoute{Host: "myApp", Domain: "apps.internal", KnownDomain: internal},
+			internal: true,
+		},
+		{
+			name:     "tcp route",
+			route:    "tcp.example.com:1234",
+			domains:  domains,
+			expected: parsedRoute{Domain: "tcp.example.com", Port: 1234, KnownDomain: tcp},
+			tcp:      true,
+		},
+		{
+			name:     "http2",
+			route:    "myApp.example.com",
+			protocol: "http2",
+			domains:  domains,
+			expected: parsedRoute{Host: "myApp", Domain: "example.com", Protocol: "http2", KnownDomain: shared},
+		},
+		{
+			name:     "invalid port",
+			route:    "tcp.example.com:http",
+			domains:  domains,
+			expected: parsedRoute{Domain: "tcp.example.com", KnownDomain: tcp},
+			tcp:      true,
+			err:      true,
+		},
+		{
+			name:     "unknown domain falls back to first label as host",
+			route:    "myApp.unknown.com/path",
+			domains:  domains,
+			expected: parsedRoute{Host: "myApp", Domain: "unknown.com", Path: "/path"},
+		},
+		{
+			name:     "unknown domain with port",
+			route:    "unknown.com:1234",
+			expected: parsedRoute{Host: "unknown", Domain: "com", Port: 1234},
+			tcp:      true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			route, err := parseRoute(test.route, test.protocol, test.domains)
+
+			if test.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			test.expected.URL = test.route
+			assert.Equal(t, test.expected, route)
+			assert.Equal(t, test.internal, route.IsInternal())
+			assert.Equal(t, test.tcp, route.IsTCP())
+		})
+	}
+}
+
+func TestParseRouteAgreesWithTheRouteCheckOnMultiLevelHosts(t *testing.T) {
+	shared := &resource.Domain{Name: "example.com"}
+	sub := &resource.Domain{Name: "b.example.com"}
+
+	route, err := parseRoute("a.b.example.com", "", []*resource.Domain{shared})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"route 'a.b.example.com' has hostname 'a.b' with a '.', which CF does not allow, 'b.example.com' is not a domain in the org",
+	}, routeCheckPlan{}.validateRoute(route, "org"))
+
+	route, err = parseRoute("a.b.example.com", "", []*resource.Domain{shared, sub})
+	assert.NoError(t, err)
+	assert.Equal(t, "a", route.Host)
+	assert.Empty(t, routeCheckPlan{}.validateRoute(route, "org"))
+}
+
+func TestMapRouteCommand(t *testing.T) {
+	tests := []struct {
+		route      parsedRoute
+		cliVersion string
+		expected   string
+	}{
+		{parsedRoute{Host: "myApp", Domain: "example.com"}, "cf7", "cf map-route myApp-CANDIDATE example.com --hostname myApp"},
+		{parsedRoute{Host: "myApp", Domain: "example.com", Path: "/api/v1"}, "cf7", "cf map-route myApp-CANDIDATE example.com --hostname myApp --path api/v1"},
+		{parsedRoute{Domain: "private.com"}, "cf7", "cf map-route myApp-CANDIDATE private.com"},
+		{parsedRoute{Host: "myApp", Domain: "apps.internal"}, "cf7", "cf map-route myApp-CANDIDATE apps.internal --hostname myApp"},
+		{parsedRoute{Domain: "tcp.example.com", Port: 1234}, "cf7", "cf map-route myApp-CANDIDATE tcp.example.com --port 1234"},
+		{parsedRoute{Host: "myApp", Domain: "example.com", Protocol: "http2"}, "cf7", "cf map-route myApp-CANDIDATE example.com --hostname myApp --app-protocol http2"},
+		{parsedRoute{Host: "myApp", Domain: "example.com", Protocol: "http2"}, "cf8", "cf map-route myApp-CANDIDATE example.com --hostname myApp --app-protocol http2"},
+		{parsedRoute{Host: "myApp", Domain: "example.com", Protocol: "http2"}, "cf6", "cf map-route myApp-CANDIDATE example.com --hostname myApp"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expected, func(t *testing.T) {
+			assert.Equal(t, test.expected, mapRouteCommand("myApp-CANDIDATE", test.route, test.cliVersion).String())
+		})
+	}
+}
+
+func TestVerifyRouteProtocols(t *testing.T) {
+	man := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp
+  routes:
+  - route: myApp.example.com
+  - route: myApp.example.com/grpc
+    protocol: http2`).Applications[0]
+
+	assert.NoError(t, verifyRouteProtocols(man, "cf7"))
+	assert.NoError(t, verifyRouteProtocols(man, "cf8"))
+	assert.Equal(t, config.ParamsInvalidError("cliVersion", "must be 'cf7' or 'cf8' as route 'myApp.example.com/grpc' has protocol 'http2', which cf6 cannot map"), verifyRouteProtocols(man, "cf6"))
+
+	withoutProtocol := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp
+  routes:
+  - route: myApp.example.com`).Applications[0]
+	assert.NoError(t, verifyRouteProtocols(withoutProtocol, "cf6"))
+}
+
+func TestParseManifestRoutes(t *testing.T) {
+	man := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp
+  routes:
+  - route: myApp.example.com
+    protocol: http2
+  - route: tcp.example.com:abc`).Applications[0]
+
+	routes, errs := parseManifestRoutes(man, nil)
+
+	assert.Len(t, routes, 2)
+	assert.Equal(t, "http2", routes[0].Protocol)
+	assert.Equal(t, "tcp.example.com:abc", routes[1].URL)
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "route 'tcp.example.com:abc' has invalid port 'abc'")
+}
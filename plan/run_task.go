@@ -0,0 +1,238 @@
+package plan
+
+import (
+	"code.cloudfoundry.org/cli/util/manifestparser"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	cfclient "github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/springernature/halfpipe-deploy-resource/logger"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// logCacheEnvelopes is the part of a response from the log cache read API, /api/v1/read/<source-id>, that we need.
+// go-cfclient doesn't have a client for it.
+type logCacheEnvelopes struct {
+	Envelopes struct {
+		Batch []logCacheEnvelope `json:"batch"`
+	} `json:"envelopes"`
+}
+
+type logCacheEnvelope struct {
+	Timestamp string            `json:"timestamp"`
+	Tags      map[string]string `json:"tags"`
+	Log       *struct {
+		Payload string `json:"payload"`
+	} `json:"log"`
+}
+
+type RunTaskPlan interface {
+	Plan(manifest manifestparser.Application, request config.Request) (pl Plan)
+}
+
+type runTaskPlan struct {
+	checkInterval time.Duration
+}
+
+func (p runTaskPlan) Plan(manifest manifestparser.Application, request config.Request) (pl Plan) {
+	task := request.Params.Task
+	desc := fmt.Sprintf("Running task '%s' with command '%s' on '%s'", task.NameOrDefault(), task.Command, createCandidateAppName(manifest.Name))
+	pl = append(pl, NewClientCommand(p.createFunc(manifest, request), desc))
+	return
+}
+
+func (p runTaskPlan) createFunc(manifest manifestparser.Application, request config.Request) func(*cfclient.Client, *logger.CapturingWriter) error {
+	return func(cfClient *cfclient.Client, logger *logger.CapturingWriter) error {
+		ctx := context.Background()
+		task := request.Params.Task
+		candidateName := createCandidateAppName(manifest.Name)
+
+		apps, err := getAppsInOrgSpace(ctx, cfClient, request.Source.Org, request.Source.Space)
+		if err != nil {
+			return err
+		}
+		candidate := findApp(candidateName, apps)
+		if candidate == nil {
+			return fmt.Errorf("candidate app '%s' not found", candidateName)
+		}
+
+		droplet, err := cfClient.Droplets.GetCurrentForApp(ctx, candidate.GUID)
+		if err != nil {
+			return fmt.Errorf("failed to get the droplet of '%s': %w", candidateName, err)
+		}
+
+		create := resource.NewTaskCreateWithCommand(task.Command).
+			WithName(task.NameOrDefault()).
+			WithDropletGUID(droplet.GUID)
+		if task.MemoryInMB() > 0 {
+			create.WithMemoryInMB(task.MemoryInMB())
+		}
+		if task.DiskInMB() > 0 {
+			create.WithDiskInMB(task.DiskInMB())
+		}
+
+		started, err := cfClient.Tasks.Create(ctx, candidate.GUID, create)
+		if err != nil {
+			return fmt.Errorf("failed to start task '%s': %w", task.NameOrDefault(), err)
+		}
+		logger.Println(fmt.Sprintf("Started task '%s' with sequence id %d on droplet '%s' with %dMB memory and %dMB disk", started.Name, started.SequenceID, droplet.GUID, started.MemoryInMB, started.DiskInMB))
+
+		return p.waitForTask(ctx, cfClient, logger, candidate.GUID, started, task.TimeoutOrDefault())
+	}
+}
+
+// waitForTask prints the logs of the task while polling its state, and cancels the task if it has not finished within the timeout.
+func (p runTaskPlan) waitForTask(ctx context.Context, cfClient *cfclient.Client, logger *logger.CapturingWriter, appGUID string, task *resource.Task, timeout time.Duration) error {
+	logCacheURL, err := p.logCacheURL(ctx, cfClient)
+	if err != nil {
+		logger.Println(fmt.Sprintf("Unable to stream the logs of the task, run 'cf logs --recent' to see them: %s", err))
+	}
+
+	sourceType := "APP/TASK/" + task.Name
+	since := task.CreatedAt.Add(-time.Second).UnixNano()
+	streamLogs := func() {
+		if logCacheURL == "" {
+			return
+		}
+		lines, last, err := p.readLogs(ctx, cfClient, logCacheURL, appGUID, sourceType, since)
+		if err != nil {
+			logger.Println(fmt.Sprintf("Unable to stream the logs of the task, run 'cf logs --recent' to see them: %s", err))
+			logCacheURL = ""
+			return
+		}
+		for _, line := range lines {
+			logger.Println(line)
+		}
+		since = last
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		time.Sleep(p.checkInterval)
+		streamLogs()
+
+		current, err := cfClient.Tasks.Get(ctx, task.GUID)
+		if err != nil {
+			return err
+		}
+
+		switch current.State {
+		case "SUCCEEDED":
+			// Logs can arrive in the log cache a little after the task has finished.
+			time.Sleep(p.checkInterval)
+			streamLogs()
+			logger.Println(fmt.Sprintf("Task '%s' succeeded", current.Name))
+			return nil
+		case "FAILED":
+			time.Sleep(p.checkInterval)
+			streamLogs()
+			reason := "unknown reason"
+			if current.Result.FailureReason != nil {
+				reason = *current.Result.FailureReason
+			}
+			return fmt.Errorf("task '%s' failed: %s", current.Name, reason)
+		}
+
+		if time.Now().After(deadline) {
+			if _, err := cfClient.Tasks.Cancel(ctx, task.GUID); err != nil {
+				logger.Println(fmt.Sprintf("Failed to cancel task '%s': %s", current.Name, err))
+			}
+			return fmt.Errorf("task '%s' did not finish within %s and has been cancelled", current.Name, timeout)
+		}
+	}
+}
+
+func (p runTaskPlan) logCacheURL(ctx context.Context, cfClient *cfclient.Client) (string, error) {
+	root, err := cfClient.Root.Get(ctx)
+	if err != nil {
+		return "", err
+	}
+	if root.Links.LogCache.Href == "" {
+		return "", errors.New("the CF API does not have a log cache endpoint")
+	}
+	return strings.TrimSuffix(root.Links.LogCache.Href, "/"), nil
+}
+
+// readLogs returns the log lines of the task after since, and the timestamp of the last line to read from next time.
+func (p runTaskPlan) readLogs(ctx context.Context, cfClient *cfclient.Client, logCacheURL string, appGUID string, sourceType string, since int64) (lines []string, last int64, err error) {
+	query := url.Values{}
+	query.Set("start_time", strconv.FormatInt(since+1, 10))
+	query.Set("envelope_types", "LOG")
+	query.Set("limit", "1000")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/api/v1/read/%s?%s", logCacheURL, appGUID, query.Encode()), nil)
+	if err != nil {
+		return
+	}
+
+	resp, err := cfClient.ExecuteAuthRequest(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	return p.taskLogs(body, sourceType, since)
+}
+
+// taskLogs picks the lines of the task out of the logs of the app, which also contains the logs of the processes and of other tasks.
+func (p runTaskPlan) taskLogs(body []byte, sourceType string, since int64) (lines []string, last int64, err error) {
+	var envelopes logCacheEnvelopes
+	if err = json.Unmarshal(body, &envelopes); err != nil {
+		return
+	}
+
+	type taskLog struct {
+		timestamp int64
+		line      string
+	}
+	var logs []taskLog
+
+	last = since
+	for _, envelope := range envelopes.Envelopes.Batch {
+		timestamp, e := strconv.ParseInt(envelope.Timestamp, 10, 64)
+		if e != nil {
+			err = e
+			return
+		}
+		if timestamp > last {
+			last = timestamp
+		}
+		if envelope.Log == nil || envelope.Tags["source_type"] != sourceType {
+			continue
+		}
+		payload, e := base64.StdEncoding.DecodeString(envelope.Log.Payload)
+		if e != nil {
+			err = e
+			return
+		}
+		logs = append(logs, taskLog{timestamp: timestamp, line: strings.TrimRight(string(payload), "\n")})
+	}
+
+	sort.SliceStable(logs, func(i, j int) bool {
+		return logs[i].timestamp < logs[j].timestamp
+	})
+	for _, log := range logs {
+		lines = append(lines, log.line)
+	}
+	return
+}
+
+func NewRunTaskPlan() RunTaskPlan {
+	return runTaskPlan{
+		checkInterval: 2 * time.Second,
+	}
+}
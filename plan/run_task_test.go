@@ -0,0 +1,59 @@
+package plan
+
+import (
+	"encoding/base64"
+	"fmt"
+	halfpipe_deploy_resource "github.com/springernature/halfpipe-deploy-resource"
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRunTaskPlan(t *testing.T) {
+	man := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp`).Applications[0]
+
+	r := validRequest
+	r.Params.Task = config.Task{Command: "bin/migrate up", Name: "migrate"}
+
+	p := NewRunTaskPlan().Plan(man, r)
+
+	assert.Len(t, p, 1)
+	assert.Equal(t, "Running task 'migrate' with command 'bin/migrate up' on 'myApp-CANDIDATE'", p[0].String())
+}
+
+func TestTaskLogs(t *testing.T) {
+	envelope := func(timestamp string, sourceType string, line string) string {
+		return fmt.Sprintf(`{"timestamp":"%s","source_id":"guid","tags":{"source_type":"%s"},"log":{"payload":"%s","type":"OUT"}}`,
+			timestamp, sourceType, base64.StdEncoding.EncodeToString([]byte(line)))
+	}
+
+	body := fmt.Sprintf(`{"envelopes":{"batch":[%s,%s,%s,%s]}}`,
+		envelope("1700000000000000300", "APP/TASK/migrate", "done\n"),
+		envelope("1700000000000000100", "APP/TASK/migrate", "migrating"),
+		envelope("1700000000000000200", "APP/PROC/WEB", "GET /health"),
+		envelope("1700000000000000400", "APP/TASK/other", "not mine"),
+	)
+
+	t.Run("picks the lines of the task in order", func(t *testing.T) {
+		lines, last, err := runTaskPlan{}.taskLogs([]byte(body), "APP/TASK/migrate", 1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"migrating", "done"}, lines)
+		assert.Equal(t, int64(1700000000000000400), last)
+	})
+
+	t.Run("no logs yet", func(t *testing.T) {
+		lines, last, err := runTaskPlan{}.taskLogs([]byte(`{"envelopes":{"batch":[]}}`), "APP/TASK/migrate", 42)
+
+		assert.NoError(t, err)
+		assert.Empty(t, lines)
+		assert.Equal(t, int64(42), last)
+	})
+
+	t.Run("invalid response", func(t *testing.T) {
+		_, _, err := runTaskPlan{}.taskLogs([]byte(`<html>`), "APP/TASK/migrate", 42)
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,291 @@
+package plan
+
+import (
+	"code.cloudfoundry.org/cli/util/manifestparser"
+	"context"
+	"errors"
+	"fmt"
+	cfclient "github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/springernature/halfpipe-deploy-resource/logger"
+	"strings"
+	"time"
+)
+
+type ScalePlan interface {
+	Plan(manifest manifestparser.Application, request config.Request) (pl Plan)
+}
+
+type scalePlan struct {
+	restartPlan restartPlan
+}
+
+// processChange is the scale of a process of the live app before and after halfpipe-scale.
+type processChange struct {
+	process    *resource.Process
+	instances  int
+	memoryInMB int
+	diskInMB   int
+}
+
+// needsRestart is true when memory or disk change, as running instances keep what they were started with.
+func (c processChange) needsRestart() bool {
+	return c.memoryInMB != c.process.MemoryInMB || c.diskInMB != c.process.DiskInMB
+}
+
+func (p scalePlan) Plan(manifest manifestparser.Application, request config.Request) (pl Plan) {
+	var scales []string
+	for _, scale := range request.Params.Scale {
+		scales = append(scales, scale.String())
+	}
+	desc := fmt.Sprintf("Scaling '%s': %s", manifest.Name, strings.Join(scales, ", "))
+	pl = append(pl, NewClientCommand(p.createFunc(manifest, request), desc))
+
+	checkDesc := fmt.Sprintf("Checking that all instances of '%s' are running", manifest.Name)
+	pl = append(pl, NewClientCommand(checkPlan{}.createFunc(manifest.Name, request.Source.Org, request.Source.Space), checkDesc))
+	return
+}
+
+func (p scalePlan) createFunc(manifest manifestparser.Application, request config.Request) func(*cfclient.Client, *logger.CapturingWriter) error {
+	return func(cfClient *cfclient.Client, logger *logger.CapturingWriter) error {
+		ctx := context.Background()
+		org, space, err := getOrgAndSpace(ctx, cfClient, request.Source.Org, request.Source.Space)
+		if err != nil {
+			return err
+		}
+
+		apps, err := getAppsInOrgSpace(ctx, cfClient, request.Source.Org, request.Source.Space)
+		if err != nil {
+			return err
+		}
+		live := findApp(manifest.Name, apps)
+		if live == nil {
+			return fmt.Errorf("app '%s' not found", manifest.Name)
+		}
+
+		processes, err := cfClient.Processes.ListForAppAll(ctx, live.GUID, nil)
+		if err != nil {
+			return err
+		}
+
+		changes, err := p.changes(request.Params.Scale, processes, live.Name)
+		if err != nil {
+			return err
+		}
+
+		if err := p.checkQuota(ctx, cfClient, logger, org, space, changes); err != nil {
+			return err
+		}
+
+		for _, change := range changes {
+			logger.Println(fmt.Sprintf("Scaling process '%s' to %d instances with %dMB memory and %dMB disk, was %d instances with %dMB memory and %dMB disk",
+				change.process.Type,
+				change.instances, change.memoryInMB, change.diskInMB,
+				change.process.Instances, change.process.MemoryInMB, change.process.DiskInMB))
+
+			scale := resource.NewProcessScale().
+				WithInstances(change.instances).
+				WithMemoryInMB(change.memoryInMB).
+				WithDiskInMB(change.diskInMB)
+			if _, err := cfClient.Processes.Scale(ctx, change.process.GUID, scale); err != nil {
+				return err
+			}
+		}
+
+		if err := p.restart(ctx, cfClient, logger, live, changes); err != nil {
+			return err
+		}
+
+		if _, err := cfClient.Applications.Update(ctx, live.GUID, &resource.AppUpdate{Name: live.Name, Metadata: p.annotations(request, time.Now())}); err != nil {
+			return fmt.Errorf("failed to record the scale in the annotations of '%s': %w", live.Name, err)
+		}
+
+		logger.Println("OK")
+		return nil
+	}
+}
+
+// restart makes the instances pick up changed memory or disk. A rolling deployment only replaces the web
+// instances, so the instances of the other processes are restarted one by one.
+func (p scalePlan) restart(ctx context.Context, cfClient *cfclient.Client, logger *logger.CapturingWriter, live *resource.App, changes []processChange) error {
+	var restarts []processChange
+	for _, change := range changes {
+		if change.needsRestart() && change.instances > 0 {
+			restarts = append(restarts, change)
+		}
+	}
+	if len(restarts) == 0 {
+		return nil
+	}
+
+	if live.State != "STARTED" {
+		logger.Println(fmt.Sprintf("Memory or disk changed, '%s' is %s so it picks them up when it is started", live.Name, live.State))
+		return nil
+	}
+
+	for _, change := range restarts {
+		if change.process.Type == "web" {
+			logger.Println("Memory or disk of process 'web' changed, restarting it with a rolling deployment")
+			if err := p.restartPlan.deploy(ctx, cfClient, logger, live, ""); err != nil {
+				return err
+			}
+			continue
+		}
+
+		logger.Println(fmt.Sprintf("Memory or disk of process '%s' changed, restarting its instances one by one", change.process.Type))
+		for index := 0; index < change.instances; index++ {
+			if err := p.restartInstance(ctx, cfClient, logger, change.process, index); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// restartInstance terminates an instance of a process and waits until CF has replaced it with a running one,
+// like checkPlan it relies on the timeout of the plan.
+func (p scalePlan) restartInstance(ctx context.Context, cfClient *cfclient.Client, logger *logger.CapturingWriter, process *resource.Process, index int) error {
+	terminated := time.Now()
+	if err := cfClient.Processes.Terminate(ctx, process.GUID, index); err != nil {
+		return fmt.Errorf("failed to restart instance %d of process '%s': %w", index, process.Type, err)
+	}
+
+	for {
+		stats, err := cfClient.Processes.GetStats(ctx, process.GUID)
+		if err != nil {
+			return err
+		}
+		for _, stat := range stats.Stats {
+			if stat.Index == index && stat.State == "RUNNING" && time.Duration(stat.Uptime)*time.Second <= time.Since(terminated) {
+				logger.Println(fmt.Sprintf("Instance %d of process '%s' is running", index, process.Type))
+				return nil
+			}
+		}
+		time.Sleep(p.restartPlan.checkInterval)
+	}
+}
+
+// changes applies the requested scale to the current processes, unset values keep what the process has now.
+func (p scalePlan) changes(scales []config.ProcessScale, processes []*resource.Process, appName string) (changes []processChange, err error) {
+	for _, scale := range scales {
+		var process *resource.Process
+		for _, proc := range processes {
+			if proc.Type == scale.TypeOrDefault() {
+				process = proc
+			}
+		}
+		if process == nil {
+			return nil, fmt.Errorf("process type '%s' does not exist in '%s'", scale.TypeOrDefault(), appName)
+		}
+
+		change := processChange{process: process, instances: process.Instances, memoryInMB: process.MemoryInMB, diskInMB: process.DiskInMB}
+		if scale.Instances != nil {
+			change.instances = *scale.Instances
+		}
+		if scale.Memory != "" {
+			change.memoryInMB = scale.MemoryInMB()
+		}
+		if scale.Disk != "" {
+			change.diskInMB = scale.DiskInMB()
+		}
+		changes = append(changes, change)
+	}
+	return
+}
+
+// needed is the extra memory and instances the changes use. A rolling deployment starts a new web instance
+// before it stops an old one, so it needs room for one more web instance while it runs. The instances of the
+// other processes are restarted in place and need no extra room.
+func (p scalePlan) needed(changes []processChange) (needed quotaUsage, largestProcess int) {
+	var web *processChange
+	for i, change := range changes {
+		needed.MemoryInMB += change.instances*change.memoryInMB - change.process.Instances*change.process.MemoryInMB
+		needed.Instances += change.instances - change.process.Instances
+		if change.memoryInMB > largestProcess {
+			largestProcess = change.memoryInMB
+		}
+		if change.process.Type == "web" {
+			web = &changes[i]
+		}
+	}
+
+	needed.MemoryInMB = max(needed.MemoryInMB, 0)
+	needed.Instances = max(needed.Instances, 0)
+	if web != nil && web.needsRestart() {
+		needed.MemoryInMB += web.memoryInMB
+		needed.Instances++
+	}
+	return
+}
+
+func (p scalePlan) checkQuota(ctx context.Context, cfClient *cfclient.Client, logger *logger.CapturingWriter, org *resource.Organization, space *resource.Space, changes []processChange) error {
+	needed, largestProcess := p.needed(changes)
+	if needed.MemoryInMB == 0 && needed.Instances == 0 {
+		return nil
+	}
+
+	quotas := quotaPlan{}
+	var exceeded []string
+
+	orgQuota, err := quotas.orgQuota(ctx, cfClient, org)
+	if err != nil {
+		return err
+	}
+	orgQuota.consumer = "scaling"
+	orgSummary, err := cfClient.Organizations.GetUsageSummary(ctx, org.GUID)
+	if err != nil {
+		return err
+	}
+	orgLines, orgExceeded := orgQuota.check(toQuotaUsage(orgSummary.UsageSummary), needed, largestProcess)
+	logger.Println(strings.Join(orgLines, "\n"))
+	exceeded = append(exceeded, orgExceeded...)
+
+	spaceQuota, err := quotas.spaceQuota(ctx, cfClient, space)
+	if err != nil {
+		return err
+	}
+	if spaceQuota != nil {
+		spaceQuota.consumer = "scaling"
+		spaceSummary, err := cfClient.Spaces.GetUsageSummary(ctx, space.GUID)
+		if err != nil {
+			return err
+		}
+		spaceLines, spaceExceeded := spaceQuota.check(toQuotaUsage(spaceSummary.UsageSummary), needed, largestProcess)
+		logger.Println(strings.Join(spaceLines, "\n"))
+		exceeded = append(exceeded, spaceExceeded...)
+	}
+
+	if len(exceeded) > 0 {
+		return errors.New(fmt.Sprintf("scaling does not fit in the quota:\n%s", strings.Join(exceeded, "\n")))
+	}
+	return nil
+}
+
+// annotations record who scaled the app and to what, as 'cf scale' outside the pipeline leaves no trace.
+func (p scalePlan) annotations(request config.Request, now time.Time) *resource.Metadata {
+	var scales []string
+	for _, scale := range request.Params.Scale {
+		scales = append(scales, scale.String())
+	}
+
+	scaledBy := request.Metadata.DeployedBy
+	if scaledBy == "" {
+		scaledBy = request.Source.Username
+	}
+
+	metadata := resource.NewMetadata().
+		WithAnnotation(annotationPrefix, "scaled-by", scaledBy).
+		WithAnnotation(annotationPrefix, "scaled-at", now.UTC().Format(time.RFC3339)).
+		WithAnnotation(annotationPrefix, "scale", strings.Join(scales, ", "))
+	if request.Metadata.Pipeline != "" {
+		metadata.SetAnnotation(annotationPrefix, "scaled-by-pipeline", request.Metadata.Pipeline)
+	}
+	return metadata
+}
+
+func NewScalePlan() ScalePlan {
+	return scalePlan{
+		restartPlan: newRestartPlan(false),
+	}
+}
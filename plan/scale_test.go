@@ -0,0 +1,108 @@
+package plan
+
+import (
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	halfpipe_deploy_resource "github.com/springernature/halfpipe-deploy-resource"
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestScalePlan(t *testing.T) {
+	man := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp`).Applications[0]
+
+	r := validRequest
+	r.Params.Scale = []config.ProcessScale{
+		{Instances: intPtr(3)},
+		{Type: "worker", Memory: "1G", Disk: "2G"},
+	}
+
+	p := NewScalePlan().Plan(man, r)
+
+	assert.Len(t, p, 2)
+	assert.Equal(t, "Scaling 'myApp': web to 3 instances, worker with 1024MB memory with 2048MB disk", p[0].String())
+	assert.Equal(t, "Checking that all instances of 'myApp' are running", p[1].String())
+}
+
+func TestScaleChanges(t *testing.T) {
+	web := &resource.Process{Type: "web", Instances: 2, MemoryInMB: 512, DiskInMB: 1024}
+	worker := &resource.Process{Type: "worker", Instances: 1, MemoryInMB: 256, DiskInMB: 1024}
+	processes := []*resource.Process{web, worker}
+
+	t.Run("instances only", func(t *testing.T) {
+		changes, err := scalePlan{}.changes([]config.ProcessScale{{Instances: intPtr(4)}}, processes, "myApp")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []processChange{{process: web, instances: 4, memoryInMB: 512, diskInMB: 1024}}, changes)
+		assert.False(t, changes[0].needsRestart())
+
+		needed, largest := scalePlan{}.needed(changes)
+		assert.Equal(t, quotaUsage{MemoryInMB: 1024, Instances: 2}, needed)
+		assert.Equal(t, 512, largest)
+	})
+
+	t.Run("memory needs a rolling deployment", func(t *testing.T) {
+		changes, err := scalePlan{}.changes([]config.ProcessScale{{Memory: "1G"}, {Type: "worker", Instances: intPtr(0)}}, processes, "myApp")
+
+		assert.NoError(t, err)
+		assert.True(t, changes[0].needsRestart())
+		assert.False(t, changes[1].needsRestart())
+
+		// 2x512MB more for web, 256MB less for the worker, and one extra web instance during the deployment.
+		needed, largest := scalePlan{}.needed(changes)
+		assert.Equal(t, quotaUsage{MemoryInMB: 1024 - 256 + 1024, Instances: 1}, needed)
+		assert.Equal(t, 1024, largest)
+	})
+
+	t.Run("memory of another process restarts it in place", func(t *testing.T) {
+		changes, err := scalePlan{}.changes([]config.ProcessScale{{Type: "worker", Memory: "1G"}}, processes, "myApp")
+
+		assert.NoError(t, err)
+		assert.True(t, changes[0].needsRestart())
+
+		// 768MB more for the worker, no extra web instance as there is no rolling deployment.
+		needed, largest := scalePlan{}.needed(changes)
+		assert.Equal(t, quotaUsage{MemoryInMB: 1024 - 256}, needed)
+		assert.Equal(t, 1024, largest)
+	})
+
+	t.Run("scaling down needs nothing", func(t *testing.T) {
+		changes, err := scalePlan{}.changes([]config.ProcessScale{{Instances: intPtr(1)}}, processes, "myApp")
+
+		assert.NoError(t, err)
+		needed, _ := scalePlan{}.needed(changes)
+		assert.Equal(t, quotaUsage{}, needed)
+	})
+
+	t.Run("unknown process type", func(t *testing.T) {
+		_, err := scalePlan{}.changes([]config.ProcessScale{{Type: "clock", Instances: intPtr(1)}}, processes, "myApp")
+
+		assert.EqualError(t, err, "process type 'clock' does not exist in 'myApp'")
+	})
+}
+
+func TestScaleAnnotations(t *testing.T) {
+	r := validRequest
+	r.Params.Scale = []config.ProcessScale{{Instances: intPtr(3)}}
+	r.Metadata.DeployedBy = "someone@example.com"
+	r.Metadata.Pipeline = "team/pipeline"
+
+	metadata := scalePlan{}.annotations(r, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+
+	value := func(key string) string {
+		return *metadata.Annotations["halfpipe.io/"+key]
+	}
+	assert.Len(t, metadata.Annotations, 4)
+	assert.Equal(t, "someone@example.com", value("scaled-by"))
+	assert.Equal(t, "2024-01-02T03:04:05Z", value("scaled-at"))
+	assert.Equal(t, "web to 3 instances", value("scale"))
+	assert.Equal(t, "team/pipeline", value("scaled-by-pipeline"))
+
+	r.Metadata.DeployedBy = ""
+	r.Metadata.Pipeline = ""
+	metadata = scalePlan{}.annotations(r, time.Now())
+	assert.Len(t, metadata.Annotations, 3)
+	assert.Equal(t, "d", value("scaled-by"))
+}
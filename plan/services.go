@@ -0,0 +1,218 @@
+package plan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	cfclient "github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/springernature/halfpipe-deploy-resource/logger"
+)
+
+type ServicesPlan interface {
+	Plan(services []config.Service, org, space string) (pl Plan)
+}
+
+type servicesPlan struct {
+	pollingOptions *cfclient.PollingOptions
+}
+
+func (p servicesPlan) Plan(services []config.Service, org, space string) (pl Plan) {
+	for _, service := range services {
+		var desc string
+		if service.IsUserProvided() {
+			desc = fmt.Sprintf("Ensuring user-provided service instance '%s'", service.Name)
+		} else {
+			desc = fmt.Sprintf("Ensuring managed service instance '%s' with offering '%s' and plan '%s'", service.Name, service.Offering, service.Plan)
+		}
+		pl = append(pl, NewClientCommand(p.createFunc(service, org, space), desc))
+	}
+	return
+}
+
+func (p servicesPlan) createFunc(service config.Service, org, space string) func(*cfclient.Client, *logger.CapturingWriter) error {
+	return func(cfClient *cfclient.Client, logger *logger.CapturingWriter) error {
+		ctx := context.Background()
+		_, s, err := getOrgAndSpace(ctx, cfClient, org, space)
+		if err != nil {
+			return err
+		}
+
+		opts := cfclient.NewServiceInstanceListOptions()
+		opts.Names = cfclient.Filter{Values: []string{service.Name}}
+		opts.SpaceGUIDs = cfclient.Filter{Values: []string{s.GUID}}
+		existing, err := cfClient.ServiceInstances.ListAll(ctx, opts)
+		if err != nil {
+			return err
+		}
+
+		var instance *resource.ServiceInstance
+		if len(existing) > 0 {
+			instance = existing[0]
+			if instance.Type != p.serviceType(service) {
+				return fmt.Errorf("service instance '%s' already exists as a %s service instance", service.Name, instance.Type)
+			}
+		}
+
+		if service.IsUserProvided() {
+			return p.ensureUserProvided(ctx, cfClient, logger, service, s.GUID, instance)
+		}
+		return p.ensureManaged(ctx, cfClient, logger, service, s.GUID, instance)
+	}
+}
+
+func (p servicesPlan) serviceType(service config.Service) string {
+	if service.IsUserProvided() {
+		return "user-provided"
+	}
+	return "managed"
+}
+
+// tags makes sure we send an empty list rather than null when updating, so tags removed from the config are removed from the instance.
+func (p servicesPlan) tags(service config.Service) []string {
+	if service.Tags == nil {
+		return []string{}
+	}
+	return service.Tags
+}
+
+func (p servicesPlan) ensureUserProvided(ctx context.Context, cfClient *cfclient.Client, logger *logger.CapturingWriter, service config.Service, spaceGUID string, instance *resource.ServiceInstance) error {
+	var credentials json.RawMessage
+	if service.Credentials != nil {
+		c, err := json.Marshal(service.Credentials)
+		if err != nil {
+			return err
+		}
+		credentials = c
+	}
+
+	if instance == nil {
+		logger.Println(fmt.Sprintf("Creating user-provided service instance '%s'", service.Name))
+		create := resource.NewServiceInstanceCreateUserProvided(service.Name, spaceGUID).
+			WithTags(service.Tags)
+		if credentials != nil {
+			create = create.WithCredentials(credentials)
+		}
+		if service.RouteServiceURL != "" {
+			create = create.WithRouteServiceURL(service.RouteServiceURL)
+		}
+		if service.SyslogDrainURL != "" {
+			create = create.WithSyslogDrainURL(service.SyslogDrainURL)
+		}
+		_, err := cfClient.ServiceInstances.CreateUserProvided(ctx, create)
+		return err
+	}
+
+	logger.Println(fmt.Sprintf("Updating user-provided service instance '%s'", service.Name))
+	update := resource.NewServiceInstanceUserProvidedUpdate().
+		WithTags(p.tags(service))
+	if service.RouteServiceURL != "" {
+		update = update.WithRouteServiceURL(service.RouteServiceURL)
+	}
+	if service.SyslogDrainURL != "" {
+		update = update.WithSyslogDrainURL(service.SyslogDrainURL)
+	}
+	if credentials != nil {
+		update = update.WithCredentials(credentials)
+	}
+	_, err := cfClient.ServiceInstances.UpdateUserProvided(ctx, instance.GUID, update)
+	return err
+}
+
+func (p servicesPlan) ensureManaged(ctx context.Context, cfClient *cfclient.Client, logger *logger.CapturingWriter, service config.Service, spaceGUID string, instance *resource.ServiceInstance) error {
+	planOpts := cfclient.NewServicePlanListOptions()
+	planOpts.Names = cfclient.Filter{Values: []string{service.Plan}}
+	planOpts.ServiceOfferingNames = cfclient.Filter{Values: []string{service.Offering}}
+	planOpts.SpaceGUIDs = cfclient.Filter{Values: []string{spaceGUID}}
+	plans, err := cfClient.ServicePlans.ListAll(ctx, planOpts)
+	if err != nil {
+		return err
+	}
+	if len(plans) != 1 {
+		return fmt.Errorf("failed to find plan '%s' for service offering '%s', found %d matching plans", service.Plan, service.Offering, len(plans))
+	}
+	planGUID := plans[0].GUID
+
+	var parameters json.RawMessage
+	if service.Parameters != nil {
+		if parameters, err = json.Marshal(service.Parameters); err != nil {
+			return err
+		}
+	}
+
+	var jobGUID string
+	var serviceInstanceGUID string
+	if instance == nil {
+		logger.Println(fmt.Sprintf("Creating managed service instance '%s'", service.Name))
+		create := resource.NewServiceInstanceCreateManaged(service.Name, spaceGUID, planGUID).
+			WithTags(service.Tags)
+		if parameters != nil {
+			create = create.WithParameters(parameters)
+		}
+		jobGUID, err = cfClient.ServiceInstances.CreateManaged(ctx, create)
+		if err != nil {
+			return err
+		}
+	} else {
+		logger.Println(fmt.Sprintf("Updating managed service instance '%s'", service.Name))
+		serviceInstanceGUID = instance.GUID
+		update := resource.NewServiceInstanceManagedUpdate().
+			WithTags(p.tags(service))
+		if instance.Relationships.ServicePlan == nil || instance.Relationships.ServicePlan.Data == nil || instance.Relationships.ServicePlan.Data.GUID != planGUID {
+			update = update.WithServicePlan(planGUID)
+		}
+		if parameters != nil {
+			update = update.WithParameters(parameters)
+		}
+		jobGUID, _, err = cfClient.ServiceInstances.UpdateManaged(ctx, instance.GUID, update)
+		if err != nil {
+			return err
+		}
+	}
+
+	if jobGUID == "" {
+		logger.Println("OK")
+		return nil
+	}
+
+	logger.Println(fmt.Sprintf("Waiting for the service broker to finish with '%s'", service.Name))
+	if err = cfClient.Jobs.PollComplete(ctx, jobGUID, p.pollingOptions); err != nil {
+		return p.brokerError(ctx, cfClient, service, spaceGUID, serviceInstanceGUID, err)
+	}
+	logger.Println("OK")
+	return nil
+}
+
+// brokerError tries to find the message the service broker gave in the last operation of the
+// service instance, as that is much more useful than the generic error from the job.
+func (p servicesPlan) brokerError(ctx context.Context, cfClient *cfclient.Client, service config.Service, spaceGUID, serviceInstanceGUID string, jobErr error) error {
+	var instance *resource.ServiceInstance
+	if serviceInstanceGUID != "" {
+		instance, _ = cfClient.ServiceInstances.Get(ctx, serviceInstanceGUID)
+	} else {
+		opts := cfclient.NewServiceInstanceListOptions()
+		opts.Names = cfclient.Filter{Values: []string{service.Name}}
+		opts.SpaceGUIDs = cfclient.Filter{Values: []string{spaceGUID}}
+		if instances, err := cfClient.ServiceInstances.ListAll(ctx, opts); err == nil && len(instances) == 1 {
+			instance = instances[0]
+		}
+	}
+
+	if instance != nil && instance.LastOperation.Description != "" {
+		return fmt.Errorf("service broker failed to %s service instance '%s': %s", instance.LastOperation.Type, service.Name, instance.LastOperation.Description)
+	}
+	return fmt.Errorf("failed to provision service instance '%s': %w", service.Name, jobErr)
+}
+
+func NewServicesPlan() ServicesPlan {
+	return servicesPlan{
+		pollingOptions: &cfclient.PollingOptions{
+			FailedState:   string(resource.JobStateFailed),
+			Timeout:       time.Hour,
+			CheckInterval: 5 * time.Second,
+		},
+	}
+}
@@ -0,0 +1,92 @@
+package plan_test
+
+import (
+	"testing"
+
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/springernature/halfpipe-deploy-resource/plan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndToEndServices(t *testing.T) {
+	ensure := func(e *endToEnd, services ...config.Service) error {
+		return e.execute(plan.NewServicesPlanForTest().Plan(services, "my-org", "my-space"))
+	}
+
+	t.Run("creates a managed service instance and waits for the broker", func(t *testing.T) {
+		e := newEndToEnd(t, endToEndManifest)
+		e.server.AddServicePlan("postgres", "small")
+
+		require.NoError(t, ensure(e, config.Service{Name: "my-db", Offering: "postgres", Plan: "small", Parameters: map[string]any{"size": 10}, Tags: []string{"db"}}))
+
+		instance := e.server.ServiceInstance("my-db")
+		require.NotNil(t, instance)
+		assert.Equal(t, "managed", instance.Type)
+		assert.Equal(t, []string{"db"}, instance.Tags)
+		assert.Equal(t, "create", instance.LastOperation.Type)
+		assert.Equal(t, "succeeded", instance.LastOperation.State, "the job of the broker has been polled until it completed")
+		assert.Contains(t, e.output.String(), "Creating managed service instance 'my-db'")
+		assert.Contains(t, e.output.String(), "Waiting for the service broker to finish with 'my-db'")
+	})
+
+	t.Run("creates a user-provided service instance", func(t *testing.T) {
+		e := newEndToEnd(t, endToEndManifest)
+
+		require.NoError(t, ensure(e, config.Service{Name: "my-ups", Credentials: map[string]any{"password": "secret"}, SyslogDrainURL: "syslog://logs.com"}))
+
+		instance := e.server.ServiceInstance("my-ups")
+		require.NotNil(t, instance)
+		assert.Equal(t, "user-provided", instance.Type)
+		assert.Equal(t, "syslog://logs.com", *instance.SyslogDrainURL)
+		assert.Contains(t, e.output.String(), "Creating user-provided service instance 'my-ups'")
+	})
+
+	t.Run("updates existing service instances", func(t *testing.T) {
+		e := newEndToEnd(t, endToEndManifest)
+		e.server.AddServicePlan("postgres", "small")
+		e.server.AddServicePlan("postgres", "large")
+		require.NoError(t, ensure(e,
+			config.Service{Name: "my-db", Offering: "postgres", Plan: "small", Tags: []string{"db"}},
+			config.Service{Name: "my-ups", SyslogDrainURL: "syslog://logs.com"},
+		))
+		small := e.server.ServiceInstance("my-db").Relationships.ServicePlan.Data.GUID
+		guids := []string{e.server.ServiceInstance("my-db").GUID, e.server.ServiceInstance("my-ups").GUID}
+
+		require.NoError(t, ensure(e,
+			config.Service{Name: "my-db", Offering: "postgres", Plan: "large"},
+			config.Service{Name: "my-ups", SyslogDrainURL: "syslog://other-logs.com"},
+		))
+
+		db := e.server.ServiceInstance("my-db")
+		assert.NotEqual(t, small, db.Relationships.ServicePlan.Data.GUID, "the plan has changed")
+		assert.Empty(t, db.Tags, "the tags that were removed from the config are removed")
+		assert.Equal(t, "update", db.LastOperation.Type)
+		assert.Equal(t, "succeeded", db.LastOperation.State)
+		ups := e.server.ServiceInstance("my-ups")
+		assert.Equal(t, "syslog://other-logs.com", *ups.SyslogDrainURL)
+		assert.Equal(t, guids, []string{db.GUID, ups.GUID}, "the service instances are updated, not recreated")
+		assert.Contains(t, e.output.String(), "Updating managed service instance 'my-db'")
+		assert.Contains(t, e.output.String(), "Updating user-provided service instance 'my-ups'")
+	})
+
+	t.Run("surfaces the error of the broker", func(t *testing.T) {
+		e := newEndToEnd(t, endToEndManifest)
+		e.server.AddServicePlan("postgres", "small")
+		e.server.FailBroker("my-db", "the quota of the org has been exceeded")
+
+		err := ensure(e, config.Service{Name: "my-db", Offering: "postgres", Plan: "small"})
+
+		assert.EqualError(t, err, "service broker failed to create service instance 'my-db': the quota of the org has been exceeded")
+	})
+
+	t.Run("refuses to turn a service instance into another type", func(t *testing.T) {
+		e := newEndToEnd(t, endToEndManifest)
+		e.server.AddServicePlan("postgres", "small")
+		require.NoError(t, ensure(e, config.Service{Name: "my-db", SyslogDrainURL: "syslog://logs.com"}))
+
+		err := ensure(e, config.Service{Name: "my-db", Offering: "postgres", Plan: "small"})
+
+		assert.EqualError(t, err, "service instance 'my-db' already exists as a user-provided service instance")
+	})
+}
@@ -0,0 +1,24 @@
+package plan
+
+import (
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestServicesPlan(t *testing.T) {
+	t.Run("no services", func(t *testing.T) {
+		assert.Empty(t, NewServicesPlan().Plan(nil, "org", "space"))
+	})
+
+	t.Run("managed and user-provided services", func(t *testing.T) {
+		p := NewServicesPlan().Plan([]config.Service{
+			{Name: "my-db", Offering: "postgres", Plan: "small"},
+			{Name: "my-ups", Credentials: map[string]any{"a": "b"}},
+		}, "org", "space")
+
+		assert.Len(t, p, 2)
+		assert.Equal(t, "Ensuring managed service instance 'my-db' with offering 'postgres' and plan 'small'", p[0].String())
+		assert.Equal(t, "Ensuring user-provided service instance 'my-ups'", p[1].String())
+	})
+}
@@ -0,0 +1,129 @@
+package plan
+
+import (
+	"code.cloudfoundry.org/cli/util/manifestparser"
+	"context"
+	"errors"
+	"fmt"
+	cfclient "github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/springernature/halfpipe-deploy-resource/logger"
+	"sort"
+	"strings"
+)
+
+type SetEnvPlan interface {
+	Plan(manifest manifestparser.Application, request config.Request) (pl Plan)
+}
+
+type setEnvPlan struct {
+	restartPlan restartPlan
+}
+
+func (p setEnvPlan) Plan(manifest manifestparser.Application, request config.Request) (pl Plan) {
+	var keys []string
+	for key := range request.Params.Vars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var changes []string
+	if len(keys) > 0 {
+		changes = append(changes, fmt.Sprintf("setting [%s]", strings.Join(keys, ", ")))
+	}
+	if len(request.Params.UnsetVars) > 0 {
+		changes = append(changes, fmt.Sprintf("unsetting [%s]", strings.Join(request.Params.UnsetVars, ", ")))
+	}
+
+	desc := fmt.Sprintf("Updating the env of '%s': %s", manifest.Name, strings.Join(changes, ", "))
+	pl = append(pl, NewClientCommand(p.createFunc(manifest, request), desc))
+
+	checkDesc := fmt.Sprintf("Checking that all instances of '%s' are running", manifest.Name)
+	pl = append(pl, NewClientCommand(checkPlan{}.createFunc(manifest.Name, request.Source.Org, request.Source.Space), checkDesc))
+	return
+}
+
+func (p setEnvPlan) createFunc(manifest manifestparser.Application, request config.Request) func(*cfclient.Client, *logger.CapturingWriter) error {
+	return func(cfClient *cfclient.Client, logger *logger.CapturingWriter) error {
+		if len(request.Params.Vars) == 0 && len(request.Params.UnsetVars) == 0 {
+			return errors.New("no env changes, set vars, unsetVars or varsPath")
+		}
+
+		ctx := context.Background()
+		apps, err := getAppsInOrgSpace(ctx, cfClient, request.Source.Org, request.Source.Space)
+		if err != nil {
+			return err
+		}
+		live := findApp(manifest.Name, apps)
+		if live == nil {
+			return fmt.Errorf("app '%s' not found", manifest.Name)
+		}
+
+		current, err := cfClient.Applications.GetEnvironmentVariables(ctx, live.GUID)
+		if err != nil {
+			return err
+		}
+
+		diff, changes := p.diff(current, request.Params.Vars, request.Params.UnsetVars)
+		logger.Println(strings.Join(diff, "\n"))
+		if len(changes) == 0 {
+			logger.Println(fmt.Sprintf("The env of '%s' is already up to date, nothing to restart", live.Name))
+			return nil
+		}
+
+		if _, err := cfClient.Applications.SetEnvironmentVariables(ctx, live.GUID, changes); err != nil {
+			return err
+		}
+
+		if live.State != "STARTED" {
+			logger.Println(fmt.Sprintf("'%s' is %s, it picks up the env when it is started", live.Name, live.State))
+			return nil
+		}
+
+		logger.Println("Restarting with a rolling deployment for the env to take effect")
+		return p.restartPlan.deploy(ctx, cfClient, logger, live, "")
+	}
+}
+
+// diff compares the user-provided env of the app with the changes and returns a line per key, without the values
+// as they are often secrets, and the changes to send to CF where a nil value unsets the key.
+func (p setEnvPlan) diff(current map[string]*string, set map[string]string, unset []string) (lines []string, changes map[string]*string) {
+	changes = make(map[string]*string)
+
+	var keys []string
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := set[key]
+		old, found := current[key]
+		switch {
+		case !found || old == nil:
+			lines = append(lines, fmt.Sprintf("+ %s (added)", key))
+		case *old != value:
+			lines = append(lines, fmt.Sprintf("~ %s (changed)", key))
+		default:
+			lines = append(lines, fmt.Sprintf("  %s (unchanged)", key))
+			continue
+		}
+		changes[key] = &value
+	}
+
+	for _, key := range unset {
+		if old, found := current[key]; !found || old == nil {
+			lines = append(lines, fmt.Sprintf("  %s (not set)", key))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("- %s (removed)", key))
+		changes[key] = nil
+	}
+	return
+}
+
+func NewSetEnvPlan() SetEnvPlan {
+	return setEnvPlan{
+		restartPlan: newRestartPlan(false),
+	}
+}
@@ -0,0 +1,58 @@
+package plan
+
+import (
+	halfpipe_deploy_resource "github.com/springernature/halfpipe-deploy-resource"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSetEnvPlan(t *testing.T) {
+	man := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp`).Applications[0]
+
+	r := validRequest
+	r.Params.Vars = map[string]string{"B": "b", "A": "a"}
+	r.Params.UnsetVars = []string{"C"}
+
+	p := NewSetEnvPlan().Plan(man, r)
+
+	assert.Len(t, p, 2)
+	assert.Equal(t, "Updating the env of 'myApp': setting [A, B], unsetting [C]", p[0].String())
+	assert.Equal(t, "Checking that all instances of 'myApp' are running", p[1].String())
+}
+
+func TestSetEnvDiff(t *testing.T) {
+	str := func(s string) *string {
+		return &s
+	}
+
+	current := map[string]*string{
+		"CHANGED":   str("old secret"),
+		"UNCHANGED": str("same"),
+		"REMOVED":   str("gone"),
+	}
+
+	lines, changes := setEnvPlan{}.diff(current,
+		map[string]string{"CHANGED": "new secret", "UNCHANGED": "same", "ADDED": "value"},
+		[]string{"REMOVED", "MISSING"})
+
+	assert.Equal(t, []string{
+		"+ ADDED (added)",
+		"~ CHANGED (changed)",
+		"  UNCHANGED (unchanged)",
+		"- REMOVED (removed)",
+		"  MISSING (not set)",
+	}, lines)
+	assert.Equal(t, map[string]*string{
+		"ADDED":   str("value"),
+		"CHANGED": str("new secret"),
+		"REMOVED": nil,
+	}, changes)
+
+	for _, line := range lines {
+		assert.NotContains(t, line, "secret")
+	}
+
+	_, changes = setEnvPlan{}.diff(current, map[string]string{"UNCHANGED": "same"}, nil)
+	assert.Empty(t, changes)
+}
@@ -1,43 +1,260 @@
 package plan
 
 import (
+	"context"
 	"fmt"
-	"strings"
+	"time"
+
+	cfclient "github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/springernature/halfpipe-deploy-resource/logger"
 )
 
 type SSOPlan interface {
-	Plan(ssoHost string) (pl Plan)
+	Plan(request config.Request) (pl Plan)
 }
 
 type ssoPlan struct {
+	pollingOptions *cfclient.PollingOptions
 }
 
-func (s ssoPlan) Plan(ssoHost string) (plan Plan) {
-	plan = append(plan, NewCompoundCommand(
-		NewCfCommand("service", "sso"),
-		NewCfCommand("create-user-provided-service", "sso", "-r", "https://ee-sso.public.springernature.app"),
-		func(log []byte) bool {
-			return strings.Contains(string(log), "Service instance 'sso' not found")
-		},
-		false,
-	))
+func (s ssoPlan) Plan(request config.Request) (plan Plan) {
+	sso := request.Params.SSOConfig()
+	org, space := request.Source.Org, request.Source.Space
 
-	//cf8 bind-route-service public.springernature.app -n $SSO_HOST sso;
+	if sso.Unbind {
+		for _, host := range sso.Hosts {
+			desc := fmt.Sprintf("Unbinding route service '%s' from route '%s'", sso.ServiceName, sso.Route(host))
+			plan = append(plan, NewClientCommand(s.unbindFunc(sso, host, org, space), desc))
+		}
+		return
+	}
 
-	plan = append(plan, NewCompoundCommand(
-		NewCfCommand("route", "public.springernature.app", "-n", ssoHost),
-		NewCfCommand("create-route", "public.springernature.app", "-n", ssoHost),
-		func(log []byte) bool {
-			return strings.Contains(string(log), fmt.Sprintf("Route with host '%s'", ssoHost))
-		},
-		false,
-	))
+	desc := fmt.Sprintf("Ensuring route service '%s' with url '%s'", sso.ServiceName, sso.RouteServiceURL)
+	plan = append(plan, NewClientCommand(s.ensureServiceFunc(sso, org, space), desc))
 
-	plan = append(plan, NewCfCommand("bind-route-service", "public.springernature.app", "-n", ssoHost, "sso"))
+	for _, host := range sso.Hosts {
+		desc := fmt.Sprintf("Binding route service '%s' to route '%s'", sso.ServiceName, sso.Route(host))
+		plan = append(plan, NewClientCommand(s.bindFunc(sso, host, org, space), desc))
+	}
 
 	return
 }
 
+func (s ssoPlan) ensureServiceFunc(sso config.SSOConfig, org, space string) func(*cfclient.Client, *logger.CapturingWriter) error {
+	return func(cfClient *cfclient.Client, logger *logger.CapturingWriter) error {
+		ctx := context.Background()
+		_, sp, err := getOrgAndSpace(ctx, cfClient, org, space)
+		if err != nil {
+			return err
+		}
+
+		instance, err := s.findServiceInstance(ctx, cfClient, sso, sp.GUID)
+		if err != nil {
+			return err
+		}
+
+		if instance == nil {
+			logger.Println(fmt.Sprintf("Creating user-provided service instance '%s'", sso.ServiceName))
+			create := resource.NewServiceInstanceCreateUserProvided(sso.ServiceName, sp.GUID).
+				WithRouteServiceURL(sso.RouteServiceURL)
+			_, err = cfClient.ServiceInstances.CreateUserProvided(ctx, create)
+			return err
+		}
+
+		if instance.Type != "user-provided" {
+			return fmt.Errorf("service instance '%s' already exists as a %s service instance, a route service must be user-provided", sso.ServiceName, instance.Type)
+		}
+
+		if instance.RouteServiceURL == nil || *instance.RouteServiceURL != sso.RouteServiceURL {
+			logger.Println(fmt.Sprintf("Updating the route service url of '%s' to '%s'", sso.ServiceName, sso.RouteServiceURL))
+			// The update replaces the syslog drain url and the tags, so they are sent as they are.
+			tags := instance.Tags
+			if tags == nil {
+				tags = []string{}
+			}
+			update := resource.NewServiceInstanceUserProvidedUpdate().
+				WithRouteServiceURL(sso.RouteServiceURL).
+				WithTags(tags)
+			if instance.SyslogDrainURL != nil {
+				update = update.WithSyslogDrainURL(*instance.SyslogDrainURL)
+			}
+			_, err = cfClient.ServiceInstances.UpdateUserProvided(ctx, instance.GUID, update)
+			return err
+		}
+
+		logger.Println(fmt.Sprintf("Service instance '%s' already exists", sso.ServiceName))
+		return nil
+	}
+}
+
+func (s ssoPlan) bindFunc(sso config.SSOConfig, host, org, space string) func(*cfclient.Client, *logger.CapturingWriter) error {
+	return func(cfClient *cfclient.Client, logger *logger.CapturingWriter) error {
+		ctx := context.Background()
+		_, sp, err := getOrgAndSpace(ctx, cfClient, org, space)
+		if err != nil {
+			return err
+		}
+
+		instance, err := s.findServiceInstance(ctx, cfClient, sso, sp.GUID)
+		if err != nil {
+			return err
+		}
+		if instance == nil {
+			return fmt.Errorf("service instance '%s' not found", sso.ServiceName)
+		}
+
+		route, err := s.findRoute(ctx, cfClient, sso, host, sp.GUID)
+		if err != nil {
+			return err
+		}
+
+		if route == nil {
+			domain, err := s.findDomain(ctx, cfClient, sso)
+			if err != nil {
+				return err
+			}
+			logger.Println(fmt.Sprintf("Creating route '%s'", sso.Route(host)))
+			create := resource.NewRouteCreate(domain.GUID, sp.GUID)
+			create.Host = &host
+			if sso.Path != "" {
+				create.Path = &sso.Path
+			}
+			route, err = cfClient.Routes.Create(ctx, create)
+			if err != nil {
+				return err
+			}
+		}
+
+		binding, err := s.findBinding(ctx, cfClient, route.GUID, instance.GUID)
+		if err != nil {
+			return err
+		}
+		if binding != nil {
+			logger.Println(fmt.Sprintf("Route '%s' is already bound to '%s'", sso.Route(host), sso.ServiceName))
+			return nil
+		}
+
+		logger.Println(fmt.Sprintf("Binding '%s' to route '%s'", sso.ServiceName, sso.Route(host)))
+		jobGUID, _, err := cfClient.ServiceRouteBindings.Create(ctx, resource.NewServiceRouteBindingCreate(route.GUID, instance.GUID))
+		if err != nil {
+			return err
+		}
+		return s.waitForJob(ctx, cfClient, jobGUID)
+	}
+}
+
+func (s ssoPlan) unbindFunc(sso config.SSOConfig, host, org, space string) func(*cfclient.Client, *logger.CapturingWriter) error {
+	return func(cfClient *cfclient.Client, logger *logger.CapturingWriter) error {
+		ctx := context.Background()
+		_, sp, err := getOrgAndSpace(ctx, cfClient, org, space)
+		if err != nil {
+			return err
+		}
+
+		instance, err := s.findServiceInstance(ctx, cfClient, sso, sp.GUID)
+		if err != nil {
+			return err
+		}
+		if instance == nil {
+			logger.Println(fmt.Sprintf("Service instance '%s' not found, nothing to unbind", sso.ServiceName))
+			return nil
+		}
+
+		route, err := s.findRoute(ctx, cfClient, sso, host, sp.GUID)
+		if err != nil {
+			return err
+		}
+		if route == nil {
+			logger.Println(fmt.Sprintf("Route '%s' not found, nothing to unbind", sso.Route(host)))
+			return nil
+		}
+
+		binding, err := s.findBinding(ctx, cfClient, route.GUID, instance.GUID)
+		if err != nil {
+			return err
+		}
+		if binding == nil {
+			logger.Println(fmt.Sprintf("Route '%s' is not bound to '%s'", sso.Route(host), sso.ServiceName))
+			return nil
+		}
+
+		logger.Println(fmt.Sprintf("Unbinding '%s' from route '%s'", sso.ServiceName, sso.Route(host)))
+		jobGUID, err := cfClient.ServiceRouteBindings.Delete(ctx, binding.GUID)
+		if err != nil {
+			return err
+		}
+		return s.waitForJob(ctx, cfClient, jobGUID)
+	}
+}
+
+func (s ssoPlan) waitForJob(ctx context.Context, cfClient *cfclient.Client, jobGUID string) error {
+	if jobGUID == "" {
+		return nil
+	}
+	return cfClient.Jobs.PollComplete(ctx, jobGUID, s.pollingOptions)
+}
+
+func (s ssoPlan) findServiceInstance(ctx context.Context, cfClient *cfclient.Client, sso config.SSOConfig, spaceGUID string) (*resource.ServiceInstance, error) {
+	opts := cfclient.NewServiceInstanceListOptions()
+	opts.Names = cfclient.Filter{Values: []string{sso.ServiceName}}
+	opts.SpaceGUIDs = cfclient.Filter{Values: []string{spaceGUID}}
+	instances, err := cfClient.ServiceInstances.ListAll(ctx, opts)
+	if err != nil || len(instances) == 0 {
+		return nil, err
+	}
+	return instances[0], nil
+}
+
+func (s ssoPlan) findDomain(ctx context.Context, cfClient *cfclient.Client, sso config.SSOConfig) (*resource.Domain, error) {
+	opts := cfclient.NewDomainListOptions()
+	opts.Names = cfclient.Filter{Values: []string{sso.Domain}}
+	domains, err := cfClient.Domains.ListAll(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(domains) == 0 {
+		return nil, fmt.Errorf("domain '%s' not found", sso.Domain)
+	}
+	return domains[0], nil
+}
+
+func (s ssoPlan) findRoute(ctx context.Context, cfClient *cfclient.Client, sso config.SSOConfig, host, spaceGUID string) (*resource.Route, error) {
+	domain, err := s.findDomain(ctx, cfClient, sso)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := cfclient.NewRouteListOptions()
+	opts.DomainGUIDs = cfclient.Filter{Values: []string{domain.GUID}}
+	opts.SpaceGUIDs = cfclient.Filter{Values: []string{spaceGUID}}
+	opts.Hosts = cfclient.Filter{Values: []string{host}}
+	opts.Paths = cfclient.Filter{Values: []string{sso.Path}}
+	routes, err := cfClient.Routes.ListAll(ctx, opts)
+	if err != nil || len(routes) == 0 {
+		return nil, err
+	}
+	return routes[0], nil
+}
+
+func (s ssoPlan) findBinding(ctx context.Context, cfClient *cfclient.Client, routeGUID, serviceInstanceGUID string) (*resource.ServiceRouteBinding, error) {
+	opts := cfclient.NewServiceRouteBindingListOptions()
+	opts.RouteGUIDs = cfclient.Filter{Values: []string{routeGUID}}
+	opts.ServiceInstanceGUIDs = cfclient.Filter{Values: []string{serviceInstanceGUID}}
+	bindings, err := cfClient.ServiceRouteBindings.ListAll(ctx, opts)
+	if err != nil || len(bindings) == 0 {
+		return nil, err
+	}
+	return bindings[0], nil
+}
+
 func NewSSOPlan() SSOPlan {
-	return ssoPlan{}
+	return ssoPlan{
+		pollingOptions: &cfclient.PollingOptions{
+			FailedState:   string(resource.JobStateFailed),
+			Timeout:       10 * time.Minute,
+			CheckInterval: 2 * time.Second,
+		},
+	}
 }
@@ -0,0 +1,66 @@
+package plan_test
+
+import (
+	"testing"
+
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/springernature/halfpipe-deploy-resource/plan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndToEndSSO(t *testing.T) {
+	sso := func(e *endToEnd, mode, routeServiceURL string) error {
+		r := e.request(config.SSO)
+		r.Params.SSOHost = "app1,app2"
+		r.Params.SSODomain = "domain.com"
+		r.Params.SSORouteServiceURL = routeServiceURL
+		r.Params.SSOMode = mode
+		return e.execute(plan.NewSSOPlanForTest().Plan(r))
+	}
+
+	t.Run("binds the route service to the routes", func(t *testing.T) {
+		e := newEndToEnd(t, endToEndManifest)
+
+		require.NoError(t, sso(e, config.SSOModeBind, "https://sso.com"))
+
+		instance := e.server.ServiceInstance("sso")
+		require.NotNil(t, instance)
+		assert.Equal(t, "https://sso.com", *instance.RouteServiceURL)
+		assert.Equal(t, []string{"app1.domain.com", "app2.domain.com"}, e.server.RoutesBoundTo("sso"))
+
+		require.NoError(t, sso(e, config.SSOModeBind, "https://sso.com"))
+		assert.Contains(t, e.output.String(), "Service instance 'sso' already exists")
+		assert.Contains(t, e.output.String(), "Route 'app1.domain.com' is already bound to 'sso'")
+		assert.Equal(t, []string{"app1.domain.com", "app2.domain.com"}, e.server.RoutesBoundTo("sso"))
+	})
+
+	t.Run("updates the url of an existing route service", func(t *testing.T) {
+		e := newEndToEnd(t, endToEndManifest)
+		require.NoError(t, sso(e, config.SSOModeBind, "https://sso.com"))
+		guid := e.server.ServiceInstance("sso").GUID
+
+		require.NoError(t, sso(e, config.SSOModeBind, "https://new-sso.com"))
+
+		instance := e.server.ServiceInstance("sso")
+		assert.Equal(t, guid, instance.GUID)
+		assert.Equal(t, "https://new-sso.com", *instance.RouteServiceURL)
+		assert.Contains(t, e.output.String(), "Updating the route service url of 'sso' to 'https://new-sso.com'")
+		assert.Equal(t, []string{"app1.domain.com", "app2.domain.com"}, e.server.RoutesBoundTo("sso"))
+	})
+
+	t.Run("unbinding is idempotent", func(t *testing.T) {
+		e := newEndToEnd(t, endToEndManifest)
+
+		require.NoError(t, sso(e, config.SSOModeUnbind, "https://sso.com"))
+		assert.Contains(t, e.output.String(), "Service instance 'sso' not found, nothing to unbind")
+
+		require.NoError(t, sso(e, config.SSOModeBind, "https://sso.com"))
+		require.NoError(t, sso(e, config.SSOModeUnbind, "https://sso.com"))
+		assert.Empty(t, e.server.RoutesBoundTo("sso"))
+
+		require.NoError(t, sso(e, config.SSOModeUnbind, "https://sso.com"))
+		assert.Contains(t, e.output.String(), "Route 'app1.domain.com' is not bound to 'sso'")
+		assert.Contains(t, e.output.String(), "Route 'app2.domain.com' is not bound to 'sso'")
+	})
+}
@@ -1 +1,50 @@
 package plan
+
+import (
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSSOPlan(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		r := validRequest
+		r.Params.SSOHost = "myHost"
+
+		p := NewSSOPlan().Plan(r)
+
+		assert.Len(t, p, 2)
+		assert.Equal(t, "Ensuring route service 'sso' with url 'https://ee-sso.public.springernature.app'", p[0].String())
+		assert.Equal(t, "Binding route service 'sso' to route 'myHost.public.springernature.app'", p[1].String())
+	})
+
+	t.Run("custom service, domain, path and multiple hosts", func(t *testing.T) {
+		r := validRequest
+		r.Params.SSOHost = "host1, host2"
+		r.Params.SSOHosts = []string{"host3"}
+		r.Params.SSOServiceName = "my-sso"
+		r.Params.SSORouteServiceURL = "https://my-sso.example.com"
+		r.Params.SSODomain = "example.com"
+		r.Params.SSOPath = "admin"
+
+		p := NewSSOPlan().Plan(r)
+
+		assert.Len(t, p, 4)
+		assert.Equal(t, "Ensuring route service 'my-sso' with url 'https://my-sso.example.com'", p[0].String())
+		assert.Equal(t, "Binding route service 'my-sso' to route 'host1.example.com/admin'", p[1].String())
+		assert.Equal(t, "Binding route service 'my-sso' to route 'host2.example.com/admin'", p[2].String())
+		assert.Equal(t, "Binding route service 'my-sso' to route 'host3.example.com/admin'", p[3].String())
+	})
+
+	t.Run("unbind", func(t *testing.T) {
+		r := validRequest
+		r.Params.SSOHost = "host1,host2"
+		r.Params.SSOMode = config.SSOModeUnbind
+
+		p := NewSSOPlan().Plan(r)
+
+		assert.Len(t, p, 2)
+		assert.Equal(t, "Unbinding route service 'sso' from route 'host1.public.springernature.app'", p[0].String())
+		assert.Equal(t, "Unbinding route service 'sso' from route 'host2.public.springernature.app'", p[1].String())
+	})
+}
@@ -0,0 +1,284 @@
+package plan
+
+import (
+	"code.cloudfoundry.org/cli/util/manifestparser"
+	"context"
+	"encoding/json"
+	"fmt"
+	cfclient "github.com/cloudfoundry/go-cfclient/v3/client"
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/springernature/halfpipe-deploy-resource/logger"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	roleLive      = "live"
+	roleCandidate = "candidate"
+	roleOld       = "old"
+	roleDelete    = "delete"
+)
+
+// appStatus is the state of one app in the family of an app, the app itself and its candidate, old and deleted versions.
+type appStatus struct {
+	Name             string     `json:"name"`
+	Role             string     `json:"role"`
+	State            string     `json:"state"`
+	RunningInstances int        `json:"runningInstances"`
+	Instances        int        `json:"instances"`
+	DropletGUID      string     `json:"dropletGuid"`
+	GitRevision      string     `json:"gitRevision"`
+	BuildVersion     string     `json:"buildVersion"`
+	Routes           []string   `json:"routes"`
+	CreatedAt        time.Time  `json:"createdAt"`
+	Age              string     `json:"age"`
+	Provenance       provenance `json:"provenance"`
+}
+
+type familyStatus struct {
+	App      string      `json:"app"`
+	Org      string      `json:"org"`
+	Space    string      `json:"space"`
+	Apps     []appStatus `json:"apps"`
+	Problems []string    `json:"problems"`
+}
+
+type StatusPlan interface {
+	Plan(manifest manifestparser.Application, request config.Request) (pl Plan)
+}
+
+type statusPlan struct{}
+
+func (p statusPlan) Plan(manifest manifestparser.Application, request config.Request) (pl Plan) {
+	desc := fmt.Sprintf("Reporting the state of '%s' and its candidate, old and deleted versions", manifest.Name)
+	pl = append(pl, NewClientCommand(p.createFunc(manifest, request), desc))
+	return
+}
+
+func (p statusPlan) createFunc(manifest manifestparser.Application, request config.Request) func(*cfclient.Client, *logger.CapturingWriter) error {
+	return func(cfClient *cfclient.Client, logger *logger.CapturingWriter) error {
+		ctx := context.Background()
+		apps, err := getAppsInOrgSpace(ctx, cfClient, request.Source.Org, request.Source.Space)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		status := familyStatus{App: manifest.Name, Org: request.Source.Org, Space: request.Source.Space, Apps: []appStatus{}}
+		for _, member := range p.family(manifest.Name, apps) {
+			s, err := p.appStatus(ctx, cfClient, member.app, member.role, now)
+			if err != nil {
+				return err
+			}
+			status.Apps = append(status.Apps, s)
+		}
+		status.Problems = p.problems(manifest, status.Apps)
+
+		if request.Params.StatusFormatOrDefault() == config.StatusFormatJSON {
+			out, err := json.MarshalIndent(status, "", "  ")
+			if err != nil {
+				return err
+			}
+			logger.Println(string(out))
+			return nil
+		}
+
+		logger.Println(p.text(status))
+		return nil
+	}
+}
+
+type familyMember struct {
+	app  *resource.App
+	role string
+}
+
+// family returns the apps that exist in the family, in the order they are promoted: candidate, live, old and deleted.
+func (p statusPlan) family(name string, apps []*resource.App) (members []familyMember) {
+	live, old, deletes := promotePlan{}.getPreviousAppState(name, apps)
+	if candidate := findApp(createCandidateAppName(name), apps); candidate != nil {
+		members = append(members, familyMember{app: candidate, role: roleCandidate})
+	}
+	if live != nil {
+		members = append(members, familyMember{app: live, role: roleLive})
+	}
+	if old != nil {
+		members = append(members, familyMember{app: old, role: roleOld})
+	}
+	for _, app := range deletes {
+		members = append(members, familyMember{app: app, role: roleDelete})
+	}
+	return
+}
+
+func (p statusPlan) appStatus(ctx context.Context, cfClient *cfclient.Client, app *resource.App, role string, now time.Time) (status appStatus, err error) {
+	status = appStatus{
+		Name:       app.Name,
+		Role:       role,
+		State:      app.State,
+		Routes:     []string{},
+		CreatedAt:  app.CreatedAt,
+		Age:        formatAge(now.Sub(app.CreatedAt)),
+		Provenance: readProvenance(app.Metadata),
+	}
+
+	if app.State == "STARTED" {
+		stats, err := cfClient.Processes.GetStatsForApp(ctx, app.GUID, "web")
+		if err != nil {
+			return status, err
+		}
+		for _, instance := range stats.Stats {
+			status.Instances++
+			if instance.State == "RUNNING" {
+				status.RunningInstances++
+			}
+		}
+	}
+
+	if status.DropletGUID, err = currentDropletGUID(cfClient.Droplets.GetCurrentAssociationForApp(ctx, app.GUID)); err != nil {
+		return
+	}
+
+	env, err := cfClient.Applications.GetEnvironmentVariables(ctx, app.GUID)
+	if err != nil {
+		return
+	}
+	if value := env["GIT_REVISION"]; value != nil {
+		status.GitRevision = *value
+	}
+	if value := env["BUILD_VERSION"]; value != nil {
+		status.BuildVersion = *value
+	}
+
+	routes, err := cfClient.Routes.ListForAppAll(ctx, app.GUID, nil)
+	if err != nil {
+		return
+	}
+	for _, route := range routes {
+		status.Routes = append(status.Routes, route.URL)
+	}
+	return
+}
+
+// currentDropletGUID is "none" for an app that has never been staged, e.g. a candidate whose push failed,
+// as CF says its current droplet is not found.
+func currentDropletGUID(droplet *resource.DropletCurrent, err error) (string, error) {
+	if resource.IsResourceNotFoundError(err) {
+		return "none", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return droplet.Data.GUID, nil
+}
+
+// problems flags the states that a successful halfpipe-all never leaves the family in.
+func (p statusPlan) problems(manifest manifestparser.Application, apps []appStatus) (problems []string) {
+	problems = []string{}
+	var live, candidate *appStatus
+	for i := range apps {
+		switch apps[i].Role {
+		case roleLive:
+			live = &apps[i]
+		case roleCandidate:
+			candidate = &apps[i]
+		}
+	}
+
+	if live == nil {
+		problems = append(problems, fmt.Sprintf("'%s' does not exist, nothing is live", manifest.Name))
+	} else {
+		if live.State != "STARTED" {
+			problems = append(problems, fmt.Sprintf("'%s' is %s", live.Name, live.State))
+		} else if live.RunningInstances < live.Instances {
+			problems = append(problems, fmt.Sprintf("only %d/%d instances of '%s' are running", live.RunningInstances, live.Instances, live.Name))
+		}
+	}
+
+	if candidate != nil && live != nil {
+		var liveRoutes []string
+		for _, route := range candidate.Routes {
+			if slices.Contains(live.Routes, route) {
+				liveRoutes = append(liveRoutes, route)
+			}
+		}
+		if len(liveRoutes) > 0 {
+			problems = append(problems, fmt.Sprintf("'%s' has the live routes [%s], it gets traffic before it is promoted", candidate.Name, strings.Join(liveRoutes, ", ")))
+		}
+	}
+
+	for _, app := range apps {
+		if (app.Role == roleOld || app.Role == roleDelete) && app.State == "STARTED" {
+			problems = append(problems, fmt.Sprintf("'%s' is still started, promote stops it", app.Name))
+		}
+	}
+	return
+}
+
+func (p statusPlan) text(status familyStatus) string {
+	var lines []string
+	if len(status.Apps) == 0 {
+		lines = append(lines, fmt.Sprintf("No apps found for '%s' in %s/%s", status.App, status.Org, status.Space))
+	}
+
+	for _, app := range status.Apps {
+		lines = append(lines, fmt.Sprintf("%s (%s)", app.Name, app.Role))
+		instances := app.State
+		if app.State == "STARTED" {
+			instances = fmt.Sprintf("%s, %d/%d instances running", app.State, app.RunningInstances, app.Instances)
+		}
+		lines = append(lines, fmt.Sprintf("  state:         %s", instances))
+		lines = append(lines, fmt.Sprintf("  droplet:       %s", orNone(app.DropletGUID)))
+		lines = append(lines, fmt.Sprintf("  git revision:  %s", orNone(app.GitRevision)))
+		lines = append(lines, fmt.Sprintf("  build version: %s", orNone(app.BuildVersion)))
+		lines = append(lines, fmt.Sprintf("  routes:        %s", orNone(strings.Join(app.Routes, ", "))))
+		lines = append(lines, fmt.Sprintf("  age:           %s, created %s", app.Age, app.CreatedAt.Format(time.RFC3339)))
+		var prov []string
+		for _, field := range app.Provenance.fields() {
+			if field.value != "" {
+				prov = append(prov, fmt.Sprintf("%s=%s", field.key, field.value))
+			}
+		}
+		lines = append(lines, fmt.Sprintf("  provenance:    %s", orNone(strings.Join(prov, ", "))))
+	}
+
+	lines = append(lines, "")
+	if len(status.Problems) == 0 {
+		lines = append(lines, "No problems found")
+	} else {
+		lines = append(lines, fmt.Sprintf("Found %d problems:", len(status.Problems)))
+		for _, problem := range status.Problems {
+			lines = append(lines, fmt.Sprintf("* %s", problem))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// formatAge rounds a duration to something readable, like '3d4h', '5h12m' or '7m'.
+func formatAge(d time.Duration) string {
+	d = d.Round(time.Minute)
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd%dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh%dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
+
+func NewStatusPlan() StatusPlan {
+	return statusPlan{}
+}
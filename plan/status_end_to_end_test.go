@@ -0,0 +1,21 @@
+package plan_test
+
+import (
+	"testing"
+
+	"github.com/springernature/halfpipe-deploy-resource/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndToEndStatusOfAnAppWithoutADroplet(t *testing.T) {
+	e := newEndToEnd(t, endToEndManifest)
+	e.mustRun(config.PUSH)
+	e.mustRun(config.PROMOTE)
+	e.server.AddApp("my-app-CANDIDATE")
+
+	require.NoError(t, e.run(e.request(config.STATUS)))
+
+	assert.Contains(t, e.output.String(), "my-app-CANDIDATE")
+	assert.Contains(t, e.output.String(), "droplet:       none")
+}
@@ -0,0 +1,141 @@
+package plan
+
+import (
+	"github.com/cloudfoundry/go-cfclient/v3/resource"
+	halfpipe_deploy_resource "github.com/springernature/halfpipe-deploy-resource"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestStatusPlan(t *testing.T) {
+	man := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp`).Applications[0]
+
+	p := NewStatusPlan().Plan(man, validRequest)
+
+	assert.Len(t, p, 1)
+	assert.Equal(t, "Reporting the state of 'myApp' and its candidate, old and deleted versions", p[0].String())
+}
+
+func TestStatusFamily(t *testing.T) {
+	apps := []*resource.App{
+		{Name: "myApp-DELETE"},
+		{Name: "otherApp"},
+		{Name: "myApp"},
+		{Name: "myApp-DELETE-1"},
+		{Name: "myApp-CANDIDATE"},
+		{Name: "myApp-OLD"},
+	}
+
+	var names, roles []string
+	for _, member := range (statusPlan{}).family("myApp", apps) {
+		names = append(names, member.app.Name)
+		roles = append(roles, member.role)
+	}
+
+	assert.Equal(t, []string{"myApp-CANDIDATE", "myApp", "myApp-OLD", "myApp-DELETE", "myApp-DELETE-1"}, names)
+	assert.Equal(t, []string{"candidate", "live", "old", "delete", "delete"}, roles)
+}
+
+func TestStatusProblems(t *testing.T) {
+	man := halfpipe_deploy_resource.ParseManifest(`applications:
+- name: myApp`).Applications[0]
+
+	// validRequest deploys to space 'c' with test domain 'kehe.com'.
+	live := appStatus{Name: "myApp", Role: roleLive, State: "STARTED", RunningInstances: 2, Instances: 2, Routes: []string{"myApp.domain.com"}}
+
+	t.Run("healthy family", func(t *testing.T) {
+		candidate := appStatus{Name: "myApp-CANDIDATE", Role: roleCandidate, State: "STARTED", Routes: []string{"myApp-c-CANDIDATE.kehe.com"}}
+		old := appStatus{Name: "myApp-OLD", Role: roleOld, State: "STOPPED"}
+
+		assert.Empty(t, statusPlan{}.problems(man, []appStatus{candidate, live, old}))
+	})
+
+	t.Run("missing live app", func(t *testing.T) {
+		assert.Equal(t, []string{"'myApp' does not exist, nothing is live"}, statusPlan{}.problems(man, nil))
+	})
+
+	t.Run("unhealthy live app", func(t *testing.T) {
+		unhealthy := live
+		unhealthy.RunningInstances = 1
+
+		stopped := live
+		stopped.State = "STOPPED"
+
+		assert.Equal(t, []string{"only 1/2 instances of 'myApp' are running"}, statusPlan{}.problems(man, []appStatus{unhealthy}))
+		assert.Equal(t, []string{"'myApp' is STOPPED"}, statusPlan{}.problems(man, []appStatus{stopped}))
+	})
+
+	t.Run("candidate with live routes", func(t *testing.T) {
+		candidate := appStatus{Name: "myApp-CANDIDATE", Role: roleCandidate, State: "STARTED", Routes: []string{"myApp-c-CANDIDATE.kehe.com", "myApp.domain.com"}}
+
+		assert.Equal(t, []string{"'myApp-CANDIDATE' has the live routes [myApp.domain.com], it gets traffic before it is promoted"}, statusPlan{}.problems(man, []appStatus{candidate, live}))
+	})
+
+	t.Run("candidate with routes the live app does not have", func(t *testing.T) {
+		candidate := appStatus{Name: "myApp-CANDIDATE", Role: roleCandidate, State: "STARTED", Routes: []string{"myApp-c-CANDIDATE.kehe.com", "myApp.domain.com/new-api"}}
+
+		assert.Empty(t, statusPlan{}.problems(man, []appStatus{candidate, live}))
+	})
+
+	t.Run("candidate without a live app", func(t *testing.T) {
+		candidate := appStatus{Name: "myApp-CANDIDATE", Role: roleCandidate, State: "STARTED", Routes: []string{"myApp.domain.com"}}
+
+		assert.Equal(t, []string{"'myApp' does not exist, nothing is live"}, statusPlan{}.problems(man, []appStatus{candidate}))
+	})
+
+	t.Run("old and deleted versions still started", func(t *testing.T) {
+		old := appStatus{Name: "myApp-OLD", Role: roleOld, State: "STARTED"}
+		deleted := appStatus{Name: "myApp-DELETE", Role: roleDelete, State: "STARTED"}
+
+		assert.Equal(t, []string{
+			"'myApp-OLD' is still started, promote stops it",
+			"'myApp-DELETE' is still started, promote stops it",
+		}, statusPlan{}.problems(man, []appStatus{live, old, deleted}))
+	})
+}
+
+func TestCurrentDropletGUID(t *testing.T) {
+	guid, err := currentDropletGUID(&resource.DropletCurrent{Data: resource.Relationship{GUID: "droplet-guid"}}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "droplet-guid", guid)
+
+	guid, err = currentDropletGUID(nil, resource.NewResourceNotFoundError())
+	assert.NoError(t, err)
+	assert.Equal(t, "none", guid)
+
+	_, err = currentDropletGUID(nil, resource.NewNotAuthorizedError())
+	assert.Error(t, err)
+}
+
+func TestStatusText(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	status := familyStatus{
+		App: "myApp",
+		Apps: []appStatus{
+			{Name: "myApp", Role: roleLive, State: "STARTED", RunningInstances: 1, Instances: 2, DropletGUID: "droplet", GitRevision: "abc", Routes: []string{"a.com", "b.com"}, CreatedAt: created, Age: "2d3h",
+				Provenance: provenance{GitRef: "abc", Version: "12"}},
+		},
+		Problems: []string{"only 1/2 instances of 'myApp' are running"},
+	}
+
+	assert.Equal(t, `myApp (live)
+  state:         STARTED, 1/2 instances running
+  droplet:       droplet
+  git revision:  abc
+  build version: -
+  routes:        a.com, b.com
+  age:           2d3h, created 2024-01-02T03:04:05Z
+  provenance:    git-ref=abc, version=12
+
+Found 1 problems:
+* only 1/2 instances of 'myApp' are running`, statusPlan{}.text(status))
+}
+
+func TestFormatAge(t *testing.T) {
+	assert.Equal(t, "0m", formatAge(20*time.Second))
+	assert.Equal(t, "7m", formatAge(7*time.Minute))
+	assert.Equal(t, "5h12m", formatAge(5*time.Hour+12*time.Minute))
+	assert.Equal(t, "3d4h", formatAge(76*time.Hour+30*time.Minute))
+}